@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -10,7 +11,7 @@ import (
 	"redis-lite/pkg/cfg"
 	"redis-lite/pkg/core"
 	"redis-lite/pkg/database"
-	"strings"
+	"redis-lite/pkg/resp"
 	"syscall"
 )
 
@@ -20,7 +21,7 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	db := database.NewStore()
+	dbs := database.NewDatabases(max(config.Databases, 1))
 
 	aofHandler, err := aof.NewAof(config)
 	if err != nil {
@@ -28,20 +29,17 @@ func main() {
 	}
 
 	slog.Info("Restoring data from AOF...")
-	aofHandler.Read(func(cmd string) {
-		cmd = strings.TrimSpace(cmd)
-		args := strings.Fields(cmd)
-		if len(args) == 0 {
-			return
-		}
-		core.Eval(db, args)
+	// replies of replayed commands are thrown away
+	replay := core.NewClient(dbs, resp.NewWriter(io.Discard))
+	aofHandler.Read(func(args [][]byte) {
+		core.Eval(replay, args)
 	})
 	slog.Info("Data restoration complete.")
 
 	jntr := database.NewJanitor(config)
-	go jntr.Run(db)
+	go jntr.Run(dbs)
 
-	srv := server.NewServer(config.Host, config.Port, db, aofHandler)
+	srv := server.NewServer(config.Host, config.Port, dbs, aofHandler)
 
 	if err := srv.Run(ctx); err != nil {
 		slog.ErrorContext(ctx, "server exited properly", "error", err)
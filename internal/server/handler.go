@@ -1,20 +1,33 @@
 package server
 
 import (
-	"bufio"
 	"context"
-	"fmt"
+	"errors"
 	"io"
 	"log/slog"
 	"net"
+	"os"
 	"redis-lite/pkg/core"
+	"redis-lite/pkg/resp"
 	"strings"
+	"time"
 )
 
 func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
-	reader := bufio.NewReader(conn)
+	ahead := &readAheadConn{Conn: conn}
+	reader := resp.NewReader(ahead)
+	// replies are collected here and flushed once per batch of pipelined commands
+	client := core.NewClient(s.DBs, resp.NewWriter(conn))
+	client.Propagate = func(db int, cmds ...[][]byte) {
+		if err := s.Aof.Write(db, cmds...); err != nil {
+			slog.ErrorContext(ctx, "AOF write error", "error", err)
+		}
+	}
+	client.Ctx = ctx
+	defer client.Close()
+	defer client.W.Flush()
 
 	for {
 		select {
@@ -23,67 +36,148 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 		default:
 		}
 
-		message, err := reader.ReadString('\n')
+		args, err := reader.ReadCommand()
 		if err != nil {
-			if err != io.EOF {
+			if resp.IsProtocolError(err) {
+				// the stream can't be trusted anymore, tell the client and hang up
+				client.W.WriteError("ERR " + err.Error())
+				slog.WarnContext(ctx, "Protocol error", "error", err)
+			} else if err != io.EOF {
 				slog.ErrorContext(ctx, "Read error", "error", err)
 			}
 			return
 		}
 
-		rawMessage := strings.TrimSpace(message)
-		args := strings.Fields(rawMessage)
-		if len(args) == 0 {
-			continue
-		}
+		if len(args) > 0 {
+			command := strings.ToUpper(string(args[0]))
 
-		command := strings.ToUpper(args[0])
+			slog.Debug("command: " + command)
 
-		slog.Info("command: " + command)
+			if command == "SUBSCRIBE" {
+				s.handleSubscribe(client, args)
+				return
+			}
 
-		if command == "SUBSCRIBE" {
-			s.handleSubscribe(conn, args)
-			return
+			if cmd := core.Lookup(command); cmd != nil && cmd.Has(core.FlagBlocking) {
+				s.evalBlocking(ctx, ahead, client, args)
+			} else {
+				core.Eval(client, args)
+			}
 		}
 
-		response := core.Eval(s.DB, args)
+		// only hit the network once the client has nothing more queued up
+		if reader.Buffered() == 0 {
+			if err := client.W.Flush(); err != nil {
+				slog.ErrorContext(ctx, "Write error", "error", err)
+				return
+			}
+		}
+	}
+}
 
-		conn.Write(response)
+// readAheadConn lets a watcher read from the connection while a command is
+// blocked: what it reads is kept in ahead, which the reader gets first.
+type readAheadConn struct {
+	net.Conn
+	ahead []byte
+}
 
-		if core.IsWriteOp(args[0]) && len(response) > 0 && response[0] != '-' {
-			s.Aof.Write(rawMessage)
+func (c *readAheadConn) Read(p []byte) (int, error) {
+	if len(c.ahead) > 0 {
+		n := copy(p, c.ahead)
+		c.ahead = c.ahead[n:]
+		if len(c.ahead) == 0 {
+			c.ahead = nil
 		}
+		return n, nil
 	}
+	return c.Conn.Read(p)
 }
 
-func (s *Server) handleSubscribe(conn net.Conn, args []string) {
+// maxReadAhead bounds what a blocked client may send before it is hung up
+// on, like Redis's client-query-buffer-limit.
+const maxReadAhead = 1 << 30
+
+// evalBlocking runs a command that may park the connection (XREAD BLOCK...).
+// Nobody decodes commands while it waits, so a watcher reads whatever the
+// client sends meanwhile, to be decoded afterwards, and notices the client
+// hanging up, which cancels the wait.
+func (s *Server) evalBlocking(ctx context.Context, conn *readAheadConn, client *core.Client, args [][]byte) {
+	// the replies of the commands pipelined before must not wait with us
+	if err := client.W.Flush(); err != nil {
+		return
+	}
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	watcherDone := make(chan struct{})
+	go func() {
+		defer close(watcherDone)
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Conn.Read(buf)
+			conn.ahead = append(conn.ahead, buf[:n]...)
+			switch {
+			case errors.Is(err, os.ErrDeadlineExceeded):
+				return
+			case err != nil:
+				cancel()
+				return
+			case len(conn.ahead) > maxReadAhead:
+				cancel()
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	client.Ctx = waitCtx
+	core.Eval(client, args)
+	client.Ctx = ctx
+
+	// an expired deadline wakes the watcher up, then the connection is ours again
+	conn.SetReadDeadline(time.Now())
+	<-watcherDone
+	conn.SetReadDeadline(time.Time{})
+}
+
+func (s *Server) handleSubscribe(client *core.Client, args [][]byte) {
+	w := client.W
+
 	// syntax => subscribe topic
 	if len(args) < 2 {
-		conn.Write([]byte("-ERR wrong number of arguments for 'subscribe' command\r\n"))
+		w.WriteError("ERR wrong number of arguments for 'subscribe' command")
 		return
 	}
 
-	topic := args[1]
+	topic := string(args[1])
 
 	// buffered chan for this specific client to receive messages
 	msgChan := make(chan string, 100)
 
-	s.DB.PubSub.Subscribe(topic, msgChan)
-	defer s.DB.PubSub.UnSubscribe(topic, msgChan)
-
-	// *{LENGTH_OF_ARRAY}
-	// ${LENGTH_OF_STRING}
-	// :{LENGTH_OF_SUBSCRIBED_CHANNELS}
-	confirm := fmt.Sprintf("*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(topic), topic)
-	conn.Write([]byte(confirm))
+	// the databases share one PubSub: channels aren't scoped to a database
+	s.DBs[0].PubSub.Subscribe(topic, msgChan)
+	defer s.DBs[0].PubSub.UnSubscribe(topic, msgChan)
+
+	// confirmation and messages are push frames in RESP3, plain arrays in RESP2:
+	// [subscribe, topic, number of subscribed channels]
+	w.WritePush(3)
+	w.WriteBulkString("subscribe")
+	w.WriteBulkString(topic)
+	w.WriteInteger(1)
+	if err := w.Flush(); err != nil {
+		return
+	}
 
 	// block here and push messages to the client as they arrive on the channel
 	for msg := range msgChan {
-		response := fmt.Sprintf("*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
-			len(topic), topic, len(msg), msg)
+		w.WritePush(3)
+		w.WriteBulkString("message")
+		w.WriteBulkString(topic)
+		w.WriteBulkString(msg)
 
-		_, err := conn.Write([]byte(response))
-		if err != nil {
+		if err := w.Flush(); err != nil {
 			slog.Info("Subscriber disconnected", "topic", topic)
 			return
 		}
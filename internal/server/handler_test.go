@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"redis-lite/pkg/aof"
+	"redis-lite/pkg/cfg"
+	"redis-lite/pkg/database"
+	"redis-lite/pkg/resp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingConn counts how many times the server hits the network.
+type countingConn struct {
+	net.Conn
+	writes atomic.Int32
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	c.writes.Add(1)
+	return c.Conn.Write(b)
+}
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	aofHandler, err := aof.NewAof(&cfg.Config{AofPath: t.TempDir() + "/test.aof"})
+	if err != nil {
+		t.Fatalf("Failed to create AOF: %v", err)
+	}
+	t.Cleanup(func() { aofHandler.Close() })
+
+	return NewServer("localhost", "0", database.NewDatabases(16), aofHandler)
+}
+
+func TestPipelinedCommandsAreBatched(t *testing.T) {
+	srv := newTestServer(t)
+
+	serverSide, clientSide := net.Pipe()
+	conn := &countingConn{Conn: serverSide}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		srv.handleConnection(ctx, conn)
+		close(done)
+	}()
+
+	var request []byte
+	var want strings.Builder
+	for i := 0; i < 16; i++ {
+		request = resp.AppendCommand(request, [][]byte{[]byte("SET"), []byte("key"), []byte("value")})
+		request = resp.AppendCommand(request, [][]byte{[]byte("GET"), []byte("key")})
+		want.WriteString("+OK\r\n$5\r\nvalue\r\n")
+	}
+
+	go clientSide.Write(request)
+
+	got := make([]byte, want.Len())
+	if _, err := io.ReadFull(bufio.NewReader(clientSide), got); err != nil {
+		t.Fatalf("Failed to read replies: %v", err)
+	}
+
+	if string(got) != want.String() {
+		t.Errorf("Unexpected replies:\n got %q\nwant %q", got, want.String())
+	}
+
+	if n := conn.writes.Load(); n != 1 {
+		t.Errorf("Expected a single write for the whole pipeline, got %d", n)
+	}
+
+	clientSide.Close()
+	<-done
+}
+
+func TestBlockedCommandEndsWhenClientCloses(t *testing.T) {
+	srv := newTestServer(t)
+
+	serverSide, clientSide := net.Pipe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		srv.handleConnection(ctx, serverSide)
+		close(done)
+	}()
+
+	request := resp.AppendCommand(nil, [][]byte{[]byte("BLPOP"), []byte("queue"), []byte("0")})
+	if _, err := clientSide.Write(request); err != nil {
+		t.Fatalf("Failed to send BLPOP: %v", err)
+	}
+
+	// let the command park, then hang up without reading anything
+	time.Sleep(20 * time.Millisecond)
+	clientSide.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Closing the connection did not unblock BLPOP")
+	}
+}
+
+func TestBlockedCommandEndsWhenClientClosesAfterPipelining(t *testing.T) {
+	srv := newTestServer(t)
+
+	serverSide, clientSide := net.Pipe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		srv.handleConnection(ctx, serverSide)
+		close(done)
+	}()
+
+	request := resp.AppendCommand(nil, [][]byte{[]byte("BLPOP"), []byte("queue"), []byte("0")})
+	if _, err := clientSide.Write(request); err != nil {
+		t.Fatalf("Failed to send BLPOP: %v", err)
+	}
+
+	// more commands while BLPOP waits don't stop the server from noticing
+	// the client hanging up
+	time.Sleep(20 * time.Millisecond)
+	if _, err := clientSide.Write(resp.AppendCommand(nil, [][]byte{[]byte("PING")})); err != nil {
+		t.Fatalf("Failed to send PING: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	clientSide.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Closing the connection did not unblock BLPOP")
+	}
+}
@@ -11,15 +11,15 @@ import (
 
 type Server struct {
 	ConfigAddr string
-	DB         *database.Store
+	DBs        database.Databases
 	Aof        *aof.Aof
 }
 
-func NewServer(host, port string, db *database.Store, aof *aof.Aof) *Server {
+func NewServer(host, port string, dbs database.Databases, aof *aof.Aof) *Server {
 	addr := fmt.Sprintf("%s:%s", host, port)
 	return &Server{
 		ConfigAddr: addr,
-		DB:         db,
+		DBs:        dbs,
 		Aof:        aof,
 	}
 }
@@ -5,6 +5,9 @@ import (
 	"io"
 	"os"
 	"redis-lite/pkg/cfg"
+	"redis-lite/pkg/resp"
+	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -12,11 +15,15 @@ type Aof struct {
 	file *os.File
 	rd   *bufio.Reader
 	mu   sync.Mutex
+	buf  []byte
+	// db is the database a replay of the file ends up in: a replay starts
+	// in database 0, and Read follows the SELECTs of an existing file.
+	db int
 }
 
 // NewAof opens (or creates) the database file.
 func NewAof(config *cfg.Config) (*Aof, error) {
-	f, err := os.OpenFile(config.AofPath, os.O_CREATE|os.O_RDWR, 0666)
+	f, err := os.OpenFile(config.AofPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
 	if err != nil {
 		return nil, err
 	}
@@ -34,15 +41,30 @@ func (aof *Aof) Close() error {
 	return aof.file.Close()
 }
 
-// Write adds a new command to the file
+// Write adds new commands to the file.
+// Commands are stored in the RESP multibulk format (like real Redis does),
+// so keys and values may contain any bytes, including spaces, NUL and CRLF.
+// Commands passed together (e.g. a MULTI ... EXEC block) are written in one go,
+// so writes from other clients can't end up in the middle of them.
+// db is the database the commands ran in: a SELECT is written first when it
+// isn't the one the file was left in, and SELECTs among cmds are followed.
 // Ideally, we would batch this or use a channel,
-// but for the MVP (our current structure) a Mutex + WriteString is safer to ensure order.
+// but for the MVP (our current structure) a Mutex + Write is safer to ensure order.
 // TODO: move this to a background channel.
-func (aof *Aof) Write(command string) error {
+func (aof *Aof) Write(db int, cmds ...[][]byte) error {
 	aof.mu.Lock()
 	defer aof.mu.Unlock()
 
-	_, err := aof.file.WriteString(command + "\n")
+	aof.buf = aof.buf[:0]
+	if db != aof.db {
+		aof.buf = resp.AppendCommand(aof.buf, [][]byte{[]byte("SELECT"), strconv.AppendInt(nil, int64(db), 10)})
+	}
+	aof.db = db
+	for _, args := range cmds {
+		aof.buf = resp.AppendCommand(aof.buf, args)
+		aof.follow(args)
+	}
+	_, err := aof.file.Write(aof.buf)
 	if err != nil {
 		return err
 	}
@@ -53,7 +75,20 @@ func (aof *Aof) Write(command string) error {
 	return nil
 }
 
-func (aof *Aof) Read(callback func(command string)) error {
+// follow tracks the database a replay is in after args.
+func (aof *Aof) follow(args [][]byte) {
+	if len(args) == 2 && strings.EqualFold(string(args[0]), "SELECT") {
+		if db, err := strconv.Atoi(string(args[1])); err == nil {
+			aof.db = db
+		}
+	}
+}
+
+// Read replays every command in the file through callback.
+// Files written by older versions (one inline command per line) are still understood.
+// It must run before the first Write to an existing file, which appends
+// to the database the replay ended in.
+func (aof *Aof) Read(callback func(args [][]byte)) error {
 	aof.mu.Lock()
 	defer aof.mu.Unlock()
 
@@ -62,19 +97,23 @@ func (aof *Aof) Read(callback func(command string)) error {
 		return err
 	}
 
-	reader := bufio.NewReader(aof.file)
+	reader := resp.NewReader(aof.file)
 
 	for {
-		line, err := reader.ReadString('\n')
+		args, err := reader.ReadCommand()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
 			return err
 		}
+		if len(args) == 0 {
+			continue
+		}
 
 		// execute the callback which will be our command handler
-		callback(line)
+		callback(args)
+		aof.follow(args)
 	}
 
 	return nil
@@ -1,13 +1,34 @@
 package aof
 
 import (
+	"bytes"
 	"os"
 	"redis-lite/pkg/cfg"
-	"strings"
+	"slices"
 	"testing"
 	"time"
 )
 
+func args(parts ...string) [][]byte {
+	out := make([][]byte, len(parts))
+	for i, p := range parts {
+		out[i] = []byte(p)
+	}
+	return out
+}
+
+func equalArgs(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func TestAof(t *testing.T) {
 	f, err := os.CreateTemp("", "aof_test_*.aof")
 	if err != nil {
@@ -39,40 +60,39 @@ func TestAof(t *testing.T) {
 
 	// 3. Test WRITE
 	// We simulate a few Redis commands
-	cmd1 := "SET key value"
-	cmd2 := "HSET user:1 name john"
-	cmd3 := "LPUSH list item"
+	cmd1 := args("SET", "key", "value")
+	cmd2 := args("HSET", "user:1", "name", "john")
+	cmd3 := args("LPUSH", "list", "item")
 
-	if err := aof.Write(cmd1); err != nil {
+	if err := aof.Write(0, cmd1); err != nil {
 		t.Errorf("Failed to write cmd1: %v", err)
 	}
-	if err := aof.Write(cmd2); err != nil {
+	if err := aof.Write(0, cmd2); err != nil {
 		t.Errorf("Failed to write cmd2: %v", err)
 	}
-	if err := aof.Write(cmd3); err != nil {
+	if err := aof.Write(0, cmd3); err != nil {
 		t.Errorf("Failed to write cmd3: %v", err)
 	}
 
 	// 4. Test READ (Verification 1: Immediate Read)
 	// We read the file we just wrote to (simulating checking consistency)
-	var lines []string
+	var lines [][][]byte
 
-	err = aof.Read(func(cmd string) {
-		// AOF stores with \n, so we trim it to compare
-		lines = append(lines, strings.TrimSpace(cmd))
+	err = aof.Read(func(cmd [][]byte) {
+		lines = append(lines, cmd)
 	})
 	if err != nil {
 		t.Fatalf("Failed to read AOF: %v", err)
 	}
 
 	if len(lines) != 3 {
-		t.Fatalf("Expected 3 lines, got %d", len(lines))
+		t.Fatalf("Expected 3 commands, got %d", len(lines))
 	}
-	if lines[0] != cmd1 {
-		t.Errorf("Line 1 mismatch. Want '%s', got '%s'", cmd1, lines[0])
+	if !equalArgs(lines[0], cmd1) {
+		t.Errorf("Command 1 mismatch. Want '%q', got '%q'", cmd1, lines[0])
 	}
-	if lines[1] != cmd2 {
-		t.Errorf("Line 2 mismatch. Want '%s', got '%s'", cmd2, lines[1])
+	if !equalArgs(lines[1], cmd2) {
+		t.Errorf("Command 2 mismatch. Want '%q', got '%q'", cmd2, lines[1])
 	}
 
 	// 5. Test PERSISTENCE (Simulate Server Restart)
@@ -88,18 +108,123 @@ func TestAof(t *testing.T) {
 	defer aofRestart.Close()
 
 	// Read again from the "new" server instance
-	var restoredLines []string
-	err = aofRestart.Read(func(cmd string) {
-		restoredLines = append(restoredLines, strings.TrimSpace(cmd))
+	var restoredLines [][][]byte
+	err = aofRestart.Read(func(cmd [][]byte) {
+		restoredLines = append(restoredLines, cmd)
 	})
 	if err != nil {
 		t.Fatalf("Failed to read AOF after restart: %v", err)
 	}
 
 	if len(restoredLines) != 3 {
-		t.Fatalf("Expected 3 restored lines, got %d", len(restoredLines))
+		t.Fatalf("Expected 3 restored commands, got %d", len(restoredLines))
+	}
+	if !equalArgs(restoredLines[2], cmd3) {
+		t.Errorf("Restored command 3 mismatch. Want '%q', got '%q'", cmd3, restoredLines[2])
+	}
+}
+
+func TestAofBinarySafe(t *testing.T) {
+	tempPath := t.TempDir() + "/binary.aof"
+	mockConfig := &cfg.Config{AofPath: tempPath}
+
+	aof, err := NewAof(mockConfig)
+	if err != nil {
+		t.Fatalf("Failed to create AOF: %v", err)
+	}
+	defer aof.Close()
+
+	cmd := args("SET", "key with spaces\r\n", "\x00\xff\r\nbinary\r\n\x00")
+	if err := aof.Write(0, cmd); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	var restored [][][]byte
+	if err := aof.Read(func(cmd [][]byte) { restored = append(restored, cmd) }); err != nil {
+		t.Fatalf("Failed to read AOF: %v", err)
+	}
+
+	if len(restored) != 1 || !equalArgs(restored[0], cmd) {
+		t.Errorf("Binary command mismatch. Want %q, got %q", cmd, restored)
+	}
+}
+
+func TestAofLegacyInlineFormat(t *testing.T) {
+	tempPath := t.TempDir() + "/legacy.aof"
+	if err := os.WriteFile(tempPath, []byte("SET key value\nLPUSH list item\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	aof, err := NewAof(&cfg.Config{AofPath: tempPath})
+	if err != nil {
+		t.Fatalf("Failed to create AOF: %v", err)
+	}
+	defer aof.Close()
+
+	var restored [][][]byte
+	if err := aof.Read(func(cmd [][]byte) { restored = append(restored, cmd) }); err != nil {
+		t.Fatalf("Failed to read AOF: %v", err)
+	}
+
+	if len(restored) != 2 || !equalArgs(restored[1], args("LPUSH", "list", "item")) {
+		t.Errorf("Legacy commands not restored, got %q", restored)
+	}
+}
+
+func TestAofSelectsDatabase(t *testing.T) {
+	mockConfig := &cfg.Config{AofPath: t.TempDir() + "/select.aof"}
+
+	aof, err := NewAof(mockConfig)
+	if err != nil {
+		t.Fatalf("Failed to create AOF: %v", err)
+	}
+	writes := []struct {
+		db   int
+		cmds [][][]byte
+	}{
+		{0, [][][]byte{args("SET", "a", "0")}},
+		{2, [][][]byte{args("SET", "b", "2")}},
+		{2, [][][]byte{args("SET", "c", "2")}},
+		{2, [][][]byte{args("MULTI"), args("SET", "d", "2"), args("SELECT", "3"), args("SET", "d", "3"), args("EXEC")}},
+		{3, [][][]byte{args("SET", "e", "3")}},
+	}
+	for _, w := range writes {
+		if err := aof.Write(w.db, w.cmds...); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+	aof.Close()
+
+	// after a restart, appending resumes in the database the replay ended in
+	aofRestart, err := NewAof(mockConfig)
+	if err != nil {
+		t.Fatalf("Failed to re-open AOF: %v", err)
+	}
+	if err := aofRestart.Read(func(cmd [][]byte) {}); err != nil {
+		t.Fatalf("Failed to read AOF: %v", err)
+	}
+	aofRestart.Write(3, args("SET", "f", "3"))
+	aofRestart.Write(0, args("SET", "g", "0"))
+	aofRestart.Close()
+
+	aofCheck, err := NewAof(mockConfig)
+	if err != nil {
+		t.Fatalf("Failed to re-open AOF: %v", err)
+	}
+	defer aofCheck.Close()
+
+	var restored []string
+	aofCheck.Read(func(cmd [][]byte) {
+		restored = append(restored, string(bytes.Join(cmd, []byte(" "))))
+	})
+	want := []string{
+		"SET a 0",
+		"SELECT 2", "SET b 2", "SET c 2",
+		"MULTI", "SET d 2", "SELECT 3", "SET d 3", "EXEC",
+		"SET e 3", "SET f 3",
+		"SELECT 0", "SET g 0",
 	}
-	if restoredLines[2] != cmd3 {
-		t.Errorf("Restored Line 3 mismatch. Want '%s', got '%s'", cmd3, restoredLines[2])
+	if !slices.Equal(restored, want) {
+		t.Errorf("Unexpected AOF contents:\n got %q\nwant %q", restored, want)
 	}
 }
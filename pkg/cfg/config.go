@@ -21,6 +21,8 @@ type Config struct {
 	ServerType      ServerType
 	JanitorInterval time.Duration
 	AofPath         string
+	// Databases is the number of databases SELECT can switch between.
+	Databases int
 }
 
 func NewConfig() *Config {
@@ -33,6 +35,7 @@ func NewConfig() *Config {
 		ServerType:      ServerType(getEnv("SERVER", "tcp")),
 		JanitorInterval: getEnvDuration("JANITOR_INTERVAL", time.Minute),
 		AofPath:         getEnv("AOF_PATH", "aof"),
+		Databases:       getEnvInt("DATABASES", 16),
 	}
 }
 
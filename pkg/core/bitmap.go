@@ -0,0 +1,264 @@
+package core
+
+import (
+	"redis-lite/pkg/database"
+	"strings"
+)
+
+const (
+	errBitOffset    = "ERR bit offset is not an integer or out of range"
+	errBitFieldType = "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."
+)
+
+// parseBitOffset parses the offset of SETBIT and GETBIT.
+func parseBitOffset(arg []byte) (int64, bool) {
+	offset, ok := parseInt(arg)
+	return offset, ok && offset >= 0 && offset <= database.MaxBitOffset
+}
+
+// setbit implements SETBIT key offset value.
+func setbit(c *Client, args [][]byte) bool {
+	offset, ok := parseBitOffset(args[2])
+	if !ok {
+		return fail(c, errBitOffset)
+	}
+	bit := string(args[3])
+	if bit != "0" && bit != "1" {
+		return fail(c, "ERR bit is not an integer or out of range")
+	}
+
+	old, err := c.DB.SetBit(string(args[1]), offset, bit[0]-'0')
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(old))
+	return true
+}
+
+// getbit implements GETBIT key offset.
+func getbit(c *Client, args [][]byte) bool {
+	offset, ok := parseBitOffset(args[2])
+	if !ok {
+		return fail(c, errBitOffset)
+	}
+
+	bit, err := c.DB.GetBit(string(args[1]), offset)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(bit))
+	return true
+}
+
+// parseBitRange parses the start end [BYTE | BIT] arguments of BITCOUNT and
+// BITPOS, start being args[0]. A missing end selects the end of the string.
+func parseBitRange(args [][]byte) (r database.BitRange, endGiven bool, msg string) {
+	r.End = -1
+	if len(args) == 0 {
+		return r, false, ""
+	}
+	if len(args) > 3 {
+		return r, false, errSyntax
+	}
+
+	var ok bool
+	if r.Start, ok = parseInt(args[0]); !ok {
+		return r, false, errNotInteger
+	}
+	if len(args) == 1 {
+		return r, false, ""
+	}
+	if r.End, ok = parseInt(args[1]); !ok {
+		return r, false, errNotInteger
+	}
+	if len(args) == 3 {
+		switch strings.ToUpper(string(args[2])) {
+		case "BYTE":
+		case "BIT":
+			r.Bits = true
+		default:
+			return r, false, errSyntax
+		}
+	}
+	return r, true, ""
+}
+
+// bitcount implements BITCOUNT key [start end [BYTE | BIT]].
+func bitcount(c *Client, args [][]byte) bool {
+	// unlike BITPOS, a start needs an end
+	if len(args) == 3 {
+		return fail(c, errSyntax)
+	}
+	r, _, msg := parseBitRange(args[2:])
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	n, err := c.DB.BitCount(string(args[1]), r)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(n)
+	return true
+}
+
+// bitpos implements BITPOS key bit [start [end [BYTE | BIT]]].
+func bitpos(c *Client, args [][]byte) bool {
+	bit, ok := parseInt(args[2])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+	if bit != 0 && bit != 1 {
+		return fail(c, "ERR The bit argument must be 1 or 0.")
+	}
+	r, endGiven, msg := parseBitRange(args[3:])
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	pos, err := c.DB.BitPos(string(args[1]), byte(bit), r, endGiven)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(pos)
+	return true
+}
+
+// bitop implements BITOP AND | OR | XOR | NOT destkey key [key ...].
+func bitop(c *Client, args [][]byte) bool {
+	var op database.BitOp
+	switch strings.ToUpper(string(args[1])) {
+	case "AND":
+		op = database.BitAnd
+	case "OR":
+		op = database.BitOr
+	case "XOR":
+		op = database.BitXor
+	case "NOT":
+		op = database.BitNot
+		if len(args) != 4 {
+			return fail(c, "ERR BITOP NOT must be called with a single source key.")
+		}
+	default:
+		return fail(c, errSyntax)
+	}
+
+	n, err := c.DB.BitOpStore(op, string(args[2]), argStrings(args[3:]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// bitfield implements BITFIELD key [GET encoding offset |
+// [OVERFLOW WRAP | SAT | FAIL] SET encoding offset value |
+// [OVERFLOW WRAP | SAT | FAIL] INCRBY encoding offset increment ...].
+func bitfield(c *Client, args [][]byte) bool {
+	var ops []database.BitFieldOp
+	overflow := database.OverflowWrap
+	writes := false
+	for i := 2; i < len(args); i++ {
+		sub := strings.ToUpper(string(args[i]))
+		if sub == "OVERFLOW" {
+			if i+1 >= len(args) {
+				return fail(c, errSyntax)
+			}
+			i++
+			switch strings.ToUpper(string(args[i])) {
+			case "WRAP":
+				overflow = database.OverflowWrap
+			case "SAT":
+				overflow = database.OverflowSat
+			case "FAIL":
+				overflow = database.OverflowFail
+			default:
+				return fail(c, "ERR Invalid OVERFLOW type specified")
+			}
+			continue
+		}
+
+		op := database.BitFieldOp{Overflow: overflow}
+		need := 3
+		switch sub {
+		case "GET":
+			op.Kind, need = database.BitFieldGet, 2
+		case "SET":
+			op.Kind = database.BitFieldSet
+		case "INCRBY":
+			op.Kind = database.BitFieldIncrBy
+		default:
+			return fail(c, errSyntax)
+		}
+		if i+need >= len(args) {
+			return fail(c, errSyntax)
+		}
+
+		var ok bool
+		if op.Signed, op.Bits, ok = parseBitFieldType(args[i+1]); !ok {
+			return fail(c, errBitFieldType)
+		}
+		if op.Offset, ok = parseBitFieldOffset(args[i+2], op.Bits); !ok {
+			return fail(c, errBitOffset)
+		}
+		if op.Kind != database.BitFieldGet {
+			if op.Value, ok = parseInt(args[i+3]); !ok {
+				return fail(c, errNotInteger)
+			}
+			writes = true
+		}
+		ops = append(ops, op)
+		i += need
+	}
+
+	results, err := c.DB.BitField(string(args[1]), ops)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	if !writes {
+		c.propagateAs()
+	}
+
+	c.W.WriteArray(len(results))
+	for _, res := range results {
+		if res.Nil {
+			c.W.WriteNull()
+		} else {
+			c.W.WriteInteger(res.Value)
+		}
+	}
+	return true
+}
+
+// parseBitFieldType parses a BITFIELD encoding: i1 to i64, or u1 to u63.
+func parseBitFieldType(arg []byte) (signed bool, bits int, ok bool) {
+	if len(arg) < 2 {
+		return false, 0, false
+	}
+	switch arg[0] {
+	case 'i', 'I':
+		signed = true
+	case 'u', 'U':
+	default:
+		return false, 0, false
+	}
+	n, ok := parseInt(arg[1:])
+	if !ok || n < 1 || n > 64 || (!signed && n == 64) {
+		return false, 0, false
+	}
+	return signed, int(n), true
+}
+
+// parseBitFieldOffset parses a BITFIELD offset, in bits, or in fields of
+// bits bits when prefixed with '#'.
+func parseBitFieldOffset(arg []byte, bits int) (int64, bool) {
+	scale := int64(1)
+	if len(arg) > 0 && arg[0] == '#' {
+		arg, scale = arg[1:], int64(bits)
+	}
+	n, ok := parseInt(arg)
+	if !ok || n < 0 || n > (database.MaxBitOffset+1-int64(bits))/scale {
+		return 0, false
+	}
+	return n * scale, true
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"testing"
+)
+
+// TestBitmapCommands replays the examples of the Redis documentation for each bitmap command.
+func TestBitmapCommands(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// SETBIT, GETBIT
+		{"SETBIT mykey 7 1", ":0\r\n"},
+		{"SETBIT mykey 7 0", ":1\r\n"},
+		{"GET mykey", "$1\r\n\x00\r\n"},
+		{"SETBIT mykey 7 1", ":0\r\n"},
+		{"GETBIT mykey 0", ":0\r\n"},
+		{"GETBIT mykey 7", ":1\r\n"},
+		{"GETBIT mykey 100", ":0\r\n"},
+		{"SETBIT mykey 23 1", ":0\r\n"},
+		{"STRLEN mykey", ":3\r\n"},
+		{"SETBIT mykey 7 2", "-ERR bit is not an integer or out of range\r\n"},
+		{"SETBIT mykey -1 1", "-ERR bit offset is not an integer or out of range\r\n"},
+		{"SETBIT mykey 4294967296 1", "-ERR bit offset is not an integer or out of range\r\n"},
+		{"GETBIT nosuchkey 3", ":0\r\n"},
+
+		// BITCOUNT
+		{"SET mykey foobar", "+OK\r\n"},
+		{"BITCOUNT mykey", ":26\r\n"},
+		{"BITCOUNT mykey 0 0", ":4\r\n"},
+		{"BITCOUNT mykey 1 1", ":6\r\n"},
+		{"BITCOUNT mykey 1 1 BYTE", ":6\r\n"},
+		{"BITCOUNT mykey 5 30 BIT", ":17\r\n"},
+		{"BITCOUNT mykey -2 -1", ":7\r\n"},
+		{"BITCOUNT mykey 3 1", ":0\r\n"},
+		{"BITCOUNT mykey 0", "-ERR syntax error\r\n"},
+		{"BITCOUNT mykey 0 1 WORD", "-ERR syntax error\r\n"},
+		{"BITCOUNT nosuchkey", ":0\r\n"},
+
+		// BITPOS
+		{"SETBIT pos 15 1", ":0\r\n"},
+		{"BITPOS pos 1", ":15\r\n"},
+		{"BITPOS pos 0", ":0\r\n"},
+		{"BITPOS pos 1 2", ":-1\r\n"},
+		{"BITPOS pos 0 1", ":8\r\n"},
+		{"BITPOS pos 1 0 -1 BIT", ":15\r\n"},
+		{"BITPOS pos 1 0 14 BIT", ":-1\r\n"},
+		{"BITPOS nosuchkey 1", ":-1\r\n"},
+		{"BITPOS nosuchkey 0", ":0\r\n"},
+		{"BITPOS pos 2", "-ERR The bit argument must be 1 or 0.\r\n"},
+
+		// BITOP
+		{"SET key1 foobar", "+OK\r\n"},
+		{"SET key2 abcdef", "+OK\r\n"},
+		{"BITOP AND dest key1 key2", ":6\r\n"},
+		{"GET dest", "$6\r\n`bc`ab\r\n"},
+		{"BITOP OR dest key1 nosuchkey", ":6\r\n"},
+		{"GET dest", "$6\r\nfoobar\r\n"},
+		{"BITOP XOR dest key1 key1", ":6\r\n"},
+		{"BITCOUNT dest", ":0\r\n"},
+		{"BITOP NOT dest nosuchkey", ":0\r\n"},
+		{"EXISTS dest", ":0\r\n"},
+		{"BITOP NOT dest key1 key2", "-ERR BITOP NOT must be called with a single source key.\r\n"},
+		{"BITOP NAND dest key1", "-ERR syntax error\r\n"},
+
+		// BITFIELD
+		{"BITFIELD field INCRBY i5 100 1 GET u4 0", "*2\r\n:1\r\n:0\r\n"},
+		{"BITFIELD counters INCRBY u2 100 1 OVERFLOW SAT INCRBY u2 102 1", "*2\r\n:1\r\n:1\r\n"},
+		{"BITFIELD counters INCRBY u2 100 1 OVERFLOW SAT INCRBY u2 102 1", "*2\r\n:2\r\n:2\r\n"},
+		{"BITFIELD counters INCRBY u2 100 1 OVERFLOW SAT INCRBY u2 102 1", "*2\r\n:3\r\n:3\r\n"},
+		{"BITFIELD counters INCRBY u2 100 1 OVERFLOW SAT INCRBY u2 102 1", "*2\r\n:0\r\n:3\r\n"},
+		{"BITFIELD counters OVERFLOW FAIL INCRBY u2 102 1", "*1\r\n$-1\r\n"},
+		{"BITFIELD signed SET i8 #1 -100 GET i8 8 GET u8 8", "*3\r\n:0\r\n:-100\r\n:156\r\n"},
+		{"BITFIELD signed INCRBY i8 #1 -100", "*1\r\n:56\r\n"},
+		{"BITFIELD signed OVERFLOW SAT INCRBY i8 #1 100 SET i8 #1 1000", "*2\r\n:127\r\n:127\r\n"},
+		{"BITFIELD signed OVERFLOW FAIL SET i8 #1 -129 GET i8 #1", "*2\r\n$-1\r\n:127\r\n"},
+		{"BITFIELD wide SET i64 0 -1 INCRBY i64 0 -9223372036854775808", "*2\r\n:0\r\n:9223372036854775807\r\n"},
+		{"BITFIELD wide OVERFLOW SAT INCRBY i64 0 1 GET u63 1", "*2\r\n:9223372036854775807\r\n:9223372036854775807\r\n"},
+		{"BITFIELD nosuchkey GET u8 0", "*1\r\n:0\r\n"},
+		{"EXISTS nosuchkey", ":0\r\n"},
+		{"BITFIELD field", "*0\r\n"},
+		{"BITFIELD field GET u64 0", "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n"},
+		{"BITFIELD field GET i0 0", "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n"},
+		{"BITFIELD field GET u8 -1", "-ERR bit offset is not an integer or out of range\r\n"},
+		{"BITFIELD field SET u8 0", "-ERR syntax error\r\n"},
+		{"BITFIELD field SET u8 0 x", "-ERR value is not an integer or out of range\r\n"},
+		{"BITFIELD field OVERFLOW LOOSE", "-ERR Invalid OVERFLOW type specified\r\n"},
+		{"BITFIELD field DECRBY u8 0 1", "-ERR syntax error\r\n"},
+
+		// wrong type
+		{"LPUSH list a", ":1\r\n"},
+		{"SETBIT list 0 1", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"BITCOUNT list", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"BITOP AND dest key1 list", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"BITFIELD list GET u8 0", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestBitPosSkipsBytes checks BITPOS across the bytes skipped whole, as in
+// the examples of the Redis documentation.
+func TestBitPosSkipsBytes(t *testing.T) {
+	tc := newTestClient()
+	tests := []struct {
+		value string
+		args  []string
+		want  string
+	}{
+		{"\xff\xf0\x00", []string{"0"}, ":12\r\n"},
+		{"\x00\xff\xf0", []string{"1", "0"}, ":8\r\n"},
+		{"\x00\xff\xf0", []string{"1", "2"}, ":16\r\n"},
+		{"\x00\xff\xf0", []string{"1", "2", "-1", "BYTE"}, ":16\r\n"},
+		{"\x00\xff\xf0", []string{"1", "7", "15", "BIT"}, ":8\r\n"},
+		{"\x00\x00\x00", []string{"1"}, ":-1\r\n"},
+		{"\x00\x00\x00", []string{"1", "7", "-3", "BIT"}, ":-1\r\n"},
+		{"\xff\xff\xff", []string{"0"}, ":24\r\n"},
+		{"\xff\xff\xff", []string{"0", "0", "-1"}, ":-1\r\n"},
+	}
+	for _, tt := range tests {
+		tc.doArgs("SET", "mykey", tt.value)
+		if got := tc.doArgs(append([]string{"BITPOS", "mykey"}, tt.args...)...); got != tt.want {
+			t.Errorf("BITPOS %q %v: got %q, want %q", tt.value, tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestBitfieldPropagation checks that BITFIELD only reaches the AOF when it
+// writes.
+func TestBitfieldPropagation(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+
+	tc.do("BITFIELD key GET u8 0")
+	tc.do("BITFIELD key SET u8 0 255 GET u8 0")
+	if len(aof) != 1 || string(aof[0][2]) != "SET" {
+		t.Errorf("Expected only the writing BITFIELD to be propagated, got %q", aof)
+	}
+}
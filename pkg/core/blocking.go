@@ -0,0 +1,99 @@
+package core
+
+import (
+	"math"
+	"redis-lite/pkg/database"
+	"strconv"
+	"time"
+)
+
+const errTimeoutNegative = "ERR timeout is negative"
+
+// blockOn parks a client reading keys (XREAD BLOCK...) until try succeeds.
+// try is called once right away and again every time one of keys is
+// written, until it reports that it served the client, the timeout elapses
+// (0 waits forever) or the client goes away. Clients that can't block (AOF
+// replay, commands inside EXEC) only get the first try. blockOn returns the
+// result of the last try.
+func blockOn(c *Client, keys []string, timeout time.Duration, try func() bool) bool {
+	if c.Ctx == nil {
+		return try()
+	}
+
+	// register before the first try, so a write between the try and the
+	// wait still wakes us up
+	w := c.DB.Block(keys...)
+	defer c.DB.Unblock(w)
+
+	return wait(c, w, timeout, try)
+}
+
+// blockPop parks a client consuming the lists at keys (BLPOP...), popping
+// them from the head when left, from the tail otherwise. pop is called
+// without a waiter first; when it finds nothing and the client can block,
+// it is called again with the client's consumer once that was served
+// (see database.BlockPop), the timeout elapsed or the client went away.
+// An element served meanwhile still goes to the client: like with Redis,
+// it is lost when the client is gone. blockPop returns the result of the
+// last call.
+func blockPop(c *Client, keys []string, left bool, timeout time.Duration, pop func(w *database.Waiter) bool) bool {
+	if done := pop(nil); done || c.Ctx == nil {
+		return done
+	}
+
+	w := c.DB.BlockPop(keys, left)
+	wait(c, w, timeout, w.Served)
+	c.DB.Unblock(w)
+	return pop(w)
+}
+
+// wait calls try until it succeeds, then every time w is signalled, and
+// reports whether it did before the timeout elapsed or the client went away.
+func wait(c *Client, w *database.Waiter, timeout time.Duration, try func() bool) bool {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		if try() {
+			return true
+		}
+		select {
+		case <-w.Ready:
+		case <-expired:
+			return false
+		case <-c.Ctx.Done():
+			return false
+		}
+	}
+}
+
+// parseTimeoutMs parses the BLOCK argument of XREAD and XREADGROUP (milliseconds).
+func parseTimeoutMs(arg []byte) (time.Duration, string) {
+	ms, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, "ERR timeout is not an integer or out of range"
+	}
+	if ms < 0 {
+		return 0, errTimeoutNegative
+	}
+	return time.Duration(ms) * time.Millisecond, ""
+}
+
+// parseTimeoutSec parses the timeout of BLPOP, BRPOP and BLMOVE (seconds, may be fractional).
+func parseTimeoutSec(arg []byte) (time.Duration, string) {
+	sec, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(sec) || math.IsInf(sec, 0) {
+		return 0, "ERR timeout is not a float or out of range"
+	}
+	if sec < 0 {
+		return 0, errTimeoutNegative
+	}
+	if sec > math.MaxInt64/float64(time.Second) {
+		return 0, "ERR timeout is out of range"
+	}
+	return time.Duration(sec * float64(time.Second)), ""
+}
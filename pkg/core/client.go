@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"redis-lite/pkg/database"
+	"redis-lite/pkg/resp"
+	"sync/atomic"
+)
+
+// Version is the Redis version we report to clients.
+// Client libraries use it to decide which commands and options they may send.
+const Version = "7.4.0"
+
+// nextClientID hands out connection ids, like Redis' CLIENT ID.
+var nextClientID atomic.Int64
+
+// Client holds the per-connection state commands can read or change.
+type Client struct {
+	ID   int64
+	Name string
+	// DBs are all the databases, DB the one SELECT picked, database 0 at first.
+	DBs database.Databases
+	DB  *database.Store
+	// W buffers the replies for this connection in the negotiated protocol.
+	W *resp.Writer
+	// Propagate receives the commands that modified the dataset, in execution order,
+	// with the number of the database they ran in (they may SELECT another one).
+	// The server appends them to the AOF; it is nil while the AOF itself is replayed.
+	Propagate func(db int, cmds ...[][]byte)
+	// Ctx is done when the connection goes away or the server shuts down, blocking
+	// commands stop waiting then. It is nil for clients that must never block,
+	// like the AOF replay.
+	Ctx context.Context
+
+	// db is the number of DB.
+	db int
+	// tx is non-nil between MULTI and EXEC/DISCARD.
+	tx *transaction
+	// watched maps the WATCHed keys to their version at WATCH time.
+	watched map[watchedKey]uint64
+	// rewrite replaces the running command in the AOF when rewritten is set, see propagateAs.
+	rewrite   [][][]byte
+	rewritten bool
+}
+
+// NewClient creates the state for a new connection to dbs replying through w.
+func NewClient(dbs database.Databases, w *resp.Writer) *Client {
+	return &Client{
+		ID:  nextClientID.Add(1),
+		DBs: dbs,
+		DB:  dbs[0],
+		W:   w,
+	}
+}
+
+// Close releases what the client holds in the store, like WATCHed keys.
+// It must be called when the connection goes away.
+func (c *Client) Close() {
+	c.unwatchAll()
+}
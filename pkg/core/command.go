@@ -0,0 +1,1195 @@
+package core
+
+import (
+	"sort"
+	"strings"
+)
+
+// Flag describes how a command behaves. Write detection for the AOF,
+// introspection and access control all read these instead of keeping their own lists.
+type Flag uint32
+
+const (
+	// FlagWrite marks commands that may modify the keyspace (they are appended to the AOF).
+	FlagWrite Flag = 1 << iota
+	// FlagReadOnly marks commands that only read data.
+	FlagReadOnly
+	// FlagFast marks commands running in O(1) or O(log N).
+	FlagFast
+	// FlagPubSub marks publish/subscribe commands.
+	FlagPubSub
+	// FlagAdmin marks server administration commands.
+	FlagAdmin
+	// FlagBlocking marks commands that may park the connection.
+	FlagBlocking
+	// FlagAllDBs marks commands that may reach past the selected database,
+	// like SELECT or MOVE: a transaction running one locks every database.
+	// Redis has no such flag, so COMMAND doesn't report it.
+	FlagAllDBs
+)
+
+var flagNames = []struct {
+	flag Flag
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadOnly, "readonly"},
+	{FlagFast, "fast"},
+	{FlagPubSub, "pubsub"},
+	{FlagAdmin, "admin"},
+	{FlagBlocking, "blocking"},
+}
+
+// Handler executes a command, writes its reply to the client and
+// returns false when that reply is an error.
+type Handler func(c *Client, args [][]byte) bool
+
+// Command is one entry of the command table.
+type Command struct {
+	// Name is the lower-case command name, as Redis reports it.
+	Name string
+	// Arity is the exact number of arguments including the command name,
+	// or -N when the command takes at least N arguments.
+	Arity int
+	Flags Flag
+	// FirstKey, LastKey and Step give the positions of the key arguments,
+	// the same way COMMAND INFO does. LastKey -1 means the last argument,
+	// FirstKey 0 means the command takes no keys.
+	FirstKey int
+	LastKey  int
+	Step     int
+	// Group is the data type or area the command belongs to (string, list, connection...).
+	Group string
+	// Since is the Redis version that introduced the command, Summary a one-line description.
+	// Both are served by COMMAND DOCS.
+	Since   string
+	Summary string
+	Handler Handler
+}
+
+// commandTable is the single list of every command the server understands.
+// To add a command, write its handler and add one entry here.
+var commandTable = []*Command{
+	// connection
+	{
+		Name: "ping", Arity: -1, Flags: FlagFast,
+		Group: "connection", Since: "1.0.0",
+		Summary: "Returns the server's liveliness response.",
+		Handler: ping,
+	},
+	{
+		Name: "hello", Arity: -1, Flags: FlagFast,
+		Group: "connection", Since: "6.0.0",
+		Summary: "Handshakes with the Redis server.",
+		Handler: hello,
+	},
+	{
+		Name: "select", Arity: 2, Flags: FlagFast | FlagAllDBs,
+		Group: "connection", Since: "1.0.0",
+		Summary: "Changes the selected database.",
+		Handler: selectDB,
+	},
+
+	// server
+	{
+		Name: "command", Arity: -1,
+		Group: "server", Since: "2.8.13",
+		Summary: "Returns detailed information about all commands.",
+		Handler: command,
+	},
+	{
+		Name: "dbsize", Arity: 1, Flags: FlagReadOnly | FlagFast,
+		Group: "server", Since: "1.0.0",
+		Summary: "Returns the number of keys in the database.",
+		Handler: dbsize,
+	},
+	{
+		Name: "flushdb", Arity: -1, Flags: FlagWrite | FlagAdmin,
+		Group: "server", Since: "1.0.0",
+		Summary: "Removes all keys from the current database.",
+		Handler: flushdb,
+	},
+	{
+		Name: "flushall", Arity: -1, Flags: FlagWrite | FlagAdmin | FlagAllDBs,
+		Group: "server", Since: "1.0.0",
+		Summary: "Removes all keys from all databases.",
+		Handler: flushall,
+	},
+	{
+		Name: "swapdb", Arity: 3, Flags: FlagWrite | FlagFast | FlagAdmin | FlagAllDBs,
+		Group: "server", Since: "4.0.0",
+		Summary: "Swaps two Redis databases.",
+		Handler: swapdb,
+	},
+
+	// transactions
+	{
+		Name: "multi", Arity: 1, Flags: FlagFast,
+		Group: "transactions", Since: "1.2.0",
+		Summary: "Starts a transaction.",
+		Handler: multi,
+	},
+	{
+		Name: "exec", Arity: 1,
+		Group: "transactions", Since: "1.2.0",
+		Summary: "Executes all commands in a transaction.",
+		Handler: exec,
+	},
+	{
+		Name: "discard", Arity: 1, Flags: FlagFast,
+		Group: "transactions", Since: "2.0.0",
+		Summary: "Discards a transaction.",
+		Handler: discard,
+	},
+	{
+		Name: "watch", Arity: -2, Flags: FlagFast,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "transactions", Since: "2.2.0",
+		Summary: "Monitors changes to keys to determine the execution of a transaction.",
+		Handler: watch,
+	},
+	{
+		Name: "unwatch", Arity: 1, Flags: FlagFast,
+		Group: "transactions", Since: "2.2.0",
+		Summary: "Forgets about watched keys of a transaction.",
+		Handler: unwatch,
+	},
+
+	// string
+	{
+		Name: "set", Arity: -3, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "1.0.0",
+		Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.",
+		Handler: set,
+	},
+	{
+		Name: "get", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "1.0.0",
+		Summary: "Returns the string value of a key.",
+		Handler: get,
+	},
+	{
+		Name: "incr", Arity: 2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "1.0.0",
+		Summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+		Handler: incr,
+	},
+	{
+		Name: "decr", Arity: 2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "1.0.0",
+		Summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+		Handler: decr,
+	},
+	{
+		Name: "incrby", Arity: 3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "1.0.0",
+		Summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+		Handler: incrby,
+	},
+	{
+		Name: "decrby", Arity: 3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "1.0.0",
+		Summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.",
+		Handler: decrby,
+	},
+	{
+		Name: "incrbyfloat", Arity: 3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "2.6.0",
+		Summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+		Handler: incrbyfloat,
+	},
+	{
+		Name: "append", Arity: 3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "2.0.0",
+		Summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.",
+		Handler: appendCmd,
+	},
+	{
+		Name: "strlen", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "2.2.0",
+		Summary: "Returns the length of a string value.",
+		Handler: strlen,
+	},
+	{
+		Name: "getrange", Arity: 4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "2.4.0",
+		Summary: "Returns a substring of the string stored at a key.",
+		Handler: getrange,
+	},
+	{
+		Name: "setrange", Arity: 4, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "2.2.0",
+		Summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.",
+		Handler: setrange,
+	},
+	{
+		Name: "mget", Arity: -2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "string", Since: "1.0.0",
+		Summary: "Atomically returns the string values of one or more keys.",
+		Handler: mget,
+	},
+	{
+		Name: "mset", Arity: -3, Flags: FlagWrite,
+		FirstKey: 1, LastKey: -1, Step: 2,
+		Group: "string", Since: "1.0.1",
+		Summary: "Atomically creates or modifies the string values of one or more keys.",
+		Handler: mset,
+	},
+	{
+		Name: "msetnx", Arity: -3, Flags: FlagWrite,
+		FirstKey: 1, LastKey: -1, Step: 2,
+		Group: "string", Since: "1.0.1",
+		Summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.",
+		Handler: msetnx,
+	},
+	{
+		Name: "getset", Arity: 3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "1.0.0",
+		Summary: "Returns the previous string value of a key after setting it to a new value.",
+		Handler: getset,
+	},
+	{
+		Name: "getdel", Arity: 2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "6.2.0",
+		Summary: "Returns the string value of a key after deleting the key.",
+		Handler: getdel,
+	},
+	{
+		Name: "getex", Arity: -2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "string", Since: "6.2.0",
+		Summary: "Returns the string value of a key after setting its expiration time.",
+		Handler: getex,
+	},
+
+	// bitmap
+	{
+		Name: "setbit", Arity: 4, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "bitmap", Since: "2.2.0",
+		Summary: "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.",
+		Handler: setbit,
+	},
+	{
+		Name: "getbit", Arity: 3, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "bitmap", Since: "2.2.0",
+		Summary: "Returns a bit value by offset.",
+		Handler: getbit,
+	},
+	{
+		Name: "bitcount", Arity: -2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "bitmap", Since: "2.6.0",
+		Summary: "Counts the number of set bits (population counting) in a string.",
+		Handler: bitcount,
+	},
+	{
+		Name: "bitpos", Arity: -3, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "bitmap", Since: "2.8.7",
+		Summary: "Finds the first set (1) or clear (0) bit in a string.",
+		Handler: bitpos,
+	},
+	{
+		Name: "bitop", Arity: -4, Flags: FlagWrite,
+		FirstKey: 2, LastKey: -1, Step: 1,
+		Group: "bitmap", Since: "2.6.0",
+		Summary: "Performs bitwise operations on multiple strings, and stores the result.",
+		Handler: bitop,
+	},
+	{
+		Name: "bitfield", Arity: -2, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "bitmap", Since: "3.2.0",
+		Summary: "Performs arbitrary bitfield integer operations on strings.",
+		Handler: bitfield,
+	},
+
+	// hyperloglog
+	{
+		Name: "pfadd", Arity: -2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hyperloglog", Since: "2.8.9",
+		Summary: "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist.",
+		Handler: pfadd,
+	},
+	{
+		Name: "pfcount", Arity: -2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "hyperloglog", Since: "2.8.9",
+		Summary: "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s).",
+		Handler: pfcount,
+	},
+	{
+		Name: "pfmerge", Arity: -2, Flags: FlagWrite,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "hyperloglog", Since: "2.8.9",
+		Summary: "Merges one or more HyperLogLog values into a single key.",
+		Handler: pfmerge,
+	},
+
+	// generic
+	{
+		Name: "del", Arity: -2, Flags: FlagWrite,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Deletes one or more keys.",
+		Handler: del,
+	},
+	{
+		Name: "unlink", Arity: -2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "generic", Since: "4.0.0",
+		Summary: "Asynchronously deletes one or more keys.",
+		Handler: del,
+	},
+	{
+		Name: "exists", Arity: -2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Determines whether one or more keys exist.",
+		Handler: exists,
+	},
+	{
+		Name: "touch", Arity: -2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "generic", Since: "3.2.1",
+		Summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.",
+		Handler: exists,
+	},
+	{
+		Name: "type", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Determines the type of value stored at a key.",
+		Handler: typeCmd,
+	},
+	{
+		Name: "rename", Arity: 3, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Renames a key and overwrites the destination.",
+		Handler: rename,
+	},
+	{
+		Name: "move", Arity: 3, Flags: FlagWrite | FlagFast | FlagAllDBs,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Moves a key to another database.",
+		Handler: move,
+	},
+	{
+		Name: "renamenx", Arity: 3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Renames a key only when the target key name doesn't exist.",
+		Handler: renamenx,
+	},
+	{
+		Name: "copy", Arity: -3, Flags: FlagWrite | FlagAllDBs,
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Group: "generic", Since: "6.2.0",
+		Summary: "Copies the value of a key to a new key.",
+		Handler: copyCmd,
+	},
+	{
+		Name: "keys", Arity: 2, Flags: FlagReadOnly,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Returns all key names that match a pattern.",
+		Handler: keys,
+	},
+	{
+		Name: "randomkey", Arity: 1, Flags: FlagReadOnly,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Returns a random key name from the database.",
+		Handler: randomkey,
+	},
+	{
+		Name: "scan", Arity: -2, Flags: FlagReadOnly,
+		Group: "generic", Since: "2.8.0",
+		Summary: "Iterates over the key names in the database.",
+		Handler: scan,
+	},
+	{
+		Name: "expire", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Sets the expiration time of a key in seconds.",
+		Handler: expire,
+	},
+	{
+		Name: "pexpire", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "2.6.0",
+		Summary: "Sets the expiration time of a key in milliseconds.",
+		Handler: pexpire,
+	},
+	{
+		Name: "expireat", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "1.2.0",
+		Summary: "Sets the expiration time of a key to a Unix timestamp.",
+		Handler: expireat,
+	},
+	{
+		Name: "pexpireat", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "2.6.0",
+		Summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.",
+		Handler: pexpireat,
+	},
+	{
+		Name: "ttl", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "1.0.0",
+		Summary: "Returns the expiration time in seconds of a key.",
+		Handler: ttl,
+	},
+	{
+		Name: "pttl", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "2.6.0",
+		Summary: "Returns the expiration time in milliseconds of a key.",
+		Handler: pttl,
+	},
+	{
+		Name: "expiretime", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "7.0.0",
+		Summary: "Returns the expiration time of a key as a Unix timestamp.",
+		Handler: expiretime,
+	},
+	{
+		Name: "pexpiretime", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "7.0.0",
+		Summary: "Returns the expiration time of a key as a Unix milliseconds timestamp.",
+		Handler: pexpiretime,
+	},
+	{
+		Name: "persist", Arity: 2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "generic", Since: "2.2.0",
+		Summary: "Removes the expiration time of a key.",
+		Handler: persist,
+	},
+
+	// hash
+	{
+		Name: "hset", Arity: -4, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Creates or modifies the value of fields in a hash.",
+		Handler: hset,
+	},
+	{
+		Name: "hget", Arity: 3, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Returns the value of a field in a hash.",
+		Handler: hget,
+	},
+	{
+		Name: "hsetnx", Arity: 4, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Sets the value of a field in a hash only when the field doesn't exist.",
+		Handler: hsetnx,
+	},
+	{
+		Name: "hmget", Arity: -3, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Returns the values of all fields in a hash.",
+		Handler: hmget,
+	},
+	{
+		Name: "hdel", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.",
+		Handler: hdel,
+	},
+	{
+		Name: "hgetall", Arity: 2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Returns all fields and values in a hash.",
+		Handler: hgetall,
+	},
+	{
+		Name: "hkeys", Arity: 2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Returns all fields in a hash.",
+		Handler: hkeys,
+	},
+	{
+		Name: "hvals", Arity: 2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Returns all values in a hash.",
+		Handler: hvals,
+	},
+	{
+		Name: "hlen", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Returns the number of fields in a hash.",
+		Handler: hlen,
+	},
+	{
+		Name: "hscan", Arity: -3, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.8.0",
+		Summary: "Iterates over fields and values of a hash.",
+		Handler: hscan,
+	},
+	{
+		Name: "hexists", Arity: 3, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Determines whether a field exists in a hash.",
+		Handler: hexists,
+	},
+	{
+		Name: "hincrby", Arity: 4, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.0.0",
+		Summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.",
+		Handler: hincrby,
+	},
+	{
+		Name: "hincrbyfloat", Arity: 4, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "2.6.0",
+		Summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist.",
+		Handler: hincrbyfloat,
+	},
+	{
+		Name: "hexpire", Arity: -6, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "7.4.0",
+		Summary: "Set expiry for hash field using relative time to expire (seconds).",
+		Handler: hexpire,
+	},
+	{
+		Name: "hpexpire", Arity: -6, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "7.4.0",
+		Summary: "Set expiry for hash field using relative time to expire (milliseconds).",
+		Handler: hpexpire,
+	},
+	{
+		Name: "hexpireat", Arity: -6, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "7.4.0",
+		Summary: "Set expiry for hash field using an absolute Unix timestamp (seconds).",
+		Handler: hexpireat,
+	},
+	{
+		Name: "hpexpireat", Arity: -6, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "7.4.0",
+		Summary: "Set expiry for hash field using an absolute Unix timestamp (milliseconds).",
+		Handler: hpexpireat,
+	},
+	{
+		Name: "httl", Arity: -5, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "7.4.0",
+		Summary: "Returns the TTL in seconds of a hash field.",
+		Handler: httl,
+	},
+	{
+		Name: "hpttl", Arity: -5, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "7.4.0",
+		Summary: "Returns the TTL in milliseconds of a hash field.",
+		Handler: hpttl,
+	},
+	{
+		Name: "hexpiretime", Arity: -5, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "7.4.0",
+		Summary: "Returns the expiration time of a hash field as a Unix timestamp, in seconds.",
+		Handler: hexpiretime,
+	},
+	{
+		Name: "hpexpiretime", Arity: -5, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "7.4.0",
+		Summary: "Returns the expiration time of a hash field as a Unix timestamp, in msec.",
+		Handler: hpexpiretime,
+	},
+	{
+		Name: "hpersist", Arity: -5, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "hash", Since: "7.4.0",
+		Summary: "Removes the expiration time for each specified field.",
+		Handler: hpersist,
+	},
+
+	// list
+	{
+		Name: "lpush", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.",
+		Handler: lpush,
+	},
+	{
+		Name: "rpush", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.",
+		Handler: rpush,
+	},
+	{
+		Name: "lpushx", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "2.2.0",
+		Summary: "Prepends one or more elements to a list only when the list exists.",
+		Handler: lpushx,
+	},
+	{
+		Name: "rpushx", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "2.2.0",
+		Summary: "Appends an element to a list only when the list exists.",
+		Handler: rpushx,
+	},
+	{
+		Name: "lpop", Arity: -2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.",
+		Handler: lpop,
+	},
+	{
+		Name: "rpop", Arity: -2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Returns and removes the last elements of the list. Deletes the list if the last element was popped.",
+		Handler: rpop,
+	},
+	{
+		Name: "llen", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Returns the length of a list.",
+		Handler: llen,
+	},
+	{
+		Name: "lindex", Arity: 3, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Returns an element from a list by its index.",
+		Handler: lindex,
+	},
+	{
+		Name: "lset", Arity: 4, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Sets the value of an element in a list by its index.",
+		Handler: lset,
+	},
+	{
+		Name: "linsert", Arity: 5, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "2.2.0",
+		Summary: "Inserts an element before or after another element in a list.",
+		Handler: linsert,
+	},
+	{
+		Name: "lrem", Arity: 4, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Removes elements from a list. Deletes the list if the last element was removed.",
+		Handler: lrem,
+	},
+	{
+		Name: "ltrim", Arity: 4, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.",
+		Handler: ltrim,
+	},
+	{
+		Name: "lrange", Arity: 4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "1.0.0",
+		Summary: "Returns a range of elements from a list.",
+		Handler: lrange,
+	},
+	{
+		Name: "lpos", Arity: -3, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "list", Since: "6.0.6",
+		Summary: "Returns the index of matching elements in a list.",
+		Handler: lpos,
+	},
+	{
+		Name: "lmove", Arity: 5, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Group: "list", Since: "6.2.0",
+		Summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.",
+		Handler: lmove,
+	},
+	{
+		Name: "blpop", Arity: -3, Flags: FlagWrite | FlagBlocking,
+		FirstKey: 1, LastKey: -2, Step: 1,
+		Group: "list", Since: "2.0.0",
+		Summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.",
+		Handler: blpop,
+	},
+	{
+		Name: "brpop", Arity: -3, Flags: FlagWrite | FlagBlocking,
+		FirstKey: 1, LastKey: -2, Step: 1,
+		Group: "list", Since: "2.0.0",
+		Summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.",
+		Handler: brpop,
+	},
+	{
+		Name: "blmove", Arity: 6, Flags: FlagWrite | FlagBlocking,
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Group: "list", Since: "6.2.0",
+		Summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved.",
+		Handler: blmove,
+	},
+
+	// set
+	{
+		Name: "sadd", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Adds one or more members to a set. Creates the key if it doesn't exist.",
+		Handler: sadd,
+	},
+	{
+		Name: "smembers", Arity: 2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Returns all members of a set.",
+		Handler: smembers,
+	},
+	{
+		Name: "sscan", Arity: -3, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "set", Since: "2.8.0",
+		Summary: "Iterates over members of a set.",
+		Handler: sscan,
+	},
+	{
+		Name: "srem", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Removes one or more members from a set. Deletes the set if the last member was removed.",
+		Handler: srem,
+	},
+	{
+		Name: "sismember", Arity: 3, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Determines whether a member belongs to a set.",
+		Handler: sismember,
+	},
+	{
+		Name: "smismember", Arity: -3, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "set", Since: "6.2.0",
+		Summary: "Determines whether multiple members belong to a set.",
+		Handler: smismember,
+	},
+	{
+		Name: "scard", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Returns the number of members in a set.",
+		Handler: scard,
+	},
+	{
+		Name: "spop", Arity: -2, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.",
+		Handler: spop,
+	},
+	{
+		Name: "srandmember", Arity: -2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Get one or multiple random members from a set",
+		Handler: srandmember,
+	},
+	{
+		Name: "smove", Arity: 4, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Moves a member from one set to another.",
+		Handler: smove,
+	},
+	{
+		Name: "sinter", Arity: -2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Returns the intersect of multiple sets.",
+		Handler: sinter,
+	},
+	{
+		Name: "sintercard", Arity: -3, Flags: FlagReadOnly,
+		Group: "set", Since: "7.0.0",
+		Summary: "Returns the number of members of the intersect of multiple sets.",
+		Handler: sintercard,
+	},
+	{
+		Name: "sinterstore", Arity: -3, Flags: FlagWrite,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Stores the intersect of multiple sets in a key.",
+		Handler: sinterstore,
+	},
+	{
+		Name: "sunion", Arity: -2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Returns the union of multiple sets.",
+		Handler: sunion,
+	},
+	{
+		Name: "sunionstore", Arity: -3, Flags: FlagWrite,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Stores the union of multiple sets in a key.",
+		Handler: sunionstore,
+	},
+	{
+		Name: "sdiff", Arity: -2, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Returns the difference of multiple sets.",
+		Handler: sdiff,
+	},
+	{
+		Name: "sdiffstore", Arity: -3, Flags: FlagWrite,
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Group: "set", Since: "1.0.0",
+		Summary: "Stores the difference of multiple sets in a key.",
+		Handler: sdiffstore,
+	},
+
+	// sorted-set
+	{
+		Name: "zadd", Arity: -4, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "1.2.0",
+		Summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.",
+		Handler: zadd,
+	},
+	{
+		Name: "zincrby", Arity: 4, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "1.2.0",
+		Summary: "Increments the score of a member in a sorted set.",
+		Handler: zincrby,
+	},
+	{
+		Name: "zrem", Arity: -3, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "1.2.0",
+		Summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.",
+		Handler: zrem,
+	},
+	{
+		Name: "zcard", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "1.2.0",
+		Summary: "Returns the number of members in a sorted set.",
+		Handler: zcard,
+	},
+	{
+		Name: "zscore", Arity: 3, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "1.2.0",
+		Summary: "Returns the score of a member in a sorted set.",
+		Handler: zscore,
+	},
+	{
+		Name: "zscan", Arity: -3, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "2.8.0",
+		Summary: "Iterates over members and scores of a sorted set.",
+		Handler: zscan,
+	},
+	{
+		Name: "zrank", Arity: -3, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "2.0.0",
+		Summary: "Returns the index of a member in a sorted set ordered by ascending scores.",
+		Handler: zrank,
+	},
+	{
+		Name: "zrevrank", Arity: -3, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "2.0.0",
+		Summary: "Returns the index of a member in a sorted set ordered by descending scores.",
+		Handler: zrevrank,
+	},
+	{
+		Name: "zrange", Arity: -4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "1.2.0",
+		Summary: "Returns members in a sorted set within a range of indexes.",
+		Handler: zrange,
+	},
+	{
+		Name: "zrevrange", Arity: -4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "1.2.0",
+		Summary: "Returns members in a sorted set within a range of indexes in reverse order.",
+		Handler: zrevrange,
+	},
+	{
+		Name: "zrangebyscore", Arity: -4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "1.0.5",
+		Summary: "Returns members in a sorted set within a range of scores.",
+		Handler: zrangebyscore,
+	},
+	{
+		Name: "zrevrangebyscore", Arity: -4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "2.2.0",
+		Summary: "Returns members in a sorted set within a range of scores in reverse order.",
+		Handler: zrevrangebyscore,
+	},
+	{
+		Name: "zrangebylex", Arity: -4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "2.8.9",
+		Summary: "Returns members in a sorted set within a lexicographical range.",
+		Handler: zrangebylex,
+	},
+	{
+		Name: "zrevrangebylex", Arity: -4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "sorted-set", Since: "2.8.9",
+		Summary: "Returns members in a sorted set within a lexicographical range in reverse order.",
+		Handler: zrevrangebylex,
+	},
+	{
+		Name: "zrangestore", Arity: -5, Flags: FlagWrite,
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Group: "sorted-set", Since: "6.2.0",
+		Summary: "Stores a range of members from sorted set in a key.",
+		Handler: zrangestore,
+	},
+
+	// stream
+	{
+		Name: "xadd", Arity: -5, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Appends a new message to a stream. Creates the key if it doesn't exist.",
+		Handler: xadd,
+	},
+	{
+		Name: "xlen", Arity: 2, Flags: FlagReadOnly | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Return the number of messages in a stream.",
+		Handler: xlen,
+	},
+	{
+		Name: "xrange", Arity: -4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Returns the messages from a stream within a range of IDs.",
+		Handler: xrange,
+	},
+	{
+		Name: "xrevrange", Arity: -4, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Returns the messages from a stream within a range of IDs in reverse order.",
+		Handler: xrevrange,
+	},
+	{
+		Name: "xread", Arity: -4, Flags: FlagReadOnly | FlagBlocking,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.",
+		Handler: xread,
+	},
+	{
+		Name: "xreadgroup", Arity: -7, Flags: FlagWrite | FlagBlocking,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise.",
+		Handler: xreadgroup,
+	},
+	{
+		Name: "xgroup", Arity: -2, Flags: FlagWrite,
+		FirstKey: 2, LastKey: 2, Step: 1,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Creates, modifies and destroys consumer groups and their consumers.",
+		Handler: xgroup,
+	},
+	{
+		Name: "xack", Arity: -4, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream.",
+		Handler: xack,
+	},
+	{
+		Name: "xpending", Arity: -3, Flags: FlagReadOnly,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Returns the information and entries from a stream consumer group's pending entries list.",
+		Handler: xpending,
+	},
+	{
+		Name: "xclaim", Arity: -6, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "stream", Since: "5.0.0",
+		Summary: "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member.",
+		Handler: xclaim,
+	},
+	{
+		Name: "xautoclaim", Arity: -6, Flags: FlagWrite | FlagFast,
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Group: "stream", Since: "6.2.0",
+		Summary: "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member.",
+		Handler: xautoclaim,
+	},
+
+	// pubsub
+	{
+		Name: "publish", Arity: 3, Flags: FlagPubSub | FlagFast,
+		Group: "pubsub", Since: "2.0.0",
+		Summary: "Posts a message to a channel.",
+		Handler: publish,
+	},
+	{
+		Name: "subscribe", Arity: -2, Flags: FlagPubSub,
+		Group: "pubsub", Since: "2.0.0",
+		Summary: "Listens for messages published to a channel.",
+		Handler: subscribe,
+	},
+}
+
+// commands indexes commandTable by name, it is filled in init.
+var commands = make(map[string]*Command)
+
+func init() {
+	for _, cmd := range commandTable {
+		commands[cmd.Name] = cmd
+	}
+}
+
+// Lookup returns the command called name (case-insensitive), or nil.
+func Lookup(name string) *Command {
+	return commands[strings.ToLower(name)]
+}
+
+// Commands returns every registered command sorted by name.
+func Commands() []*Command {
+	list := make([]*Command, 0, len(commands))
+	for _, cmd := range commands {
+		list = append(list, cmd)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Has reports whether the command has flag set.
+func (cmd *Command) Has(flag Flag) bool {
+	return cmd.Flags&flag != 0
+}
+
+// FlagNames returns the flags as the strings Redis uses in COMMAND replies.
+func (cmd *Command) FlagNames() []string {
+	var names []string
+	for _, f := range flagNames {
+		if cmd.Has(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// Categories returns the ACL categories of the command (e.g. @write, @list),
+// derived from its flags and group.
+func (cmd *Command) Categories() []string {
+	var cats []string
+	if cmd.Has(FlagWrite) {
+		cats = append(cats, "@write")
+	}
+	if cmd.Has(FlagReadOnly) {
+		cats = append(cats, "@read")
+	}
+	if cmd.Has(FlagFast) {
+		cats = append(cats, "@fast")
+	} else {
+		cats = append(cats, "@slow")
+	}
+	if cmd.Has(FlagAdmin) {
+		cats = append(cats, "@admin", "@dangerous")
+	}
+	if cmd.Has(FlagBlocking) {
+		cats = append(cats, "@blocking")
+	}
+	switch cmd.Group {
+	case "generic":
+		cats = append(cats, "@keyspace")
+	case "sorted-set":
+		cats = append(cats, "@sortedset")
+	case "transactions":
+		cats = append(cats, "@transaction")
+	case "server":
+		// server commands only get the categories derived from their flags
+	default:
+		cats = append(cats, "@"+cmd.Group)
+	}
+	return cats
+}
+
+// CheckArity reports whether args (including the command name) has an accepted length.
+func (cmd *Command) CheckArity(args [][]byte) bool {
+	if cmd.Arity >= 0 {
+		return len(args) == cmd.Arity
+	}
+	return len(args) >= -cmd.Arity
+}
+
+// Keys returns the key arguments of a call to cmd.
+func (cmd *Command) Keys(args [][]byte) [][]byte {
+	if cmd.FirstKey == 0 {
+		return nil
+	}
+
+	last := cmd.LastKey
+	if last < 0 {
+		last = len(args) + last
+	}
+
+	var keys [][]byte
+	for i := cmd.FirstKey; i <= last && i < len(args); i += cmd.Step {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
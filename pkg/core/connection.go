@@ -0,0 +1,79 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ping implements PING [message].
+func ping(c *Client, args [][]byte) bool {
+	if len(args) > 2 {
+		return fail(c, "ERR wrong number of arguments for 'ping' command")
+	}
+	if len(args) == 2 {
+		c.W.WriteBulk(args[1])
+		return true
+	}
+	c.W.WriteSimpleString("PONG")
+	return true
+}
+
+// hello implements HELLO [protover [AUTH username password] [SETNAME clientname]].
+// It switches the connection protocol and replies with the server properties.
+func hello(c *Client, args [][]byte) bool {
+	w := c.W
+	proto := w.Protocol()
+
+	if len(args) > 1 {
+		ver, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			return fail(c, "ERR Protocol version is not an integer or out of range")
+		}
+		if ver != 2 && ver != 3 {
+			return fail(c, "NOPROTO unsupported protocol version")
+		}
+		proto = ver
+	}
+
+	name := c.Name
+	for i := 2; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch {
+		case opt == "AUTH" && i+2 < len(args):
+			// there is no ACL yet: only the default user exists and it has no password
+			if string(args[i+1]) != "default" {
+				return fail(c, "WRONGPASS invalid username-password pair or user is disabled.")
+			}
+			i += 2
+		case opt == "SETNAME" && i+1 < len(args):
+			name = string(args[i+1])
+			if strings.ContainsAny(name, " \n") {
+				return fail(c, "ERR Client names cannot contain spaces, newlines or special characters.")
+			}
+			i++
+		default:
+			return fail(c, "ERR Syntax error in HELLO option '"+string(args[i])+"'")
+		}
+	}
+
+	c.Name = name
+	w.SetProtocol(proto)
+
+	w.WriteMap(7)
+	w.WriteBulkString("server")
+	w.WriteBulkString("redis")
+	w.WriteBulkString("version")
+	w.WriteBulkString(Version)
+	w.WriteBulkString("proto")
+	w.WriteInteger(int64(proto))
+	w.WriteBulkString("id")
+	w.WriteInteger(c.ID)
+	w.WriteBulkString("mode")
+	w.WriteBulkString("standalone")
+	w.WriteBulkString("role")
+	w.WriteBulkString("master")
+	w.WriteBulkString("modules")
+	w.WriteArray(0)
+
+	return true
+}
@@ -1,186 +1,117 @@
 package core
 
 import (
-	"fmt"
-	"redis-lite/pkg/database"
+	"math"
 	"strconv"
 	"strings"
-	"time"
 )
 
-// Eval executes a command and returns the RESP-encoded response.
-func Eval(db *database.Store, args []string) []byte {
-	if len(args) == 0 {
-		return []byte("-ERR empty command\r\n")
-	}
+const (
+	errSyntax     = "ERR syntax error"
+	errNotInteger = "ERR value is not an integer or out of range"
+	errNotFloat   = "ERR value is not a valid float"
+)
 
-	errDuration := []byte("wrong duration for ttl")
-	errArgLen := func(command string) []byte {
-		err := fmt.Sprintf("-ERR wrong number of arguments for '%s' command\r\n", command)
-		return []byte(err)
+// Eval executes a command and writes the RESP-encoded reply to the client.
+// Arguments are passed through as raw bytes, so keys and values are binary-safe.
+// It returns false when the command replied with an error.
+func Eval(c *Client, args [][]byte) bool {
+	if len(args) == 0 {
+		return fail(c, "ERR empty command")
 	}
-	var err error
-
-	cmd := strings.ToUpper(args[0])
-
-	var expiry time.Duration
-
-	switch cmd {
-	case "PING":
-		return []byte("+PONG\r\n")
-
-	case "SET":
-		// syntax SET key value ttl
-		if len(args) < 3 {
-			return errArgLen("SET")
-		}
-		if len(args) > 3 {
-			expiry, err = time.ParseDuration(args[3])
-			if err != nil {
-				return errDuration
-			}
-		}
-		// Default TTL 0
-		db.Set(args[1], args[2], expiry)
-		return []byte("+OK\r\n")
-
-	case "GET":
-		if len(args) != 2 {
-			return errArgLen("GET")
-		}
-		val, found := db.Get(args[1])
-		if !found {
-			return []byte("$-1\r\n")
-		}
-		strVal, ok := val.(string)
-		if !ok {
-			return []byte("-ERR value is not a string\r\n")
-		}
-		return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(strVal), strVal))
 
-	case "DEL":
-		if len(args) != 2 {
-			return errArgLen("DEL")
+	cmd := Lookup(string(args[0]))
+	if cmd == nil {
+		if c.tx != nil {
+			c.tx.aborted = true
 		}
-		db.Delete(args[1])
-		return []byte(":1\r\n")
+		return fail(c, unknownCommand(args))
+	}
 
-	case "HSET":
-		// syntax: HSET key field value ttl
-		if len(args) < 4 {
-			return errArgLen("HSET")
-		}
-		if len(args) > 4 {
-			expiry, err = time.ParseDuration(args[4])
-			if err != nil {
-				return errDuration
-			}
-		}
-		created, err := db.HSet(args[1], args[2], args[3], expiry)
-		if err != nil {
-			return []byte("-ERR " + err.Error() + "\r\n")
+	if !cmd.CheckArity(args) {
+		if c.tx != nil {
+			c.tx.aborted = true
 		}
-		if created {
-			return []byte(":1\r\n")
-		}
-		return []byte(":0\r\n")
+		return fail(c, "ERR wrong number of arguments for '"+cmd.Name+"' command")
+	}
 
-	case "HGET":
-		if len(args) < 2 {
-			return errArgLen("HGET")
-		}
-		val, found := db.HGet(args[1], args[2])
-		if !found {
-			return []byte("$-1\r\n")
-		}
-		return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(val), val))
+	if c.tx != nil && queuesInMulti(cmd) {
+		c.tx.queue = append(c.tx.queue, args)
+		c.tx.cmds = append(c.tx.cmds, cmd)
+		c.W.WriteSimpleString("QUEUED")
+		return true
+	}
 
-	case "LPUSH":
-		if len(args) < 3 {
-			return errArgLen("LPUSH")
+	c.rewrite, c.rewritten = nil, false
+	ok := cmd.Handler(c, args)
+	if ok && cmd.Has(FlagWrite) && c.Propagate != nil {
+		if cmds := c.propagated(args); len(cmds) > 0 {
+			c.Propagate(c.db, cmds...)
 		}
-		if len(args) > 3 {
-			expiry, err = time.ParseDuration(args[3])
-			if err != nil {
-				return errDuration
-			}
-		}
-		count, err := db.LPush(args[1], args[2], expiry)
-		if err != nil {
-			return []byte("-ERR " + err.Error() + "\r\n")
-		}
-		return []byte(fmt.Sprintf(":%d\r\n", count))
+	}
+	return ok
+}
 
-	case "LPOP":
-		if len(args) < 2 {
-			return errArgLen("LPOP")
-		}
-		val, found := db.LPop(args[1])
-		if !found {
-			return []byte("$-1\r\n")
-		}
-		return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(val), val))
+// propagateAs makes cmds (possibly none) stand for the running command in
+// the AOF. Handlers use it when replaying the command as sent would not
+// reproduce its effect, e.g. XADD with an ID generated from the clock.
+func (c *Client) propagateAs(cmds ...[][]byte) {
+	c.rewrite, c.rewritten = cmds, true
+}
 
-	case "LRANGE":
-		if len(args) < 4 {
-			return errArgLen("LRANGE")
-		}
-		start, _ := strconv.Atoi(args[2])
-		stop, _ := strconv.Atoi(args[3])
+// propagated returns what the AOF receives for the command args that just ran.
+func (c *Client) propagated(args [][]byte) [][][]byte {
+	if c.rewritten {
+		return c.rewrite
+	}
+	return [][][]byte{args}
+}
 
-		list, found := db.LRange(args[1], start, stop)
-		if !found {
-			return []byte("*0\r\n")
-		}
+// IsWriteOp reports whether cmd may modify the keyspace.
+func IsWriteOp(cmd string) bool {
+	c := Lookup(cmd)
+	return c != nil && c.Has(FlagWrite)
+}
 
-		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("*%d\r\n", len(list)))
-		for _, v := range list {
-			sb.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(v), v))
-		}
-		return []byte(sb.String())
+// fail writes an error reply and reports the failure to the dispatcher.
+func fail(c *Client, msg string) bool {
+	c.W.WriteError(msg)
+	return false
+}
 
-	case "SADD":
-		if len(args) < 3 {
-			return errArgLen("SADD")
-		}
-		added, err := db.SAdd(args[1], args[2:])
-		if err != nil {
-			return []byte("-ERR " + err.Error() + "\r\n")
-		}
-		return []byte(fmt.Sprintf(":%d\r\n", added))
+// unknownCommand builds the Redis error for a command missing from the table.
+func unknownCommand(args [][]byte) string {
+	var sb strings.Builder
+	sb.WriteString("ERR unknown command '")
+	sb.Write(args[0])
+	sb.WriteString("', with args beginning with: ")
+	for _, arg := range args[1:] {
+		sb.WriteString("'")
+		sb.Write(arg)
+		sb.WriteString("' ")
+	}
+	return sb.String()
+}
 
-	case "SMEMBERS":
-		if len(args) < 2 {
-			return errArgLen("SMEMBERS")
-		}
-		members, found := db.SMembers(args[1])
-		if !found {
-			return []byte("*0\r\n")
-		}
+// parseInt parses a command argument as a 64 bit integer.
+func parseInt(arg []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	return n, err == nil
+}
 
-		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("*%d\r\n", len(members)))
-		for _, m := range members {
-			sb.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(m), m))
-		}
-		return []byte(sb.String())
-	case "PUBLISH":
-		// syntax: PUBLISH topic message
-		if len(args) < 3 {
-			return errArgLen("PUBLISH")
-		}
-		count := db.PubSub.Publish(args[1], args[2])
-		return []byte(fmt.Sprintf(":%d\r\n", count))
-	default:
-		return []byte(fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd))
-	}
+// parseFloat parses a command argument as a double, accepting "inf" and
+// "-inf" but not NaN, which has no place in a score or a counter.
+func parseFloat(arg []byte) (float64, bool) {
+	f, err := strconv.ParseFloat(string(arg), 64)
+	return f, err == nil && !math.IsNaN(f)
 }
 
-func IsWriteOp(cmd string) bool {
-	switch strings.ToUpper(cmd) {
-	case "SET", "DEL", "HSET", "LPUSH", "LPOP", "SADD":
-		return true
+// argStrings converts args to strings, for commands taking lists of keys,
+// members or fields.
+func argStrings(args [][]byte) []string {
+	strs := make([]string, len(args))
+	for i, a := range args {
+		strs[i] = string(a)
 	}
-	return false
+	return strs
 }
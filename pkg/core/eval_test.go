@@ -0,0 +1,778 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"redis-lite/pkg/aof"
+	"redis-lite/pkg/cfg"
+	"redis-lite/pkg/database"
+	"redis-lite/pkg/resp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testClient is a client whose replies are collected in a buffer.
+type testClient struct {
+	*Client
+	out *bytes.Buffer
+}
+
+func newTestClient() *testClient {
+	out := &bytes.Buffer{}
+	return &testClient{
+		Client: NewClient(database.NewDatabases(16), resp.NewWriter(out)),
+		out:    out,
+	}
+}
+
+// replay runs the commands of an AOF file holding content through tc, like
+// the server does at startup.
+func (tc *testClient) replay(t *testing.T, content string) {
+	t.Helper()
+
+	path := t.TempDir() + "/replay.aof"
+	if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	file, err := aof.NewAof(&cfg.Config{AofPath: path})
+	if err != nil {
+		t.Fatalf("Failed to open AOF: %v", err)
+	}
+	defer file.Close()
+
+	if err := file.Read(func(args [][]byte) { Eval(tc.Client, args) }); err != nil {
+		t.Fatalf("Failed to replay AOF: %v", err)
+	}
+	tc.W.Flush()
+}
+
+// do runs a command given as space-separated words and returns the raw reply.
+func (tc *testClient) do(command string) string {
+	return tc.doArgs(strings.Fields(command)...)
+}
+
+func (tc *testClient) doArgs(parts ...string) string {
+	args := make([][]byte, len(parts))
+	for i, p := range parts {
+		args[i] = []byte(p)
+	}
+
+	tc.out.Reset()
+	Eval(tc.Client, args)
+	tc.W.Flush()
+	return tc.out.String()
+}
+
+func TestHello(t *testing.T) {
+	tc := newTestClient()
+
+	reply := tc.do("HELLO")
+	if !strings.HasPrefix(reply, "*14\r\n$6\r\nserver\r\n") {
+		t.Errorf("HELLO without version should keep RESP2, got %q", reply)
+	}
+
+	reply = tc.do("HELLO 3 SETNAME worker-1")
+	if !strings.HasPrefix(reply, "%7\r\n") || !strings.Contains(reply, "$5\r\nproto\r\n:3\r\n") {
+		t.Errorf("HELLO 3 should reply with a RESP3 map, got %q", reply)
+	}
+	if tc.Name != "worker-1" {
+		t.Errorf("Expected client name to be set, got %q", tc.Name)
+	}
+
+	if reply := tc.do("GET missing"); reply != "_\r\n" {
+		t.Errorf("Expected RESP3 null after HELLO 3, got %q", reply)
+	}
+
+	tc.do("SADD set a")
+	if reply := tc.do("SMEMBERS set"); reply != "~1\r\n$1\r\na\r\n" {
+		t.Errorf("Expected RESP3 set reply, got %q", reply)
+	}
+
+	tc.do("HELLO 2")
+	if reply := tc.do("GET missing"); reply != "$-1\r\n" {
+		t.Errorf("Expected RESP2 null after HELLO 2, got %q", reply)
+	}
+}
+
+func TestHelloErrors(t *testing.T) {
+	tc := newTestClient()
+
+	if reply := tc.do("HELLO 4"); !strings.HasPrefix(reply, "-NOPROTO") {
+		t.Errorf("Expected NOPROTO, got %q", reply)
+	}
+	if reply := tc.do("HELLO 3 AUTH admin secret"); !strings.HasPrefix(reply, "-WRONGPASS") {
+		t.Errorf("Expected WRONGPASS, got %q", reply)
+	}
+	if reply := tc.do("HELLO 3 AUTH default secret"); !strings.HasPrefix(reply, "%7") {
+		t.Errorf("Expected default user to authenticate, got %q", reply)
+	}
+	if reply := tc.do("HELLO 3 BOGUS"); !strings.HasPrefix(reply, "-ERR Syntax error") {
+		t.Errorf("Expected syntax error, got %q", reply)
+	}
+}
+
+// readReply checks that b starts with exactly one well-formed RESP2/RESP3 value
+// and returns whatever follows it.
+func readReply(b []byte) ([]byte, error) {
+	line, rest, ok := bytes.Cut(b, []byte("\r\n"))
+	if !ok || len(line) == 0 {
+		return nil, fmt.Errorf("missing CRLF-terminated header in %q", b)
+	}
+
+	body := string(line[1:])
+	switch line[0] {
+	case '+', '-':
+		if strings.ContainsAny(body, "\r\n") {
+			return nil, fmt.Errorf("line break inside simple reply %q", line)
+		}
+		return rest, nil
+	case ':':
+		if _, err := strconv.ParseInt(body, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid integer %q", body)
+		}
+		return rest, nil
+	case ',':
+		if _, err := strconv.ParseFloat(body, 64); err != nil {
+			return nil, fmt.Errorf("invalid double %q", body)
+		}
+		return rest, nil
+	case '#':
+		if body != "t" && body != "f" {
+			return nil, fmt.Errorf("invalid boolean %q", body)
+		}
+		return rest, nil
+	case '_':
+		if body != "" {
+			return nil, fmt.Errorf("invalid null %q", line)
+		}
+		return rest, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("invalid bulk length %q", body)
+		}
+		if n == -1 {
+			return rest, nil
+		}
+		if len(rest) < n+2 || string(rest[n:n+2]) != "\r\n" {
+			return nil, fmt.Errorf("bulk string of length %d not terminated by CRLF in %q", n, rest)
+		}
+		return rest[n+2:], nil
+	case '*', '~', '>', '%':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 || (n == -1 && line[0] != '*') {
+			return nil, fmt.Errorf("invalid aggregate length %q", line)
+		}
+		if line[0] == '%' {
+			n *= 2
+		}
+		for i := 0; i < n; i++ {
+			if rest, err = readReply(rest); err != nil {
+				return nil, err
+			}
+		}
+		return rest, nil
+	}
+
+	return nil, fmt.Errorf("unknown reply type %q", line[0])
+}
+
+// replyCases exercise the success and error paths of every command in the table.
+var replyCases = [][]string{
+	{"PING"},
+	{"HELLO"},
+	{"HELLO", "9"},
+	{"HELLO", "3", "SETNAME"},
+	{"SET", "str", "value"},
+	{"SET", "str", "value", "10s"},
+	{"SET", "str", "value", "soon"},
+	{"SET", "str", "value", "EX", "10", "NX"},
+	{"SET", "str", "value", "PX", "10000", "XX", "GET"},
+	{"SET", "str", "value", "KEEPTTL"},
+	{"SET", "str", "value", "EXAT", "0"},
+	{"SET", "str", "value", "EX", "1", "PXAT", "1"},
+	{"SET", "str"},
+	{"GET", "str"},
+	{"GET", "missing"},
+	{"GET", "hash"},
+	{"GET"},
+	{"SET", "n", "10"},
+	{"INCR", "n"},
+	{"INCR", "str"},
+	{"INCR", "hash"},
+	{"DECR", "n"},
+	{"INCRBY", "n", "5"},
+	{"INCRBY", "n", "five"},
+	{"DECRBY", "n", "5"},
+	{"DECRBY", "n", "-9223372036854775808"},
+	{"INCRBYFLOAT", "n", "0.5"},
+	{"INCRBYFLOAT", "n", "half"},
+	{"INCRBYFLOAT", "str", "1"},
+	{"APPEND", "str", "!"},
+	{"APPEND", "hash", "!"},
+	{"STRLEN", "str"},
+	{"STRLEN", "hash"},
+	{"GETRANGE", "str", "0", "2"},
+	{"GETRANGE", "str", "0", "x"},
+	{"GETRANGE", "hash", "0", "1"},
+	{"SETRANGE", "str", "1", "abc"},
+	{"SETRANGE", "str", "-1", "abc"},
+	{"SETRANGE", "hash", "0", "abc"},
+	{"SETBIT", "bits", "7", "1"},
+	{"SETBIT", "bits", "7", "2"},
+	{"SETBIT", "hash", "7", "1"},
+	{"GETBIT", "bits", "7"},
+	{"GETBIT", "bits", "-7"},
+	{"BITCOUNT", "bits"},
+	{"BITCOUNT", "bits", "0", "-1", "BIT"},
+	{"BITCOUNT", "bits", "0"},
+	{"BITPOS", "bits", "1"},
+	{"BITPOS", "bits", "1", "0", "-1", "BYTE"},
+	{"BITPOS", "bits", "2"},
+	{"BITOP", "OR", "bits2", "bits", "str"},
+	{"BITOP", "NOT", "bits2", "bits", "str"},
+	{"BITFIELD", "bits", "GET", "u4", "0", "OVERFLOW", "FAIL", "INCRBY", "u4", "0", "16"},
+	{"BITFIELD", "bits", "SET", "u64", "0", "1"},
+	{"PFADD", "hll", "a", "b"},
+	{"PFADD", "hll", "a"},
+	{"PFADD", "hash", "a"},
+	{"PFADD", "str", "a"},
+	{"PFCOUNT", "hll"},
+	{"PFCOUNT", "hll", "missing"},
+	{"PFCOUNT", "str"},
+	{"PFMERGE", "hll2", "hll", "missing"},
+	{"PFMERGE", "hll2", "str"},
+	{"MGET", "str", "missing", "hash"},
+	{"MSET", "k1", "v1", "k2", "v2"},
+	{"MSET", "k1", "v1", "k2"},
+	{"MSETNX", "k1", "v1", "k3", "v3"},
+	{"MSETNX", "k3", "v3", "k4", "v4"},
+	{"GETSET", "k1", "v0"},
+	{"GETSET", "hash", "v0"},
+	{"GETEX", "k1", "EX", "100"},
+	{"GETEX", "k1", "PERSIST"},
+	{"GETEX", "k1", "PX", "0"},
+	{"GETEX", "k1", "EX"},
+	{"GETEX", "k1"},
+	{"GETDEL", "k1"},
+	{"GETDEL", "k1"},
+	{"GETDEL", "hash"},
+	{"DEL", "missing"},
+	{"DEL", "k2", "missing"},
+	{"DEL"},
+	{"UNLINK", "missing"},
+	{"EXISTS", "str", "missing"},
+	{"TOUCH", "str"},
+	{"TYPE", "str"},
+	{"RENAME", "missing", "other"},
+	{"RENAMENX", "missing", "other"},
+	{"COPY", "str", "str"},
+	{"COPY", "str", "copy", "DB", "zero"},
+	{"COPY", "str", "copy", "REPLACE"},
+	{"RENAME", "copy", "renamed"},
+	{"RENAMENX", "renamed", "str"},
+	{"KEYS", "*"},
+	{"KEYS", "k[12]"},
+	{"KEYS"},
+	{"RANDOMKEY"},
+	{"SCAN", "0"},
+	{"SCAN", "0", "MATCH", "k*", "COUNT", "100", "TYPE", "string"},
+	{"SCAN", "0", "TYPE", "blob"},
+	{"SCAN", "0", "COUNT", "0"},
+	{"SCAN", "-1"},
+	{"DBSIZE"},
+	{"MOVE", "missing", "1"},
+	{"MOVE", "str", "0"},
+	{"MOVE", "str", "16"},
+	{"SELECT", "1"},
+	{"SELECT", "16"},
+	{"SELECT", "one"},
+	{"SWAPDB", "0", "1"},
+	{"SWAPDB", "0", "one"},
+	{"SWAPDB", "1", "0"},
+	{"SELECT", "0"},
+	{"EXPIRE", "str", "100"},
+	{"EXPIRE", "str", "100", "NX"},
+	{"EXPIRE", "str", "100", "NX", "XX"},
+	{"EXPIRE", "str", "100", "GT", "LT"},
+	{"EXPIRE", "str", "100", "SOON"},
+	{"EXPIRE", "str", "ten"},
+	{"EXPIRE", "str", "9223372036854775807"},
+	{"PEXPIRE", "str", "100000", "XX"},
+	{"EXPIREAT", "missing", "4000000000"},
+	{"PEXPIREAT", "str", "4000000000000", "GT"},
+	{"TTL", "str"},
+	{"TTL", "missing"},
+	{"PTTL", "str"},
+	{"EXPIRETIME", "str"},
+	{"PEXPIRETIME", "missing"},
+	{"PERSIST", "str"},
+	{"PERSIST", "str"},
+	{"TTL", "str"},
+	{"HSET", "hash", "field", "value"},
+	{"HSET", "hash", "f2", "v2", "f3", "3"},
+	{"HSET", "hash", "field", "value", "odd"},
+	{"HSET", "str", "field", "value"},
+	{"HSET", "hash", "field"},
+	{"HGET", "hash", "field"},
+	{"HGET", "hash", "missing"},
+	{"HGET", "str", "field"},
+	{"HGET", "hash"},
+	{"HSETNX", "hash", "field", "other"},
+	{"HSETNX", "hash", "f4", "v4"},
+	{"HSETNX", "str", "f", "v"},
+	{"HMGET", "hash", "field", "missing"},
+	{"HMGET", "str", "field"},
+	{"HGETALL", "hash"},
+	{"HGETALL", "missing"},
+	{"HKEYS", "missing"},
+	{"HVALS", "str"},
+	{"HLEN", "hash"},
+	{"HLEN", "str"},
+	{"HSCAN", "hash", "0"},
+	{"HSCAN", "hash", "0", "MATCH", "f*", "NOVALUES"},
+	{"HSCAN", "hash", "0", "TYPE", "hash"},
+	{"HSCAN", "str", "0"},
+	{"HEXISTS", "hash", "field"},
+	{"HEXISTS", "hash", "missing"},
+	{"HINCRBY", "hash", "f3", "2"},
+	{"HINCRBY", "hash", "field", "1"},
+	{"HINCRBY", "hash", "f3", "x"},
+	{"HINCRBYFLOAT", "hash", "f3", "0.5"},
+	{"HINCRBYFLOAT", "hash", "field", "1"},
+	{"HINCRBYFLOAT", "hash", "f3", "nan"},
+	{"HEXPIRE", "hash", "100", "FIELDS", "1", "field"},
+	{"HEXPIRE", "hash", "100", "NX", "FIELDS", "2", "field", "missing"},
+	{"HEXPIRE", "hash", "soon", "FIELDS", "1", "field"},
+	{"HEXPIRE", "hash", "100", "FIELDS", "2", "field"},
+	{"HPEXPIRE", "hash", "100000", "XX", "FIELDS", "1", "field"},
+	{"HPEXPIRE", "hash", "-1", "FIELDS", "1", "field"},
+	{"HEXPIREAT", "hash", "4000000000", "GT", "FIELDS", "1", "field"},
+	{"HEXPIREAT", "str", "4000000000", "FIELDS", "1", "field"},
+	{"HPEXPIREAT", "hash", "4000000000000", "LT", "FIELDS", "1", "field"},
+	{"HPEXPIREAT", "hash", "4000000000000", "XX", "NX", "FIELDS", "1", "field"},
+	{"HTTL", "hash", "FIELDS", "2", "field", "missing"},
+	{"HTTL", "hash", "FIELDS", "0"},
+	{"HPTTL", "hash", "FIELDS", "1", "f3"},
+	{"HPTTL", "str", "FIELDS", "1", "f3"},
+	{"HEXPIRETIME", "hash", "FIELDS", "1", "field"},
+	{"HEXPIRETIME", "hash", "FIELD", "1", "field"},
+	{"HPEXPIRETIME", "missing", "FIELDS", "1", "field"},
+	{"HPERSIST", "hash", "FIELDS", "2", "field", "f3"},
+	{"HPERSIST", "str", "FIELDS", "1", "field"},
+	{"HPERSIST", "hash", "FIELDS", "x", "field"},
+	{"HPEXPIRE", "hash", "0", "FIELDS", "1", "f4"},
+	{"HDEL", "hash", "f2", "missing"},
+	{"HDEL", "str", "f"},
+	{"HDEL", "hash"},
+	{"LPUSH", "list", "a"},
+	{"LPUSH", "list", "b", "c"},
+	{"LPUSH", "str", "a"},
+	{"LPUSH", "list"},
+	{"RPUSH", "list", "d", "e"},
+	{"RPUSH", "str", "a"},
+	{"LPUSHX", "list", "f"},
+	{"LPUSHX", "missing", "f"},
+	{"RPUSHX", "list", "g"},
+	{"RPUSHX", "str", "g"},
+	{"LRANGE", "list", "0", "-1"},
+	{"LRANGE", "missing", "0", "-1"},
+	{"LRANGE", "list", "zero", "-1"},
+	{"LRANGE", "list", "0"},
+	{"LLEN", "list"},
+	{"LLEN", "str"},
+	{"LINDEX", "list", "-1"},
+	{"LINDEX", "list", "100"},
+	{"LINDEX", "list", "first"},
+	{"LSET", "list", "0", "x"},
+	{"LSET", "list", "100", "x"},
+	{"LSET", "missing", "0", "x"},
+	{"LINSERT", "list", "BEFORE", "x", "y"},
+	{"LINSERT", "list", "AFTER", "nope", "y"},
+	{"LINSERT", "list", "AROUND", "x", "y"},
+	{"LREM", "list", "0", "y"},
+	{"LREM", "list", "all", "y"},
+	{"LPOS", "list", "x"},
+	{"LPOS", "list", "x", "RANK", "-1", "COUNT", "0", "MAXLEN", "10"},
+	{"LPOS", "list", "x", "RANK", "0"},
+	{"LPOS", "list", "x", "COUNT"},
+	{"LMOVE", "list", "other", "RIGHT", "LEFT"},
+	{"LMOVE", "missing", "other", "RIGHT", "LEFT"},
+	{"LMOVE", "list", "str", "RIGHT", "LEFT"},
+	{"LMOVE", "list", "other", "UP", "LEFT"},
+	{"LTRIM", "other", "1", "0"},
+	{"LTRIM", "list", "0", "end"},
+	{"LPOP", "list"},
+	{"LPOP", "list", "2"},
+	{"LPOP", "missing", "2"},
+	{"LPOP", "list", "-1"},
+	{"LPOP", "list", "1", "2"},
+	{"LPOP", "missing"},
+	{"LPOP"},
+	{"RPOP", "list"},
+	{"RPOP", "str"},
+	{"LPUSH", "list", "a", "b", "c"},
+	{"BLPOP", "list", "missing", "0"},
+	{"BLPOP", "missing", "0.5"},
+	{"BLPOP", "list", "-1"},
+	{"BLPOP", "list", "soon"},
+	{"BLPOP", "str", "0"},
+	{"BLPOP", "list"},
+	{"BRPOP", "list", "0"},
+	{"BLMOVE", "list", "other", "LEFT", "RIGHT", "0"},
+	{"BLMOVE", "missing", "other", "LEFT", "RIGHT", "0"},
+	{"BLMOVE", "other", "str", "LEFT", "RIGHT", "0"},
+	{"BLMOVE", "other", "list", "UP", "RIGHT", "0"},
+	{"SADD", "set", "a", "b"},
+	{"SADD", "str", "a"},
+	{"SADD", "set"},
+	{"SMEMBERS", "set"},
+	{"SMEMBERS", "missing"},
+	{"SMEMBERS"},
+	{"SMEMBERS", "str"},
+	{"SSCAN", "set", "0", "COUNT", "1"},
+	{"SSCAN", "set", "0", "MATCH"},
+	{"SSCAN", "str", "0"},
+	{"SREM", "set", "a", "missing"},
+	{"SREM", "str", "a"},
+	{"SREM", "set"},
+	{"SISMEMBER", "set", "b"},
+	{"SISMEMBER", "set", "missing"},
+	{"SISMEMBER", "str", "b"},
+	{"SMISMEMBER", "set", "b", "missing"},
+	{"SMISMEMBER", "str", "b"},
+	{"SCARD", "set"},
+	{"SCARD", "str"},
+	{"SRANDMEMBER", "set"},
+	{"SRANDMEMBER", "set", "-3"},
+	{"SRANDMEMBER", "missing"},
+	{"SRANDMEMBER", "set", "x"},
+	{"SRANDMEMBER", "set", "1", "2"},
+	{"SADD", "other", "b", "c"},
+	{"SINTER", "set", "other"},
+	{"SINTER", "set", "str"},
+	{"SUNION", "set", "other", "missing"},
+	{"SDIFF", "other", "set"},
+	{"SINTERCARD", "2", "set", "other", "LIMIT", "1"},
+	{"SINTERCARD", "0", "set"},
+	{"SINTERCARD", "3", "set", "other"},
+	{"SINTERCARD", "1", "set", "LIMIT", "-1"},
+	{"SINTERCARD", "1", "set", "LIMIT"},
+	{"SINTERSTORE", "dest", "set", "other"},
+	{"SUNIONSTORE", "dest", "set", "other"},
+	{"SDIFFSTORE", "dest", "missing"},
+	{"SDIFFSTORE", "dest", "str"},
+	{"SMOVE", "other", "set", "c"},
+	{"SMOVE", "other", "set", "missing"},
+	{"SMOVE", "set", "str", "b"},
+	{"SPOP", "other"},
+	{"SPOP", "other"},
+	{"SPOP", "set", "2"},
+	{"SPOP", "set", "-1"},
+	{"SPOP", "str"},
+	{"ZADD", "zset", "1", "a", "2", "b", "3", "c"},
+	{"ZADD", "zset", "XX", "CH", "5", "a"},
+	{"ZADD", "zset", "INCR", "1.5", "a"},
+	{"ZADD", "zset", "NX", "INCR", "1", "a"},
+	{"ZADD", "zset", "NX", "XX", "1", "a"},
+	{"ZADD", "zset", "GT", "LT", "1", "a"},
+	{"ZADD", "zset", "INCR", "1", "a", "2", "b"},
+	{"ZADD", "zset", "1"},
+	{"ZADD", "zset", "one", "a"},
+	{"ZADD", "zset", "nan", "a"},
+	{"ZADD", "str", "1", "a"},
+	{"ZADD", "zset"},
+	{"ZINCRBY", "zset", "2", "b"},
+	{"ZINCRBY", "zset", "two", "b"},
+	{"ZINCRBY", "str", "1", "a"},
+	{"ZINCRBY", "zset", "1"},
+	{"ZCARD", "zset"},
+	{"ZCARD", "missing"},
+	{"ZCARD", "str"},
+	{"ZCARD"},
+	{"ZSCORE", "zset", "a"},
+	{"ZSCORE", "zset", "missing"},
+	{"ZSCORE", "str", "a"},
+	{"ZSCORE", "zset"},
+	{"ZSCAN", "zset", "0"},
+	{"ZSCAN", "zset", "x"},
+	{"ZSCAN", "str", "0"},
+	{"ZRANK", "zset", "a"},
+	{"ZRANK", "zset", "a", "WITHSCORE"},
+	{"ZRANK", "zset", "missing"},
+	{"ZRANK", "zset", "missing", "WITHSCORE"},
+	{"ZRANK", "zset", "a", "BOGUS"},
+	{"ZRANK", "str", "a"},
+	{"ZRANK", "zset"},
+	{"ZREVRANK", "zset", "a"},
+	{"ZREVRANK", "zset", "a", "WITHSCORE"},
+	{"ZREVRANK", "zset"},
+	{"ZRANGE", "zset", "0", "-1"},
+	{"ZRANGE", "zset", "0", "-1", "WITHSCORES"},
+	{"ZRANGE", "zset", "(1", "+inf", "BYSCORE", "LIMIT", "0", "2", "WITHSCORES"},
+	{"ZRANGE", "zset", "+inf", "-inf", "BYSCORE", "REV"},
+	{"ZRANGE", "zset", "[a", "+", "BYLEX"},
+	{"ZRANGE", "zset", "-", "+", "BYLEX", "WITHSCORES"},
+	{"ZRANGE", "zset", "0", "-1", "LIMIT", "0", "1"},
+	{"ZRANGE", "zset", "0", "-1", "BYSCORE", "LIMIT", "0"},
+	{"ZRANGE", "zset", "low", "high", "BYSCORE"},
+	{"ZRANGE", "zset", "a", "b", "BYLEX"},
+	{"ZRANGE", "zset", "zero", "-1"},
+	{"ZRANGE", "missing", "0", "-1"},
+	{"ZRANGE", "str", "0", "-1"},
+	{"ZRANGE", "zset", "0"},
+	{"ZREVRANGE", "zset", "0", "-1", "WITHSCORES"},
+	{"ZREVRANGE", "zset", "0", "-1", "BYSCORE"},
+	{"ZREVRANGE", "zset", "0"},
+	{"ZRANGEBYSCORE", "zset", "-inf", "(3", "WITHSCORES", "LIMIT", "1", "1"},
+	{"ZRANGEBYSCORE", "zset", "-inf", "+inf", "REV"},
+	{"ZRANGEBYSCORE", "zset", "0"},
+	{"ZREVRANGEBYSCORE", "zset", "+inf", "-inf", "WITHSCORES"},
+	{"ZREVRANGEBYSCORE", "zset", "+inf"},
+	{"ZRANGEBYLEX", "zset", "-", "(c"},
+	{"ZRANGEBYLEX", "zset", "-", "+", "WITHSCORES"},
+	{"ZRANGEBYLEX", "zset", "-"},
+	{"ZREVRANGEBYLEX", "zset", "+", "-", "LIMIT", "0", "1"},
+	{"ZREVRANGEBYLEX", "zset", "+"},
+	{"ZRANGESTORE", "dst", "zset", "0", "1"},
+	{"ZRANGESTORE", "dst", "zset", "0", "-1", "WITHSCORES"},
+	{"ZRANGESTORE", "dst", "str", "0", "-1"},
+	{"ZRANGESTORE", "dst", "zset", "0"},
+	{"ZREM", "zset", "a", "missing"},
+	{"ZREM", "str", "a"},
+	{"ZREM", "zset"},
+	{"XADD", "stream", "1-1", "field", "value"},
+	{"XADD", "stream", "*", "field", "value"},
+	{"XADD", "stream", "MAXLEN", "~", "10", "LIMIT", "5", "*", "f", "v"},
+	{"XADD", "stream", "MINID", "0", "5-*", "f", "v"},
+	{"XADD", "stream", "1-1", "field", "value"},
+	{"XADD", "stream", "0-0", "field", "value"},
+	{"XADD", "stream", "bad-id", "field", "value"},
+	{"XADD", "stream", "MAXLEN", "10", "LIMIT", "5", "*", "f", "v"},
+	{"XADD", "stream", "*", "field"},
+	{"XADD", "nostream", "NOMKSTREAM", "*", "f", "v"},
+	{"XADD", "str", "*", "f", "v"},
+	{"XADD", "stream", "*", "f"},
+	{"XLEN", "stream"},
+	{"XLEN", "missing"},
+	{"XLEN", "str"},
+	{"XLEN"},
+	{"XRANGE", "stream", "-", "+"},
+	{"XRANGE", "stream", "(1-1", "+", "COUNT", "1"},
+	{"XRANGE", "stream", "-", "+", "COUNT", "0"},
+	{"XRANGE", "stream", "-", "+", "LIMIT", "1"},
+	{"XRANGE", "stream", "x", "+"},
+	{"XRANGE", "str", "-", "+"},
+	{"XRANGE", "stream", "-"},
+	{"XREVRANGE", "stream", "+", "-", "COUNT", "2"},
+	{"XREVRANGE", "stream", "+", "(0-0"},
+	{"XREVRANGE", "stream", "+"},
+	{"XREAD", "COUNT", "2", "STREAMS", "stream", "missing", "0", "0"},
+	{"XREAD", "STREAMS", "stream", "$"},
+	{"XREAD", "BLOCK", "1", "STREAMS", "stream", "$"},
+	{"XREAD", "BLOCK", "-1", "STREAMS", "stream", "$"},
+	{"XREAD", "STREAMS", "stream"},
+	{"XREAD", "STREAMS", "str", "0"},
+	{"XREAD", "STREAMS", "stream", "x"},
+	{"XREAD", "STREAMS"},
+	{"XGROUP", "CREATE", "stream", "group", "0"},
+	{"XGROUP", "CREATE", "stream", "group", "$"},
+	{"XGROUP", "CREATE", "newstream", "group", "$", "MKSTREAM"},
+	{"XGROUP", "CREATE", "missing", "group", "$"},
+	{"XGROUP", "CREATECONSUMER", "stream", "group", "alice"},
+	{"XGROUP", "CREATECONSUMER", "stream", "nogroup", "alice"},
+	{"XGROUP", "SETID", "newstream", "group", "0"},
+	{"XGROUP", "DELCONSUMER", "newstream", "group", "alice"},
+	{"XGROUP", "DESTROY", "newstream", "group"},
+	{"XGROUP", "BOGUS", "stream", "group"},
+	{"XGROUP", "DESTROY", "stream"},
+	{"XGROUP"},
+	{"XREADGROUP", "GROUP", "group", "alice", "COUNT", "2", "STREAMS", "stream", ">"},
+	{"XREADGROUP", "GROUP", "group", "alice", "STREAMS", "stream", "0"},
+	{"XREADGROUP", "GROUP", "group", "alice", "BLOCK", "1", "STREAMS", "stream", ">"},
+	{"XREADGROUP", "GROUP", "group", "alice", "NOACK", "STREAMS", "stream", ">"},
+	{"XREADGROUP", "GROUP", "nogroup", "alice", "STREAMS", "stream", ">"},
+	{"XREADGROUP", "COUNT", "1", "STREAMS", "stream", ">"},
+	{"XREADGROUP", "GROUP", "group", "alice", "STREAMS", "stream"},
+	{"XPENDING", "stream", "group"},
+	{"XPENDING", "newstream", "group"},
+	{"XPENDING", "stream", "group", "IDLE", "0", "-", "+", "10"},
+	{"XPENDING", "stream", "group", "-", "+", "10", "alice"},
+	{"XPENDING", "stream", "group", "-", "+"},
+	{"XPENDING", "stream", "nogroup"},
+	{"XPENDING", "stream"},
+	{"XCLAIM", "stream", "group", "bob", "0", "1-1"},
+	{"XCLAIM", "stream", "group", "bob", "0", "1-1", "JUSTID", "RETRYCOUNT", "3"},
+	{"XCLAIM", "stream", "group", "bob", "0", "1-1", "BOGUS"},
+	{"XCLAIM", "stream", "group", "bob", "soon", "1-1"},
+	{"XCLAIM", "stream", "nogroup", "bob", "0", "1-1"},
+	{"XCLAIM", "stream", "group", "bob", "0"},
+	{"XAUTOCLAIM", "stream", "group", "carol", "0", "0", "COUNT", "1"},
+	{"XAUTOCLAIM", "stream", "group", "carol", "0", "0", "JUSTID"},
+	{"XAUTOCLAIM", "stream", "group", "carol", "0", "0", "COUNT", "0"},
+	{"XAUTOCLAIM", "stream", "nogroup", "carol", "0", "0"},
+	{"XAUTOCLAIM", "stream", "group", "carol", "0"},
+	{"XACK", "stream", "group", "1-1", "9-9"},
+	{"XACK", "stream", "group", "bad"},
+	{"XACK", "stream", "group"},
+	{"PUBLISH", "news", "hello"},
+	{"PUBLISH", "news"},
+	{"SUBSCRIBE", "news"},
+	{"COMMAND"},
+	{"COMMAND", "COUNT"},
+	{"COMMAND", "COUNT", "extra"},
+	{"COMMAND", "LIST"},
+	{"COMMAND", "INFO", "get", "nosuch"},
+	{"COMMAND", "INFO"},
+	{"COMMAND", "DOCS"},
+	{"COMMAND", "DOCS", "set", "nosuch"},
+	{"COMMAND", "BOGUS"},
+	{"NOSUCHCOMMAND", "arg"},
+	{"UNKNOWN\r\nINJECTED"},
+	{"EXEC"},
+	{"DISCARD"},
+	{"UNWATCH"},
+	{"WATCH", "str", "list"},
+	{"MULTI"},
+	{"MULTI"},
+	{"WATCH", "str"},
+	{"SET", "str", "queued"},
+	{"GET", "str"},
+	{"EXEC"},
+	{"MULTI"},
+	{"GET", "str", "too", "many"},
+	{"EXEC"},
+	{"MULTI"},
+	{"DISCARD"},
+	{"FLUSHDB", "NOW"},
+	{"FLUSHDB"},
+	{"FLUSHALL", "ASYNC"},
+}
+
+// TestRepliesAreWellFormed checks each case produces exactly one valid reply in both protocols.
+func TestRepliesAreWellFormed(t *testing.T) {
+	for _, proto := range []string{"2", "3"} {
+		tc := newTestClient()
+		tc.do("HELLO " + proto)
+
+		for _, cmd := range replyCases {
+			reply := tc.doArgs(cmd...)
+			rest, err := readReply([]byte(reply))
+			if err != nil {
+				t.Errorf("RESP%s %q: malformed reply %q: %v", proto, cmd, reply, err)
+				continue
+			}
+			if len(rest) != 0 {
+				t.Errorf("RESP%s %q: expected a single reply, got trailing %q", proto, cmd, rest)
+			}
+		}
+	}
+}
+
+func TestErrorReplies(t *testing.T) {
+	tc := newTestClient()
+	tc.do("HSET hash field value")
+
+	tests := map[string]string{
+		"SET k v soon":     "-ERR syntax error\r\n",
+		"GET hash":         "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n",
+		"LRANGE l zero -1": "-ERR value is not an integer or out of range\r\n",
+		"GET":              "-ERR wrong number of arguments for 'get' command\r\n",
+	}
+
+	for cmd, want := range tests {
+		if got := tc.do(cmd); got != want {
+			t.Errorf("%s: got %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+// TestEveryCommandHasReplyCases makes sure new commands get added to replyCases.
+func TestEveryCommandHasReplyCases(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, cmd := range replyCases {
+		covered[strings.ToLower(cmd[0])] = true
+	}
+
+	for _, cmd := range Commands() {
+		if !covered[cmd.Name] {
+			t.Errorf("Command %q has no entry in replyCases", cmd.Name)
+		}
+	}
+}
+
+func TestCommandTable(t *testing.T) {
+	for _, cmd := range Commands() {
+		if cmd.Handler == nil {
+			t.Errorf("%s: missing handler", cmd.Name)
+		}
+		if cmd.Has(FlagWrite) && cmd.Has(FlagReadOnly) {
+			t.Errorf("%s: can't be both write and readonly", cmd.Name)
+		}
+		if cmd.FirstKey > 0 && cmd.Step == 0 {
+			t.Errorf("%s: key step must be set when the command takes keys", cmd.Name)
+		}
+	}
+
+	if !IsWriteOp("set") || !IsWriteOp("LPUSH") || IsWriteOp("GET") || IsWriteOp("nosuch") {
+		t.Error("IsWriteOp should follow the write flag of the table")
+	}
+
+	keys := Lookup("set").Keys([][]byte{[]byte("SET"), []byte("k"), []byte("v")})
+	if len(keys) != 1 || string(keys[0]) != "k" {
+		t.Errorf("Expected SET to have key k, got %q", keys)
+	}
+}
+
+func TestArityErrors(t *testing.T) {
+	tc := newTestClient()
+
+	if got := tc.do("HGET hash"); got != "-ERR wrong number of arguments for 'hget' command\r\n" {
+		t.Errorf("Unexpected reply for missing argument: %q", got)
+	}
+	if got := tc.do("GET a b"); got != "-ERR wrong number of arguments for 'get' command\r\n" {
+		t.Errorf("Unexpected reply for extra argument: %q", got)
+	}
+	if got := tc.do("nosuch a"); got != "-ERR unknown command 'nosuch', with args beginning with: 'a' \r\n" {
+		t.Errorf("Unexpected reply for unknown command: %q", got)
+	}
+}
+
+func TestCommandIntrospection(t *testing.T) {
+	tc := newTestClient()
+
+	want := ":" + strconv.Itoa(len(Commands())) + "\r\n"
+	if got := tc.do("COMMAND COUNT"); got != want {
+		t.Errorf("COMMAND COUNT: got %q, want %q", got, want)
+	}
+
+	info := tc.do("COMMAND INFO get nosuch")
+	wantInfo := "*2\r\n*10\r\n$3\r\nget\r\n:2\r\n*2\r\n+readonly\r\n+fast\r\n:1\r\n:1\r\n:1\r\n" +
+		"*3\r\n+@read\r\n+@fast\r\n+@string\r\n*0\r\n*0\r\n*0\r\n*-1\r\n"
+	if info != wantInfo {
+		t.Errorf("COMMAND INFO get:\n got %q\nwant %q", info, wantInfo)
+	}
+
+	// the commands wiping or swapping databases are filtered as dangerous
+	for _, name := range []string{"FLUSHDB", "FLUSHALL", "SWAPDB"} {
+		if cats := Lookup(name).Categories(); !slices.Contains(cats, "@dangerous") || !slices.Contains(cats, "@admin") {
+			t.Errorf("%s: expected @admin and @dangerous, got %q", name, cats)
+		}
+	}
+
+	tc.do("HELLO 3")
+	docs := tc.do("COMMAND DOCS get")
+	if !strings.HasPrefix(docs, "%1\r\n$3\r\nget\r\n%3\r\n$7\r\nsummary\r\n") {
+		t.Errorf("COMMAND DOCS get should be a map of maps, got %q", docs)
+	}
+
+	// every command must be documented
+	for _, cmd := range Commands() {
+		if cmd.Summary == "" || cmd.Since == "" || cmd.Group == "" {
+			t.Errorf("%s: missing documentation", cmd.Name)
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package core
+
+import (
+	"redis-lite/pkg/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hset implements HSET key field value [field value ...].
+func hset(c *Client, args [][]byte) bool {
+	if ttl, ok := legacyTTL(args, 5); ok {
+		return legacyHSet(c, args, time.Now().Add(ttl))
+	}
+	if len(args)%2 != 0 {
+		return fail(c, "ERR wrong number of arguments for 'hset' command")
+	}
+
+	fields := make([]database.HashField, 0, (len(args)-2)/2)
+	for i := 2; i < len(args); i += 2 {
+		fields = append(fields, database.HashField{Name: string(args[i]), Value: args[i+1]})
+	}
+
+	added, err := c.DB.HSet(string(args[1]), fields...)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(added))
+	return true
+}
+
+// legacyHSet implements the HSET key field value ttl of older versions, see
+// legacyTTL: the field gets the deadline at.
+func legacyHSet(c *Client, args [][]byte, at time.Time) bool {
+	key, field := string(args[1]), string(args[2])
+	var added int
+	var err error
+	c.DB.WithLocked([]string{key}, false, func(tx *database.Store) {
+		if added, err = tx.HSet(key, database.HashField{Name: field, Value: args[3]}); err == nil {
+			tx.HExpire(key, at, database.ExpireFlags{}, []string{field})
+		}
+	})
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	c.propagateAs(
+		[][]byte{[]byte("HSET"), args[1], args[2], args[3]},
+		hpexpireatCommand(args[1], at, []string{field}),
+	)
+	c.W.WriteInteger(int64(added))
+	return true
+}
+
+// hsetnx implements HSETNX key field value.
+func hsetnx(c *Client, args [][]byte) bool {
+	set, err := c.DB.HSetNX(string(args[1]), string(args[2]), args[3])
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeBoolInteger(c, set)
+	return true
+}
+
+// writeBoolInteger writes the 1 or 0 integer replies of predicates like HEXISTS.
+func writeBoolInteger(c *Client, v bool) {
+	if v {
+		c.W.WriteInteger(1)
+	} else {
+		c.W.WriteInteger(0)
+	}
+}
+
+// hget implements HGET key field.
+func hget(c *Client, args [][]byte) bool {
+	val, found, err := c.DB.HGet(string(args[1]), string(args[2]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	if !found {
+		c.W.WriteNull()
+		return true
+	}
+	c.W.WriteBulk(val)
+	return true
+}
+
+// hmget implements HMGET key field [field ...].
+func hmget(c *Client, args [][]byte) bool {
+	values, err := c.DB.HMGet(string(args[1]), argStrings(args[2:]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	c.W.WriteArray(len(values))
+	for _, v := range values {
+		if v == nil {
+			c.W.WriteNull()
+		} else {
+			c.W.WriteBulk(v)
+		}
+	}
+	return true
+}
+
+// hdel implements HDEL key field [field ...].
+func hdel(c *Client, args [][]byte) bool {
+	removed, err := c.DB.HDel(string(args[1]), argStrings(args[2:]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(removed))
+	return true
+}
+
+// hgetall implements HGETALL key.
+func hgetall(c *Client, args [][]byte) bool {
+	fields, err := c.DB.HGetAll(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	c.W.WriteMap(len(fields))
+	for _, f := range fields {
+		c.W.WriteBulkString(f.Name)
+		c.W.WriteBulk(f.Value)
+	}
+	return true
+}
+
+// hkeys implements HKEYS key.
+func hkeys(c *Client, args [][]byte) bool {
+	fields, err := c.DB.HGetAll(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	c.W.WriteArray(len(fields))
+	for _, f := range fields {
+		c.W.WriteBulkString(f.Name)
+	}
+	return true
+}
+
+// hvals implements HVALS key.
+func hvals(c *Client, args [][]byte) bool {
+	fields, err := c.DB.HGetAll(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	c.W.WriteArray(len(fields))
+	for _, f := range fields {
+		c.W.WriteBulk(f.Value)
+	}
+	return true
+}
+
+// hscan implements HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES].
+func hscan(c *Client, args [][]byte) bool {
+	noValues := len(args) > 3 && strings.ToUpper(string(args[len(args)-1])) == "NOVALUES"
+	if noValues {
+		args = args[:len(args)-1]
+	}
+	cursor, opts, msg := parseScan(args, 2, false)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	next, fields, err := c.DB.HScan(string(args[1]), cursor, opts)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	if noValues {
+		writeScanCursor(c, next, len(fields))
+		for _, f := range fields {
+			c.W.WriteBulkString(f.Name)
+		}
+		return true
+	}
+	writeScanCursor(c, next, 2*len(fields))
+	for _, f := range fields {
+		c.W.WriteBulkString(f.Name)
+		c.W.WriteBulk(f.Value)
+	}
+	return true
+}
+
+// hlen implements HLEN key.
+func hlen(c *Client, args [][]byte) bool {
+	n, err := c.DB.HLen(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// hexists implements HEXISTS key field.
+func hexists(c *Client, args [][]byte) bool {
+	exists, err := c.DB.HExists(string(args[1]), string(args[2]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeBoolInteger(c, exists)
+	return true
+}
+
+// hincrby implements HINCRBY key field increment.
+func hincrby(c *Client, args [][]byte) bool {
+	incr, ok := parseInt(args[3])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+
+	n, err := c.DB.HIncrBy(string(args[1]), string(args[2]), incr)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(n)
+	return true
+}
+
+// hincrbyfloat implements HINCRBYFLOAT key field increment.
+func hincrbyfloat(c *Client, args [][]byte) bool {
+	incr, ok := parseFloat(args[3])
+	if !ok {
+		return fail(c, errNotFloat)
+	}
+
+	val, deadline, err := c.DB.HIncrByFloat(string(args[1]), string(args[2]), incr)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	// replaying the addition could round differently, the result can't;
+	// HSET drops the deadline of the field, so it is propagated again
+	cmds := [][][]byte{{[]byte("HSET"), args[1], args[2], val}}
+	if deadline != 0 {
+		cmds = append(cmds, hpexpireatCommand(args[1], time.Unix(0, deadline), []string{string(args[2])}))
+	}
+	c.propagateAs(cmds...)
+
+	c.W.WriteBulk(val)
+	return true
+}
+
+// maxFieldExpireMs bounds the deadlines of hash fields, like Redis does.
+const maxFieldExpireMs = 1 << 48
+
+const errFieldExpireTime = "ERR invalid expire time, must be >= 0 and <= 2^48"
+
+// parseFields parses the FIELDS numfields field [field ...] block ending
+// the hash field expiration commands, starting at args[i].
+func parseFields(args [][]byte, i int) ([]string, string) {
+	if i >= len(args) || strings.ToUpper(string(args[i])) != "FIELDS" {
+		return nil, "ERR Mandatory argument FIELDS is missing or not at the right position"
+	}
+	if i+1 >= len(args) {
+		return nil, errSyntax
+	}
+	n, ok := parseInt(args[i+1])
+	if !ok || n <= 0 {
+		return nil, "ERR Parameter `numFields` should be greater than 0"
+	}
+	if n != int64(len(args)-i-2) {
+		return nil, "ERR The `numfields` parameter must match the number of arguments"
+	}
+
+	return argStrings(args[i+2:]), ""
+}
+
+// hexpireGeneric implements HEXPIRE, HPEXPIRE, HEXPIREAT and HPEXPIREAT:
+// key time [NX | XX | GT | LT] FIELDS numfields field [field ...],
+// time being in unit, relative to now unless absolute.
+func hexpireGeneric(c *Client, args [][]byte, unit time.Duration, absolute bool) bool {
+	n, ok := parseInt(args[2])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+	perMs := int64(unit / time.Millisecond)
+	if n < 0 || n > maxFieldExpireMs/perMs {
+		return fail(c, errFieldExpireTime)
+	}
+
+	var flags database.ExpireFlags
+	i := 3
+	switch strings.ToUpper(string(args[i])) {
+	case "NX":
+		flags.NX = true
+	case "XX":
+		flags.XX = true
+	case "GT":
+		flags.GT = true
+	case "LT":
+		flags.LT = true
+	default:
+		i--
+	}
+	fields, msg := parseFields(args, i+1)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	ms := n * perMs
+	if !absolute {
+		ms += time.Now().UnixMilli()
+	}
+	if ms > maxFieldExpireMs {
+		return fail(c, errFieldExpireTime)
+	}
+	at := time.UnixMilli(ms)
+
+	results, err := c.DB.HExpire(string(args[1]), at, flags, fields)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	// the AOF gets the absolute deadline, so a replay doesn't push it back
+	var updated, deleted []string
+	c.W.WriteArray(len(results))
+	for i, r := range results {
+		switch r {
+		case database.FieldUpdated:
+			updated = append(updated, fields[i])
+		case database.FieldExpiredNow:
+			deleted = append(deleted, fields[i])
+		}
+		c.W.WriteInteger(int64(r))
+	}
+
+	var cmds [][][]byte
+	if len(updated) > 0 {
+		cmds = append(cmds, hpexpireatCommand(args[1], at, updated))
+	}
+	if len(deleted) > 0 {
+		hdel := [][]byte{[]byte("HDEL"), args[1]}
+		for _, f := range deleted {
+			hdel = append(hdel, []byte(f))
+		}
+		cmds = append(cmds, hdel)
+	}
+	c.propagateAs(cmds...)
+	return true
+}
+
+// hpexpireatCommand builds HPEXPIREAT key at FIELDS numfields fields...
+func hpexpireatCommand(key []byte, at time.Time, fields []string) [][]byte {
+	cmd := [][]byte{
+		[]byte("HPEXPIREAT"), key,
+		strconv.AppendInt(nil, at.UnixMilli(), 10),
+		[]byte("FIELDS"), strconv.AppendInt(nil, int64(len(fields)), 10),
+	}
+	for _, f := range fields {
+		cmd = append(cmd, []byte(f))
+	}
+	return cmd
+}
+
+func hexpire(c *Client, args [][]byte) bool {
+	return hexpireGeneric(c, args, time.Second, false)
+}
+
+func hpexpire(c *Client, args [][]byte) bool {
+	return hexpireGeneric(c, args, time.Millisecond, false)
+}
+
+func hexpireat(c *Client, args [][]byte) bool {
+	return hexpireGeneric(c, args, time.Second, true)
+}
+
+func hpexpireat(c *Client, args [][]byte) bool {
+	return hexpireGeneric(c, args, time.Millisecond, true)
+}
+
+// httlGeneric implements HTTL, HPTTL, HEXPIRETIME and HPEXPIRETIME:
+// key FIELDS numfields field [field ...]. The deadlines are replied in unit,
+// as a time left unless absolute.
+func httlGeneric(c *Client, args [][]byte, unit time.Duration, absolute bool) bool {
+	fields, msg := parseFields(args, 2)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	deadlines, err := c.DB.HExpireTime(string(args[1]), fields)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	now := time.Now().UnixNano()
+	c.W.WriteArray(len(deadlines))
+	for _, d := range deadlines {
+		switch {
+		case d < 0:
+			c.W.WriteInteger(d)
+		case absolute:
+			c.W.WriteInteger(d / int64(unit))
+		case unit == time.Second:
+			// rounded to the closest second, like TTL
+			c.W.WriteInteger(((d-now)/int64(time.Millisecond) + 500) / 1000)
+		default:
+			c.W.WriteInteger((d - now) / int64(unit))
+		}
+	}
+	return true
+}
+
+func httl(c *Client, args [][]byte) bool {
+	return httlGeneric(c, args, time.Second, false)
+}
+
+func hpttl(c *Client, args [][]byte) bool {
+	return httlGeneric(c, args, time.Millisecond, false)
+}
+
+func hexpiretime(c *Client, args [][]byte) bool {
+	return httlGeneric(c, args, time.Second, true)
+}
+
+func hpexpiretime(c *Client, args [][]byte) bool {
+	return httlGeneric(c, args, time.Millisecond, true)
+}
+
+// hpersist implements HPERSIST key FIELDS numfields field [field ...].
+func hpersist(c *Client, args [][]byte) bool {
+	fields, msg := parseFields(args, 2)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	results, err := c.DB.HPersist(string(args[1]), fields)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	c.W.WriteArray(len(results))
+	for _, r := range results {
+		c.W.WriteInteger(int64(r))
+	}
+	return true
+}
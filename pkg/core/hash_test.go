@@ -0,0 +1,293 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestHashCommands replays the examples of the Redis documentation for each hash command.
+func TestHashCommands(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// HSET, HGET
+		{"HSET myhash field1 Hello", ":1\r\n"},
+		{"HGET myhash field1", "$5\r\nHello\r\n"},
+		{"HSET myhash field2 Hi field3 World", ":2\r\n"},
+		{"HSET myhash field2 Hey field4 !", ":1\r\n"},
+		{"HGET myhash field2", "$3\r\nHey\r\n"},
+		{"HGET myhash nofield", "$-1\r\n"},
+		{"HSET myhash field1", "-ERR wrong number of arguments for 'hset' command\r\n"},
+
+		// HSETNX
+		{"HSETNX nx field Hello", ":1\r\n"},
+		{"HSETNX nx field World", ":0\r\n"},
+		{"HGET nx field", "$5\r\nHello\r\n"},
+
+		// HMGET
+		{"HMGET myhash field1 field2 nofield", "*3\r\n$5\r\nHello\r\n$3\r\nHey\r\n$-1\r\n"},
+		{"HMGET nohash field1", "*1\r\n$-1\r\n"},
+
+		// HLEN, HEXISTS
+		{"HLEN myhash", ":4\r\n"},
+		{"HLEN nohash", ":0\r\n"},
+		{"HEXISTS myhash field1", ":1\r\n"},
+		{"HEXISTS myhash field9", ":0\r\n"},
+
+		// HGETALL, HKEYS, HVALS
+		{"HSET pair f v", ":1\r\n"},
+		{"HGETALL pair", "*2\r\n$1\r\nf\r\n$1\r\nv\r\n"},
+		{"HKEYS pair", "*1\r\n$1\r\nf\r\n"},
+		{"HVALS pair", "*1\r\n$1\r\nv\r\n"},
+		{"HGETALL nohash", "*0\r\n"},
+
+		// HDEL
+		{"HDEL pair f", ":1\r\n"},
+		{"HDEL pair f", ":0\r\n"},
+		{"HGETALL pair", "*0\r\n"},
+		{"HDEL myhash field1 field2 nofield", ":2\r\n"},
+		{"HLEN myhash", ":2\r\n"},
+
+		// HINCRBY
+		{"HSET counter field 5", ":1\r\n"},
+		{"HINCRBY counter field 1", ":6\r\n"},
+		{"HINCRBY counter field -1", ":5\r\n"},
+		{"HINCRBY counter field -10", ":-5\r\n"},
+		{"HINCRBY counter new 3", ":3\r\n"},
+		{"HINCRBY counter field x", "-ERR value is not an integer or out of range\r\n"},
+		{"HSET counter big 9223372036854775807", ":1\r\n"},
+		{"HINCRBY counter big 1", "-ERR increment or decrement would overflow\r\n"},
+		{"HINCRBY myhash field3 1", "-ERR hash value is not an integer\r\n"},
+
+		// HINCRBYFLOAT
+		{"HSET float field 10.50", ":1\r\n"},
+		{"HINCRBYFLOAT float field 0.1", "$4\r\n10.6\r\n"},
+		{"HINCRBYFLOAT float field -5", "$3\r\n5.6\r\n"},
+		{"HSET float exp 5.0e3", ":1\r\n"},
+		{"HINCRBYFLOAT float exp 2.0e2", "$4\r\n5200\r\n"},
+		{"HINCRBYFLOAT myhash field3 1", "-ERR hash value is not a float\r\n"},
+		{"HINCRBYFLOAT float field inf", "-ERR increment would produce NaN or Infinity\r\n"},
+
+		// errors
+		{"SET str value", "+OK\r\n"},
+		{"HSET str f v", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"HGET str f", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"HGETALL str", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"HDEL str f", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestHashRESP3 checks that HGETALL replies with a map in RESP3.
+func TestHashRESP3(t *testing.T) {
+	tc := newTestClient()
+	tc.do("HELLO 3")
+	tc.do("HSET h f v")
+
+	if got, want := tc.do("HGETALL h"), "%1\r\n$1\r\nf\r\n$1\r\nv\r\n"; got != want {
+		t.Errorf("HGETALL: got %q, want %q", got, want)
+	}
+}
+
+// TestHIncrByFloatPropagation checks that HINCRBYFLOAT reaches the AOF as the
+// HSET of its result.
+func TestHIncrByFloatPropagation(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+
+	tc.do("HINCRBYFLOAT h f 0.1")
+	tc.do("HINCRBYFLOAT h f x")
+
+	if len(aof) != 1 {
+		t.Fatalf("Expected 1 propagated command, got %q", aof)
+	}
+	if got, want := string(bytes.Join(aof[0], []byte(" "))), "HSET h f 0.1"; got != want {
+		t.Errorf("Propagated %q, want %q", got, want)
+	}
+}
+
+// TestHashFieldExpiration replays the examples of the Redis documentation for
+// the hash field expiration commands.
+func TestHashFieldExpiration(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// HEXPIRE
+		{"HEXPIRE no-key 20 NX FIELDS 2 field1 field2", "*2\r\n:-2\r\n:-2\r\n"},
+		{"HSET mykey field1 hello field2 world", ":2\r\n"},
+		{"HEXPIRE mykey 10 FIELDS 3 field1 field2 field3", "*3\r\n:1\r\n:1\r\n:-2\r\n"},
+		{"HEXPIRE mykey 20 NX FIELDS 1 field1", "*1\r\n:0\r\n"},
+		{"HEXPIRE mykey 5 GT FIELDS 1 field1", "*1\r\n:0\r\n"},
+		{"HEXPIRE mykey 300 GT FIELDS 1 field1", "*1\r\n:1\r\n"},
+
+		// HTTL, HPERSIST
+		{"HTTL mykey FIELDS 3 field1 field2 field3", "*3\r\n:300\r\n:10\r\n:-2\r\n"},
+		{"HPERSIST mykey FIELDS 2 field2 field3", "*2\r\n:1\r\n:-2\r\n"},
+		{"HPERSIST mykey FIELDS 1 field2", "*1\r\n:-1\r\n"},
+		{"HTTL mykey FIELDS 2 field1 field2", "*2\r\n:300\r\n:-1\r\n"},
+		{"HEXPIRE mykey 10 XX FIELDS 1 field2", "*1\r\n:0\r\n"},
+		{"HTTL no-key FIELDS 1 field1", "*1\r\n:-2\r\n"},
+
+		// HSET drops the deadline, HINCRBY keeps it
+		{"HSET mykey field1 again", ":0\r\n"},
+		{"HTTL mykey FIELDS 1 field1", "*1\r\n:-1\r\n"},
+		{"HSET mykey n 1", ":1\r\n"},
+		{"HEXPIRE mykey 100 FIELDS 1 n", "*1\r\n:1\r\n"},
+		{"HINCRBY mykey n 1", ":2\r\n"},
+		{"HTTL mykey FIELDS 1 n", "*1\r\n:100\r\n"},
+
+		// a deadline in the past deletes the field, and the key with its last one
+		{"HEXPIREAT mykey 1 FIELDS 1 field1", "*1\r\n:2\r\n"},
+		{"HEXPIRE mykey 0 FIELDS 2 field2 n", "*2\r\n:2\r\n:2\r\n"},
+		{"HLEN mykey", ":0\r\n"},
+
+		// errors
+		{"HEXPIRE mykey 10 FIELDS 2 field1", "-ERR The `numfields` parameter must match the number of arguments\r\n"},
+		{"HEXPIRE mykey 10 FIELDS 0 field1", "-ERR Parameter `numFields` should be greater than 0\r\n"},
+		{"HEXPIRE mykey 10 NX XX FIELDS 1 field1", "-ERR Mandatory argument FIELDS is missing or not at the right position\r\n"},
+		{"HEXPIRE mykey -1 FIELDS 1 field1", "-ERR invalid expire time, must be >= 0 and <= 2^48\r\n"},
+		{"HPEXPIREAT mykey 281474976710657 FIELDS 1 field1", "-ERR invalid expire time, must be >= 0 and <= 2^48\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestExpiredHashFields checks that expired fields are hidden from every reader.
+func TestExpiredHashFields(t *testing.T) {
+	tc := newTestClient()
+	tc.do("HSET h keep 1 drop 2")
+	tc.do("HPEXPIRE h 10 FIELDS 1 drop")
+	time.Sleep(20 * time.Millisecond)
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"HGET h drop", "$-1\r\n"},
+		{"HMGET h drop", "*1\r\n$-1\r\n"},
+		{"HEXISTS h drop", ":0\r\n"},
+		{"HGETALL h", "*2\r\n$4\r\nkeep\r\n$1\r\n1\r\n"},
+		{"HLEN h", ":1\r\n"},
+		{"HTTL h FIELDS 1 drop", "*1\r\n:-2\r\n"},
+		{"HSETNX h drop 3", ":1\r\n"},
+	}
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestHashWithoutLiveFields checks that a hash whose fields all expired is
+// a missing key, before anything reclaims it.
+func TestHashWithoutLiveFields(t *testing.T) {
+	tc := newTestClient()
+	tc.do("HSET h a 1 b 2")
+	tc.do("HPEXPIRE h 10 FIELDS 2 a b")
+	time.Sleep(20 * time.Millisecond)
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"EXISTS h", ":0\r\n"},
+		{"TYPE h", "+none\r\n"},
+		{"KEYS *", "*0\r\n"},
+		{"SCAN 0", "*2\r\n$1\r\n0\r\n*0\r\n"},
+		{"RANDOMKEY", "$-1\r\n"},
+		{"HLEN h", ":0\r\n"},
+		{"SET h v NX", "+OK\r\n"},
+		{"DBSIZE", ":1\r\n"},
+	}
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestLegacyHSet replays the HSET key field value ttl lines older versions
+// wrote to the AOF.
+func TestLegacyHSet(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+	tc.replay(t, "HSET h f v 10s\nHSET h g w\n")
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"HGET h f", "$1\r\nv\r\n"},
+		{"HGET h g", "$1\r\nw\r\n"},
+		{"HTTL h FIELDS 2 f g", "*2\r\n:10\r\n:-1\r\n"},
+		{"TTL h", ":-1\r\n"},
+		{"HSET h f v 0s", "-ERR wrong number of arguments for 'hset' command\r\n"},
+	}
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+
+	if len(aof) != 3 || string(aof[0][0]) != "HSET" || string(aof[1][0]) != "HPEXPIREAT" {
+		t.Errorf("Expected HSET, HPEXPIREAT and HSET to be propagated, got %q", aof)
+	}
+}
+
+// TestHashExpirationPropagation checks that replaying the AOF restores the
+// same deadlines, however long after the commands ran.
+func TestHashExpirationPropagation(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+
+	tc.do("HSET h a 1 b 2 c 3.5 d 4")
+	tc.do("HEXPIRE h 100 FIELDS 2 a c")
+	tc.do("HEXPIRE h 100 NX FIELDS 1 a")
+	tc.do("HPEXPIRE h 0 FIELDS 1 d")
+	tc.do("HINCRBYFLOAT h c 1")
+	tc.do("HPERSIST h FIELDS 1 a")
+
+	for _, cmd := range aof {
+		if name := string(cmd[0]); name == "HEXPIRE" || name == "HPEXPIRE" {
+			t.Errorf("%s should be propagated with an absolute deadline, got %q", name, cmd)
+		}
+	}
+
+	replay := newTestClient()
+	for _, cmd := range aof {
+		parts := make([]string, len(cmd))
+		for i, arg := range cmd {
+			parts[i] = string(arg)
+		}
+		replay.doArgs(parts...)
+	}
+
+	for _, cmd := range []string{"HMGET h a b c d", "HPEXPIRETIME h FIELDS 4 a b c d"} {
+		if want, got := tc.do(cmd), replay.do(cmd); got != want {
+			t.Errorf("%s after replay:\n got %q\nwant %q", cmd, got, want)
+		}
+	}
+}
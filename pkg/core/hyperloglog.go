@@ -0,0 +1,33 @@
+package core
+
+// pfadd implements PFADD key [element [element ...]].
+func pfadd(c *Client, args [][]byte) bool {
+	changed, err := c.DB.PFAdd(string(args[1]), args[2:])
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	if !changed {
+		c.propagateAs()
+	}
+	writeBoolInteger(c, changed)
+	return true
+}
+
+// pfcount implements PFCOUNT key [key ...].
+func pfcount(c *Client, args [][]byte) bool {
+	n, err := c.DB.PFCount(argStrings(args[1:]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(n)
+	return true
+}
+
+// pfmerge implements PFMERGE destkey [sourcekey [sourcekey ...]].
+func pfmerge(c *Client, args [][]byte) bool {
+	if err := c.DB.PFMerge(string(args[1]), argStrings(args[2:])); err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteOK()
+	return true
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestHyperLogLogCommands replays the examples of the Redis documentation for
+// each HyperLogLog command.
+func TestHyperLogLogCommands(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// PFADD, PFCOUNT
+		{"PFADD hll a b c d e f g", ":1\r\n"},
+		{"PFCOUNT hll", ":7\r\n"},
+		{"PFADD hll a b", ":0\r\n"},
+		{"PFADD some-other-hll 1 2 3", ":1\r\n"},
+		{"PFCOUNT hll some-other-hll", ":10\r\n"},
+		{"PFCOUNT hll nosuchkey", ":7\r\n"},
+		{"PFCOUNT nosuchkey", ":0\r\n"},
+		{"PFADD empty", ":1\r\n"},
+		{"PFADD empty", ":0\r\n"},
+		{"PFCOUNT empty", ":0\r\n"},
+
+		// PFMERGE
+		{"PFADD hll1 foo bar zap a", ":1\r\n"},
+		{"PFADD hll2 a b c foo", ":1\r\n"},
+		{"PFMERGE hll3 hll1 hll2", "+OK\r\n"},
+		{"PFCOUNT hll3", ":6\r\n"},
+		{"PFMERGE hll3 hll", "+OK\r\n"},
+		{"PFCOUNT hll3", ":10\r\n"},
+		{"PFMERGE hll4", "+OK\r\n"},
+		{"PFCOUNT hll4", ":0\r\n"},
+
+		// other types
+		{"SET str value", "+OK\r\n"},
+		{"PFADD str a", "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n"},
+		{"PFCOUNT hll str", "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n"},
+		{"PFMERGE hll str", "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n"},
+		{"LPUSH list a", ":1\r\n"},
+		{"PFADD list a", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"PFCOUNT list", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestHyperLogLogIsAString checks that a HyperLogLog can be read and copied
+// like any string.
+func TestHyperLogLogIsAString(t *testing.T) {
+	tc := newTestClient()
+	tc.do("PFADD hll a b c")
+
+	if got := tc.do("TYPE hll"); got != "+string\r\n" {
+		t.Errorf("TYPE: got %q", got)
+	}
+	got := tc.do("GET hll")
+	if !strings.HasPrefix(got, "$") || !strings.Contains(got, "HYLL") {
+		t.Fatalf("Expected GET to return the HyperLogLog, got %q", got)
+	}
+
+	// the value may contain CRLF: skip the length line and drop the final one
+	value := got[strings.Index(got, "\r\n")+2 : len(got)-2]
+	tc.doArgs("SET", "copy", value)
+	if got := tc.do("PFCOUNT copy"); got != ":3\r\n" {
+		t.Errorf("Expected SET to restore the HyperLogLog GET returned, got %q", got)
+	}
+}
+
+// TestPFAddPropagation checks that only the PFADDs changing the HyperLogLog
+// reach the AOF.
+func TestPFAddPropagation(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+
+	tc.do("PFADD hll a")
+	tc.do("PFADD hll a")
+	tc.do("PFCOUNT hll")
+	if len(aof) != 1 {
+		t.Errorf("Expected a single PFADD to be propagated, got %q", aof)
+	}
+}
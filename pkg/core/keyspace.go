@@ -0,0 +1,437 @@
+package core
+
+import (
+	"math"
+	"redis-lite/pkg/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// del implements DEL key [key ...] and UNLINK key [key ...], which only
+// differ in Redis: here the garbage collector frees the values of both off
+// the command path.
+func del(c *Client, args [][]byte) bool {
+	n := c.DB.Delete(argStrings(args[1:])...)
+	if n == 0 {
+		c.propagateAs()
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// exists implements EXISTS key [key ...] and TOUCH key [key ...]; keys don't
+// track their last access, so touching one is only checking it exists.
+func exists(c *Client, args [][]byte) bool {
+	c.W.WriteInteger(int64(c.DB.Exists(argStrings(args[1:])...)))
+	return true
+}
+
+// typeCmd implements TYPE key.
+func typeCmd(c *Client, args [][]byte) bool {
+	c.W.WriteSimpleString(c.DB.Type(string(args[1])))
+	return true
+}
+
+// rename implements RENAME key newkey.
+func rename(c *Client, args [][]byte) bool {
+	if _, err := c.DB.Rename(string(args[1]), string(args[2]), false); err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteOK()
+	return true
+}
+
+// renamenx implements RENAMENX key newkey.
+func renamenx(c *Client, args [][]byte) bool {
+	renamed, err := c.DB.Rename(string(args[1]), string(args[2]), true)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	if !renamed {
+		c.propagateAs()
+	}
+	writeBoolInteger(c, renamed)
+	return true
+}
+
+// copyCmd implements COPY source destination [DB destination-db] [REPLACE].
+func copyCmd(c *Client, args [][]byte) bool {
+	replace := false
+	to := c.db
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			if i+1 >= len(args) {
+				return fail(c, errSyntax)
+			}
+			i++
+			db, msg := c.dbIndex(args[i])
+			if msg != "" {
+				return fail(c, msg)
+			}
+			to = db
+		default:
+			return fail(c, errSyntax)
+		}
+	}
+
+	src, dst := string(args[1]), string(args[2])
+	if src == dst && to == c.db {
+		return fail(c, "ERR source and destination objects are the same")
+	}
+
+	copied := c.DB.Copy(src, c.DBs[to], dst, replace)
+	if !copied {
+		c.propagateAs()
+	}
+	writeBoolInteger(c, copied)
+	return true
+}
+
+// keys implements KEYS pattern.
+func keys(c *Client, args [][]byte) bool {
+	keys := c.DB.Keys(string(args[1]))
+	c.W.WriteArray(len(keys))
+	for _, key := range keys {
+		c.W.WriteBulkString(key)
+	}
+	return true
+}
+
+// randomkey implements RANDOMKEY.
+func randomkey(c *Client, args [][]byte) bool {
+	key, ok := c.DB.RandomKey()
+	if !ok {
+		c.W.WriteNull()
+		return true
+	}
+	c.W.WriteBulkString(key)
+	return true
+}
+
+// dbsize implements DBSIZE.
+func dbsize(c *Client, args [][]byte) bool {
+	c.W.WriteInteger(int64(c.DB.DBSize()))
+	return true
+}
+
+// flushdb implements FLUSHDB [ASYNC | SYNC].
+func flushdb(c *Client, args [][]byte) bool {
+	if !parseFlushMode(args) {
+		return fail(c, errSyntax)
+	}
+	c.DB.Flush()
+	c.W.WriteOK()
+	return true
+}
+
+// flushall implements FLUSHALL [ASYNC | SYNC].
+func flushall(c *Client, args [][]byte) bool {
+	if !parseFlushMode(args) {
+		return fail(c, errSyntax)
+	}
+	c.DBs.Flush()
+	c.W.WriteOK()
+	return true
+}
+
+// parseFlushMode checks the optional ASYNC or SYNC of the FLUSH commands,
+// which both get the same flush, see Store.Flush.
+func parseFlushMode(args [][]byte) bool {
+	if len(args) == 1 {
+		return true
+	}
+	mode := strings.ToUpper(string(args[1]))
+	return len(args) == 2 && (mode == "ASYNC" || mode == "SYNC")
+}
+
+// selectDB implements SELECT index.
+func selectDB(c *Client, args [][]byte) bool {
+	db, msg := c.dbIndex(args[1])
+	if msg != "" {
+		return fail(c, msg)
+	}
+	c.db, c.DB = db, c.DBs[db]
+	c.W.WriteOK()
+	return true
+}
+
+// swapdb implements SWAPDB index1 index2.
+func swapdb(c *Client, args [][]byte) bool {
+	i, ok := parseInt(args[1])
+	if !ok {
+		return fail(c, "ERR invalid first DB index")
+	}
+	j, ok := parseInt(args[2])
+	if !ok {
+		return fail(c, "ERR invalid second DB index")
+	}
+	if i < 0 || i >= int64(len(c.DBs)) || j < 0 || j >= int64(len(c.DBs)) {
+		return fail(c, "ERR DB index is out of range")
+	}
+	c.DBs.Swap(int(i), int(j))
+	c.W.WriteOK()
+	return true
+}
+
+// move implements MOVE key db.
+func move(c *Client, args [][]byte) bool {
+	db, msg := c.dbIndex(args[2])
+	if msg != "" {
+		return fail(c, msg)
+	}
+	if db == c.db {
+		return fail(c, "ERR source and destination objects are the same")
+	}
+
+	moved := c.DB.Move(string(args[1]), c.DBs[db])
+	if !moved {
+		c.propagateAs()
+	}
+	writeBoolInteger(c, moved)
+	return true
+}
+
+// dbIndex parses the number of a database, returning an error message when
+// it isn't one.
+func (c *Client) dbIndex(arg []byte) (int, string) {
+	db, ok := parseInt(arg)
+	if !ok {
+		return 0, errNotInteger
+	}
+	if db < 0 || db >= int64(len(c.DBs)) {
+		return 0, "ERR DB index is out of range"
+	}
+	return int(db), ""
+}
+
+// Deadlines are kept in int64 nanoseconds, which end in 2262, while Redis
+// keeps them in milliseconds. Like Redis, a time is only invalid when its
+// milliseconds overflow; a deadline past 2262 is cut to the last millisecond
+// that fits, so such keys expire then instead of in millions of years.
+const (
+	minDeadlineMilli = math.MinInt64 / int64(time.Millisecond)
+	maxDeadlineMilli = math.MaxInt64 / int64(time.Millisecond)
+)
+
+// toDeadline returns the deadline n units from now, or since the epoch when
+// absolute, cut to the millisecond and to the range of deadlines. It
+// reports false when the time overflows in milliseconds.
+func toDeadline(n int64, unit time.Duration, absolute bool) (time.Time, bool) {
+	per := int64(unit / time.Millisecond)
+	if n > math.MaxInt64/per || n < math.MinInt64/per {
+		return time.Time{}, false
+	}
+	ms := n * per
+	if !absolute {
+		now := time.Now().UnixMilli()
+		if ms > math.MaxInt64-now {
+			return time.Time{}, false
+		}
+		ms += now
+	}
+	return time.UnixMilli(min(max(ms, minDeadlineMilli), maxDeadlineMilli)), true
+}
+
+// parseDeadline parses the time argument of the EXPIRE family: a number of
+// units from now, or since the epoch when absolute. The deadline is cut to
+// the millisecond, the precision the AOF records it with.
+func parseDeadline(arg []byte, unit time.Duration, absolute bool, cmd string) (time.Time, string) {
+	n, ok := parseInt(arg)
+	if !ok {
+		return time.Time{}, errNotInteger
+	}
+	at, ok := toDeadline(n, unit, absolute)
+	if !ok {
+		return time.Time{}, "ERR invalid expire time in '" + cmd + "' command"
+	}
+	return at, ""
+}
+
+// expireGeneric implements EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT:
+// key time [NX | XX | GT | LT], time being in unit, relative to now unless absolute.
+func expireGeneric(c *Client, args [][]byte, unit time.Duration, absolute bool) bool {
+	var flags database.ExpireFlags
+	for _, arg := range args[3:] {
+		switch opt := strings.ToUpper(string(arg)); opt {
+		case "NX":
+			flags.NX = true
+		case "XX":
+			flags.XX = true
+		case "GT":
+			flags.GT = true
+		case "LT":
+			flags.LT = true
+		default:
+			return fail(c, "ERR Unsupported option "+string(arg))
+		}
+	}
+	switch {
+	case flags.NX && (flags.XX || flags.GT || flags.LT):
+		return fail(c, "ERR NX and XX, GT or LT options at the same time are not compatible")
+	case flags.GT && flags.LT:
+		return fail(c, "ERR GT and LT options at the same time are not compatible")
+	}
+
+	at, msg := parseDeadline(args[2], unit, absolute, strings.ToLower(string(args[0])))
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	if !c.DB.Expire(string(args[1]), at, flags) {
+		c.propagateAs()
+		c.W.WriteInteger(0)
+		return true
+	}
+
+	// the AOF gets the absolute deadline, so a replay doesn't push it back
+	c.propagateAs(expireCommand(args[1], at))
+	c.W.WriteInteger(1)
+	return true
+}
+
+// expireCommand builds what the AOF records for key getting the deadline at:
+// PEXPIREAT key at, or DEL key when at is already past.
+func expireCommand(key []byte, at time.Time) [][]byte {
+	if !at.After(time.Now()) {
+		return [][]byte{[]byte("DEL"), key}
+	}
+	return [][]byte{[]byte("PEXPIREAT"), key, strconv.AppendInt(nil, at.UnixMilli(), 10)}
+}
+
+func expire(c *Client, args [][]byte) bool {
+	return expireGeneric(c, args, time.Second, false)
+}
+
+func pexpire(c *Client, args [][]byte) bool {
+	return expireGeneric(c, args, time.Millisecond, false)
+}
+
+func expireat(c *Client, args [][]byte) bool {
+	return expireGeneric(c, args, time.Second, true)
+}
+
+func pexpireat(c *Client, args [][]byte) bool {
+	return expireGeneric(c, args, time.Millisecond, true)
+}
+
+// ttlGeneric implements TTL, PTTL, EXPIRETIME and PEXPIRETIME key: the deadline
+// of key in unit, as a time left unless absolute, -1 when the key has none and
+// -2 when it is missing.
+func ttlGeneric(c *Client, args [][]byte, unit time.Duration, absolute bool) bool {
+	deadline, ok := c.DB.ExpireTime(string(args[1]))
+	switch {
+	case !ok:
+		c.W.WriteInteger(-2)
+	case deadline == 0:
+		c.W.WriteInteger(-1)
+	case absolute:
+		c.W.WriteInteger(deadline / int64(unit))
+	case unit == time.Second:
+		// rounded to the closest second, like Redis
+		c.W.WriteInteger(((deadline-time.Now().UnixNano())/int64(time.Millisecond) + 500) / 1000)
+	default:
+		c.W.WriteInteger((deadline - time.Now().UnixNano()) / int64(unit))
+	}
+	return true
+}
+
+func ttl(c *Client, args [][]byte) bool {
+	return ttlGeneric(c, args, time.Second, false)
+}
+
+func pttl(c *Client, args [][]byte) bool {
+	return ttlGeneric(c, args, time.Millisecond, false)
+}
+
+func expiretime(c *Client, args [][]byte) bool {
+	return ttlGeneric(c, args, time.Second, true)
+}
+
+func pexpiretime(c *Client, args [][]byte) bool {
+	return ttlGeneric(c, args, time.Millisecond, true)
+}
+
+// persist implements PERSIST key.
+func persist(c *Client, args [][]byte) bool {
+	removed := c.DB.Persist(string(args[1]))
+	if !removed {
+		c.propagateAs()
+	}
+	writeBoolInteger(c, removed)
+	return true
+}
+
+// typeNames are the type names TYPE replies, which SCAN TYPE accepts.
+var typeNames = map[string]bool{
+	"string": true, "list": true, "set": true, "zset": true, "hash": true, "stream": true,
+}
+
+// parseScan parses the cursor at args[i] and the [MATCH pattern] [COUNT count]
+// options following it, plus [TYPE type] for SCAN.
+func parseScan(args [][]byte, i int, withType bool) (uint64, database.ScanArgs, string) {
+	scan := database.ScanArgs{Count: 10}
+	cursor, err := strconv.ParseUint(string(args[i]), 10, 64)
+	if err != nil {
+		return 0, scan, "ERR invalid cursor"
+	}
+
+	for i++; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return 0, scan, errSyntax
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			scan.Match = string(args[i+1])
+			if scan.Match == "*" {
+				scan.Match = ""
+			}
+		case "COUNT":
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				return 0, scan, errNotInteger
+			}
+			if n < 1 {
+				return 0, scan, errSyntax
+			}
+			scan.Count = int(min(n, math.MaxInt32))
+		case "TYPE":
+			if !withType {
+				return 0, scan, errSyntax
+			}
+			scan.Type = strings.ToLower(string(args[i+1]))
+			if !typeNames[scan.Type] {
+				return 0, scan, "ERR unknown type name '" + string(args[i+1]) + "'"
+			}
+		default:
+			return 0, scan, errSyntax
+		}
+	}
+	return cursor, scan, ""
+}
+
+// writeScanCursor starts the reply of the SCAN family: the cursor to continue
+// from, followed by an array of n elements the caller writes.
+func writeScanCursor(c *Client, cursor uint64, n int) {
+	c.W.WriteArray(2)
+	c.W.WriteBulk(strconv.AppendUint(nil, cursor, 10))
+	c.W.WriteArray(n)
+}
+
+// scan implements SCAN cursor [MATCH pattern] [COUNT count] [TYPE type].
+func scan(c *Client, args [][]byte) bool {
+	cursor, opts, msg := parseScan(args, 1, true)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	next, keys := c.DB.Scan(cursor, opts)
+	writeScanCursor(c, next, len(keys))
+	for _, key := range keys {
+		c.W.WriteBulkString(key)
+	}
+	return true
+}
@@ -0,0 +1,469 @@
+package core
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExpireCommands replays the examples of the Redis documentation for the
+// key expiration commands.
+func TestExpireCommands(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// EXPIRE, TTL
+		{"SET mykey Hello", "+OK\r\n"},
+		{"EXPIRE mykey 10", ":1\r\n"},
+		{"TTL mykey", ":10\r\n"},
+		{"SET mykey Hello", "+OK\r\n"},
+		{"TTL mykey", ":-1\r\n"},
+		{"EXPIRE mykey 10 XX", ":0\r\n"},
+		{"TTL mykey", ":-1\r\n"},
+		{"EXPIRE mykey 10 NX", ":1\r\n"},
+		{"TTL mykey", ":10\r\n"},
+		{"EXPIRE mykey 5 GT", ":0\r\n"},
+		{"EXPIRE mykey 20 GT", ":1\r\n"},
+		{"EXPIRE mykey 30 LT", ":0\r\n"},
+		{"TTL mykey", ":20\r\n"},
+		{"TTL nokey", ":-2\r\n"},
+		{"EXPIRE nokey 10", ":0\r\n"},
+
+		// PEXPIRE, PTTL
+		{"SET pkey Hello", "+OK\r\n"},
+		{"PEXPIRE pkey 1500000", ":1\r\n"},
+		{"TTL pkey", ":1500\r\n"},
+
+		// EXPIREAT, EXPIRETIME, PEXPIRETIME
+		{"SET atkey Hello", "+OK\r\n"},
+		{"EXPIREAT atkey 4000000000", ":1\r\n"},
+		{"EXPIRETIME atkey", ":4000000000\r\n"},
+		{"PEXPIRETIME atkey", ":4000000000000\r\n"},
+		{"PEXPIREAT atkey 4000000000123", ":1\r\n"},
+		{"PEXPIRETIME atkey", ":4000000000123\r\n"},
+		{"EXPIRETIME nokey", ":-2\r\n"},
+
+		// PERSIST
+		{"PERSIST mykey", ":1\r\n"},
+		{"TTL mykey", ":-1\r\n"},
+		{"PERSIST mykey", ":0\r\n"},
+		{"PERSIST nokey", ":0\r\n"},
+
+		// any type expires
+		{"HSET hash f v", ":1\r\n"},
+		{"EXPIRE hash 100", ":1\r\n"},
+		{"TTL hash", ":100\r\n"},
+
+		// a deadline in the past deletes the key
+		{"EXPIRE hash -1", ":1\r\n"},
+		{"HLEN hash", ":0\r\n"},
+		{"EXPIREAT mykey 1", ":1\r\n"},
+		{"GET mykey", "$-1\r\n"},
+
+		// errors
+		{"EXPIRE atkey 10 NX XX", "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n"},
+		{"EXPIRE atkey 10 GT LT", "-ERR GT and LT options at the same time are not compatible\r\n"},
+		{"EXPIRE atkey 10 SOON", "-ERR Unsupported option SOON\r\n"},
+		{"EXPIRE atkey ten", "-ERR value is not an integer or out of range\r\n"},
+		{"EXPIRE atkey 9223372036854775807", "-ERR invalid expire time in 'expire' command\r\n"},
+		{"EXPIREAT atkey -9223372036854775807", "-ERR invalid expire time in 'expireat' command\r\n"},
+		{"PEXPIRE atkey 9223372036854775807", "-ERR invalid expire time in 'pexpire' command\r\n"},
+
+		// deadlines past 2262 are cut to the last one that fits
+		{"SET far v", "+OK\r\n"},
+		{"EXPIRE far 100000000000", ":1\r\n"},
+		{"PEXPIRETIME far", ":9223372036854\r\n"},
+		{"PEXPIREAT far 9223372036854775807", ":1\r\n"},
+		{"PEXPIRETIME far", ":9223372036854\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestExpiredKeys checks that keys of every type vanish at their deadline.
+func TestExpiredKeys(t *testing.T) {
+	tc := newTestClient()
+	tc.do("SET str v")
+	tc.do("RPUSH list a")
+	tc.do("SADD set a")
+	tc.do("ZADD zset 1 a")
+	for _, key := range []string{"str", "list", "set", "zset"} {
+		tc.do("PEXPIRE " + key + " 10")
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	for _, cmd := range []string{"GET str", "LLEN list", "SCARD set", "ZCARD zset", "PTTL str"} {
+		if got := tc.do(cmd); got != "$-1\r\n" && got != ":0\r\n" && got != ":-2\r\n" {
+			t.Errorf("%s after the deadline: got %q", cmd, got)
+		}
+	}
+}
+
+// TestExpirePropagation checks that replaying the AOF restores the same
+// deadlines, however long after the commands ran.
+func TestExpirePropagation(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+
+	for _, cmd := range []string{
+		"SET a 1", "SET b 2", "SET c 3", "SET d 4", "SET e 5",
+		"EXPIRE a 100",
+		"EXPIRE a 200 LT",
+		"PEXPIRE b 0",
+		"EXPIRE c 100",
+		"PERSIST c",
+		"GETEX d EX 100",
+		"GETEX e PX 0",
+		"EXPIRE missing 100",
+	} {
+		tc.do(cmd)
+	}
+
+	for _, cmd := range aof {
+		switch name := string(cmd[0]); name {
+		case "EXPIRE", "PEXPIRE", "GETEX":
+			t.Errorf("%s should be propagated with an absolute deadline, got %q", name, cmd)
+		}
+	}
+
+	replay := newTestClient()
+	for _, cmd := range aof {
+		parts := make([]string, len(cmd))
+		for i, arg := range cmd {
+			parts[i] = string(arg)
+		}
+		replay.doArgs(parts...)
+	}
+
+	for _, key := range []string{"a", "b", "c", "d", "e", "missing"} {
+		for _, cmd := range []string{"GET " + key, "PEXPIRETIME " + key} {
+			if want, got := tc.do(cmd), replay.do(cmd); got != want {
+				t.Errorf("%s after replay:\n got %q\nwant %q", cmd, got, want)
+			}
+		}
+	}
+}
+
+// TestKeyspaceCommands replays the examples of the Redis documentation for
+// the keyspace commands.
+func TestKeyspaceCommands(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// DEL, UNLINK
+		{"SET key1 Hello", "+OK\r\n"},
+		{"SET key2 World", "+OK\r\n"},
+		{"DEL key1 key2 key3", ":2\r\n"},
+		{"SET key1 Hello", "+OK\r\n"},
+		{"SET key2 World", "+OK\r\n"},
+		{"UNLINK key1 key2 key3", ":2\r\n"},
+		{"DEL key1", ":0\r\n"},
+
+		// EXISTS, TOUCH
+		{"SET key1 Hello", "+OK\r\n"},
+		{"EXISTS key1", ":1\r\n"},
+		{"EXISTS nosuchkey", ":0\r\n"},
+		{"SET key2 World", "+OK\r\n"},
+		{"EXISTS key1 key2 nosuchkey", ":2\r\n"},
+		{"EXISTS key1 key1", ":2\r\n"},
+		{"TOUCH key1 key2 nosuchkey", ":2\r\n"},
+
+		// TYPE
+		{"SET key1 value", "+OK\r\n"},
+		{"LPUSH key2 value", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"LPUSH list value", ":1\r\n"},
+		{"SADD set value", ":1\r\n"},
+		{"HSET hash f v", ":1\r\n"},
+		{"ZADD zset 1 m", ":1\r\n"},
+		{"XADD stream 1-1 f v", "$3\r\n1-1\r\n"},
+		{"TYPE key1", "+string\r\n"},
+		{"TYPE list", "+list\r\n"},
+		{"TYPE set", "+set\r\n"},
+		{"TYPE hash", "+hash\r\n"},
+		{"TYPE zset", "+zset\r\n"},
+		{"TYPE stream", "+stream\r\n"},
+		{"TYPE nosuchkey", "+none\r\n"},
+
+		// RENAME keeps the TTL and overwrites the destination
+		{"SET mykey Hello", "+OK\r\n"},
+		{"EXPIRE mykey 100", ":1\r\n"},
+		{"RENAME mykey myotherkey", "+OK\r\n"},
+		{"GET myotherkey", "$5\r\nHello\r\n"},
+		{"TTL myotherkey", ":100\r\n"},
+		{"EXISTS mykey", ":0\r\n"},
+		{"RENAME myotherkey list", "+OK\r\n"},
+		{"TYPE list", "+string\r\n"},
+		{"RENAME list list", "+OK\r\n"},
+		{"RENAME nosuchkey other", "-ERR no such key\r\n"},
+
+		// RENAMENX
+		{"SET mykey Hello", "+OK\r\n"},
+		{"SET myotherkey World", "+OK\r\n"},
+		{"RENAMENX mykey myotherkey", ":0\r\n"},
+		{"GET myotherkey", "$5\r\nWorld\r\n"},
+		{"RENAMENX mykey newkey", ":1\r\n"},
+		{"RENAMENX newkey newkey", ":0\r\n"},
+		{"RENAMENX nosuchkey other", "-ERR no such key\r\n"},
+
+		// COPY
+		{"SET dolly sheep", "+OK\r\n"},
+		{"COPY dolly clone", ":1\r\n"},
+		{"GET clone", "$5\r\nsheep\r\n"},
+		{"COPY dolly clone", ":0\r\n"},
+		{"SET dolly goat", "+OK\r\n"},
+		{"COPY dolly clone REPLACE", ":1\r\n"},
+		{"GET clone", "$4\r\ngoat\r\n"},
+		{"COPY nosuchkey clone REPLACE", ":0\r\n"},
+		{"COPY dolly other DB 0", ":1\r\n"},
+		{"COPY dolly dolly DB 1", ":1\r\n"},
+		{"COPY dolly dolly DB 16", "-ERR DB index is out of range\r\n"},
+		{"COPY dolly dolly DB -1", "-ERR DB index is out of range\r\n"},
+		{"COPY dolly dolly", "-ERR source and destination objects are the same\r\n"},
+		{"COPY dolly dolly DB 0", "-ERR source and destination objects are the same\r\n"},
+		{"COPY dolly clone KEEP", "-ERR syntax error\r\n"},
+
+		// DBSIZE, RANDOMKEY, FLUSHALL, FLUSHDB
+		{"FLUSHALL", "+OK\r\n"},
+		{"DBSIZE", ":0\r\n"},
+		{"RANDOMKEY", "$-1\r\n"},
+		{"SET only one", "+OK\r\n"},
+		{"RANDOMKEY", "$4\r\nonly\r\n"},
+		{"DBSIZE", ":1\r\n"},
+		{"FLUSHDB ASYNC", "+OK\r\n"},
+		{"DBSIZE", ":0\r\n"},
+		{"FLUSHALL SYNC", "+OK\r\n"},
+		{"FLUSHALL LATER", "-ERR syntax error\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestDatabases checks that each connection works in its selected database.
+func TestDatabases(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"SET a zero", "+OK\r\n"},
+		{"SELECT 1", "+OK\r\n"},
+		{"GET a", "$-1\r\n"},
+		{"SET a one", "+OK\r\n"},
+		{"SET b one", "+OK\r\n"},
+		{"DBSIZE", ":2\r\n"},
+		{"SELECT 16", "-ERR DB index is out of range\r\n"},
+		{"SELECT x", "-ERR value is not an integer or out of range\r\n"},
+
+		// MOVE
+		{"MOVE b 0", ":1\r\n"},
+		{"MOVE a 0", ":0\r\n"},
+		{"MOVE missing 0", ":0\r\n"},
+		{"MOVE a 1", "-ERR source and destination objects are the same\r\n"},
+		{"DBSIZE", ":1\r\n"},
+		{"SELECT 0", "+OK\r\n"},
+		{"GET a", "$4\r\nzero\r\n"},
+		{"GET b", "$3\r\none\r\n"},
+
+		// SWAPDB
+		{"SWAPDB 0 1", "+OK\r\n"},
+		{"GET a", "$3\r\none\r\n"},
+		{"DBSIZE", ":1\r\n"},
+		{"SWAPDB 0 x", "-ERR invalid second DB index\r\n"},
+		{"SWAPDB x 0", "-ERR invalid first DB index\r\n"},
+		{"SWAPDB 0 16", "-ERR DB index is out of range\r\n"},
+
+		// FLUSHDB, FLUSHALL
+		{"FLUSHDB", "+OK\r\n"},
+		{"DBSIZE", ":0\r\n"},
+		{"SELECT 1", "+OK\r\n"},
+		{"DBSIZE", ":2\r\n"},
+		{"FLUSHALL", "+OK\r\n"},
+		{"DBSIZE", ":0\r\n"},
+	}
+
+	tc := newTestClient()
+	other := newTestClientOn(tc.DBs[0])
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+	if got := other.do("DBSIZE"); got != ":0\r\n" {
+		t.Errorf("Expected FLUSHALL to empty the database of other clients, got %q", got)
+	}
+}
+
+// TestDatabasePropagation checks that replaying the AOF restores every key
+// in the database it was written to.
+func TestDatabasePropagation(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	db := 0
+	tc.Propagate = func(at int, cmds ...[][]byte) {
+		// what the AOF does
+		if at != db {
+			aof = append(aof, [][]byte{[]byte("SELECT"), []byte(strconv.Itoa(at))})
+		}
+		for _, cmd := range cmds {
+			if strings.EqualFold(string(cmd[0]), "SELECT") {
+				at, _ = strconv.Atoi(string(cmd[1]))
+			}
+		}
+		db = at
+		aof = append(aof, cmds...)
+	}
+
+	for _, cmd := range []string{
+		"SET a 0",
+		"SELECT 2", "SET a 2", "SET b 2", "COPY b b DB 3",
+		"MULTI", "SET c 2", "SELECT 1", "SET c 1", "MOVE c 0", "EXEC",
+		"SET d 1",
+		"SELECT 4", "SET e 4", "SWAPDB 4 5",
+	} {
+		tc.do(cmd)
+	}
+
+	replay := newTestClient()
+	for _, cmd := range aof {
+		parts := make([]string, len(cmd))
+		for i, arg := range cmd {
+			parts[i] = string(arg)
+		}
+		replay.doArgs(parts...)
+	}
+
+	for db := range 6 {
+		tc.do("SELECT " + strconv.Itoa(db))
+		replay.do("SELECT " + strconv.Itoa(db))
+		for _, cmd := range []string{"DBSIZE", "MGET a b c d e"} {
+			if want, got := tc.do(cmd), replay.do(cmd); got != want {
+				t.Errorf("%s in database %d after replay:\n got %q\nwant %q", cmd, db, got, want)
+			}
+		}
+	}
+}
+
+// TestCopyIsDeep checks that modifying a copy leaves the original alone,
+// whatever the type of the value.
+func TestCopyIsDeep(t *testing.T) {
+	tc := newTestClient()
+	for _, cmd := range []string{
+		"RPUSH list a b", "SADD set a", "HSET hash f v", "ZADD zset 1 a",
+		"XADD stream 1-1 f v", "XGROUP CREATE stream g 0",
+		"PEXPIRE list 100000",
+	} {
+		tc.do(cmd)
+	}
+	for _, key := range []string{"list", "set", "hash", "zset", "stream"} {
+		tc.do("COPY " + key + " " + key + "-copy")
+	}
+	for _, cmd := range []string{
+		"RPUSH list-copy c", "SADD set-copy b", "HSET hash-copy f changed",
+		"ZADD zset-copy 2 a", "XADD stream-copy 2-1 f v", "XGROUP DESTROY stream-copy g",
+	} {
+		tc.do(cmd)
+	}
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"LRANGE list 0 -1", "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{"LRANGE list-copy 0 -1", "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"PTTL list-copy", tc.do("PTTL list")},
+		{"SCARD set", ":1\r\n"},
+		{"HGET hash f", "$1\r\nv\r\n"},
+		{"ZSCORE zset a", "$1\r\n1\r\n"},
+		{"XLEN stream", ":1\r\n"},
+		{"XGROUP CREATECONSUMER stream g alice", ":1\r\n"},
+	}
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestScanCommands(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"SCAN 0", "*2\r\n$1\r\n0\r\n*0\r\n"},
+		{"SET key1 v", "+OK\r\n"},
+		{"SADD key2 a", ":1\r\n"},
+		{"SCAN 0 MATCH *1", "*2\r\n$1\r\n0\r\n*1\r\n$4\r\nkey1\r\n"},
+		{"SCAN 0 TYPE set", "*2\r\n$1\r\n0\r\n*1\r\n$4\r\nkey2\r\n"},
+		{"SCAN 0 TYPE SET MATCH *1", "*2\r\n$1\r\n0\r\n*0\r\n"},
+		{"HSET hash field value", ":1\r\n"},
+		{"HSCAN hash 0", "*2\r\n$1\r\n0\r\n*2\r\n$5\r\nfield\r\n$5\r\nvalue\r\n"},
+		{"HSCAN hash 0 NOVALUES", "*2\r\n$1\r\n0\r\n*1\r\n$5\r\nfield\r\n"},
+		{"HSCAN hash 0 MATCH x*", "*2\r\n$1\r\n0\r\n*0\r\n"},
+		{"SSCAN key2 0", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\na\r\n"},
+		{"ZADD zset 1.5 m", ":1\r\n"},
+		{"ZSCAN zset 0 COUNT 5", "*2\r\n$1\r\n0\r\n*2\r\n$1\r\nm\r\n$3\r\n1.5\r\n"},
+		{"ZSCAN nosuchkey 0", "*2\r\n$1\r\n0\r\n*0\r\n"},
+
+		// errors
+		{"SCAN x", "-ERR invalid cursor\r\n"},
+		{"SCAN 0 COUNT 0", "-ERR syntax error\r\n"},
+		{"SCAN 0 COUNT x", "-ERR value is not an integer or out of range\r\n"},
+		{"SCAN 0 TYPE blob", "-ERR unknown type name 'blob'\r\n"},
+		{"SCAN 0 MATCH", "-ERR syntax error\r\n"},
+		{"SSCAN key2 0 TYPE set", "-ERR syntax error\r\n"},
+		{"SSCAN key1 0", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	tc := newTestClient()
+	tc.do("MSET firstname Jack lastname Stuntman age 35")
+	tc.do("PEXPIRE age 10")
+	time.Sleep(20 * time.Millisecond)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*name*", []string{"firstname", "lastname"}},
+		{"a??", nil},
+		{"*", []string{"firstname", "lastname"}},
+		{"[fx]irst*", []string{"firstname"}},
+		{"[^f]*", []string{"lastname"}},
+		{"first\\*", nil},
+	}
+	for _, tt := range tests {
+		got := tc.DB.Keys(tt.pattern)
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("KEYS %s: got %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+
+	if got, want := tc.do("KEYS first*"), "*1\r\n$9\r\nfirstname\r\n"; got != want {
+		t.Errorf("KEYS first*: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,421 @@
+package core
+
+import (
+	"math"
+	"redis-lite/pkg/database"
+	"strings"
+	"time"
+)
+
+const errNotPositive = "ERR value is out of range, must be positive"
+
+// lpush implements LPUSH key element [element ...].
+func lpush(c *Client, args [][]byte) bool {
+	if ttl, ok := legacyTTL(args, 4); ok {
+		return legacyLPush(c, args, time.Now().Add(ttl))
+	}
+	return pushGeneric(c, args, c.DB.LPush)
+}
+
+// legacyLPush implements the LPUSH key value ttl of older versions, see
+// legacyTTL: the list gets the deadline at when the push creates it.
+func legacyLPush(c *Client, args [][]byte, at time.Time) bool {
+	key := string(args[1])
+	var n int
+	var err error
+	c.DB.WithLocked([]string{key}, false, func(tx *database.Store) {
+		if n, err = tx.LPush(key, args[2]); err == nil && n == 1 {
+			tx.Expire(key, at, database.ExpireFlags{})
+		}
+	})
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	cmds := [][][]byte{{[]byte("LPUSH"), args[1], args[2]}}
+	if n == 1 {
+		cmds = append(cmds, expireCommand(args[1], at))
+	}
+	c.propagateAs(cmds...)
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// rpush implements RPUSH key element [element ...].
+func rpush(c *Client, args [][]byte) bool {
+	return pushGeneric(c, args, c.DB.RPush)
+}
+
+// lpushx implements LPUSHX key element [element ...].
+func lpushx(c *Client, args [][]byte) bool {
+	return pushGeneric(c, args, c.DB.LPushX)
+}
+
+// rpushx implements RPUSHX key element [element ...].
+func rpushx(c *Client, args [][]byte) bool {
+	return pushGeneric(c, args, c.DB.RPushX)
+}
+
+func pushGeneric(c *Client, args [][]byte, push func(key string, values ...[]byte) (int, error)) bool {
+	n, err := push(string(args[1]), args[2:]...)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// lpop implements LPOP key [count].
+func lpop(c *Client, args [][]byte) bool {
+	return popGeneric(c, args, c.DB.LPop)
+}
+
+// rpop implements RPOP key [count].
+func rpop(c *Client, args [][]byte) bool {
+	return popGeneric(c, args, c.DB.RPop)
+}
+
+func popGeneric(c *Client, args [][]byte, pop func(key string, count int) ([][]byte, error)) bool {
+	if len(args) > 3 {
+		return fail(c, "ERR wrong number of arguments for '"+strings.ToLower(string(args[0]))+"' command")
+	}
+
+	count := int64(1)
+	if len(args) == 3 {
+		var ok bool
+		if count, ok = parseInt(args[2]); !ok || count < 0 {
+			return fail(c, errNotPositive)
+		}
+	}
+
+	popped, err := pop(string(args[1]), int(count))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	// without a count the reply is a single element, with one always an array
+	if len(args) == 2 {
+		if len(popped) == 0 {
+			c.W.WriteNull()
+			return true
+		}
+		c.W.WriteBulk(popped[0])
+		return true
+	}
+	if popped == nil {
+		c.W.WriteNullArray()
+		return true
+	}
+	writeBulks(c, popped)
+	return true
+}
+
+// writeBulks writes values as an array of bulk strings.
+func writeBulks(c *Client, values [][]byte) {
+	c.W.WriteArray(len(values))
+	for _, v := range values {
+		c.W.WriteBulk(v)
+	}
+}
+
+// llen implements LLEN key.
+func llen(c *Client, args [][]byte) bool {
+	n, err := c.DB.LLen(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// lindex implements LINDEX key index.
+func lindex(c *Client, args [][]byte) bool {
+	index, ok := parseInt(args[2])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+
+	val, found, err := c.DB.LIndex(string(args[1]), int(index))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	if !found {
+		c.W.WriteNull()
+		return true
+	}
+	c.W.WriteBulk(val)
+	return true
+}
+
+// lset implements LSET key index element.
+func lset(c *Client, args [][]byte) bool {
+	index, ok := parseInt(args[2])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+
+	if err := c.DB.LSet(string(args[1]), int(index), args[3]); err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteOK()
+	return true
+}
+
+// linsert implements LINSERT key BEFORE|AFTER pivot element.
+func linsert(c *Client, args [][]byte) bool {
+	var before bool
+	switch strings.ToUpper(string(args[2])) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+	default:
+		return fail(c, errSyntax)
+	}
+
+	n, err := c.DB.LInsert(string(args[1]), before, args[3], args[4])
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// lrem implements LREM key count element.
+func lrem(c *Client, args [][]byte) bool {
+	count, ok := parseInt(args[2])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+
+	n, err := c.DB.LRem(string(args[1]), int(count), args[3])
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// parseIndexRange parses the start and stop arguments of LRANGE and LTRIM.
+func parseIndexRange(startArg, stopArg []byte) (int, int, bool) {
+	start, ok := parseInt(startArg)
+	if !ok {
+		return 0, 0, false
+	}
+	stop, ok := parseInt(stopArg)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(start), int(stop), true
+}
+
+// ltrim implements LTRIM key start stop.
+func ltrim(c *Client, args [][]byte) bool {
+	start, stop, ok := parseIndexRange(args[2], args[3])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+
+	if err := c.DB.LTrim(string(args[1]), start, stop); err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteOK()
+	return true
+}
+
+// lrange implements LRANGE key start stop.
+func lrange(c *Client, args [][]byte) bool {
+	start, stop, ok := parseIndexRange(args[2], args[3])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+
+	list, err := c.DB.LRange(string(args[1]), start, stop)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeBulks(c, list)
+	return true
+}
+
+// lpos implements LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len].
+func lpos(c *Client, args [][]byte) bool {
+	opts := database.LPosArgs{Rank: 1}
+	withCount := false
+
+	for i := 3; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return fail(c, errSyntax)
+		}
+		n, ok := parseInt(args[i+1])
+		if !ok {
+			return fail(c, errNotInteger)
+		}
+
+		switch strings.ToUpper(string(args[i])) {
+		case "RANK":
+			// LPos negates negative ranks, which the smallest int64 survives
+			if n == math.MinInt64 {
+				return fail(c, "ERR value is out of range, value must between -9223372036854775807 and 9223372036854775807")
+			}
+			if n == 0 {
+				return fail(c, "ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			opts.Rank = int(n)
+		case "COUNT":
+			if n < 0 {
+				return fail(c, "ERR COUNT can't be negative")
+			}
+			opts.Count = int(n)
+			withCount = true
+		case "MAXLEN":
+			if n < 0 {
+				return fail(c, "ERR MAXLEN can't be negative")
+			}
+			opts.MaxLen = int(n)
+		default:
+			return fail(c, errSyntax)
+		}
+	}
+	if !withCount {
+		opts.Count = 1
+	}
+
+	matches, err := c.DB.LPos(string(args[1]), args[2], opts)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	if withCount {
+		c.W.WriteArray(len(matches))
+		for _, index := range matches {
+			c.W.WriteInteger(int64(index))
+		}
+		return true
+	}
+	if len(matches) == 0 {
+		c.W.WriteNull()
+		return true
+	}
+	c.W.WriteInteger(int64(matches[0]))
+	return true
+}
+
+// lmove implements LMOVE source destination LEFT|RIGHT LEFT|RIGHT.
+func lmove(c *Client, args [][]byte) bool {
+	srcLeft, ok := parseDirection(args[3])
+	if !ok {
+		return fail(c, errSyntax)
+	}
+	dstLeft, ok := parseDirection(args[4])
+	if !ok {
+		return fail(c, errSyntax)
+	}
+
+	val, moved, err := c.DB.LMove(string(args[1]), string(args[2]), srcLeft, dstLeft)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	if !moved {
+		c.W.WriteNull()
+		return true
+	}
+	c.W.WriteBulk(val)
+	return true
+}
+
+// parseDirection parses the LEFT | RIGHT arguments of the move commands.
+func parseDirection(arg []byte) (left bool, ok bool) {
+	switch strings.ToUpper(string(arg)) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// blpop implements BLPOP key [key ...] timeout.
+func blpop(c *Client, args [][]byte) bool {
+	return bpopGeneric(c, args, true)
+}
+
+// brpop implements BRPOP key [key ...] timeout.
+func brpop(c *Client, args [][]byte) bool {
+	return bpopGeneric(c, args, false)
+}
+
+func bpopGeneric(c *Client, args [][]byte, left bool) bool {
+	timeout, msg := parseTimeoutSec(args[len(args)-1])
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	keys := make([]string, 0, len(args)-2)
+	for _, key := range args[1 : len(args)-1] {
+		keys = append(keys, string(key))
+	}
+
+	var key string
+	var val []byte
+	var failure error
+	blockPop(c, keys, left, timeout, func(w *database.Waiter) bool {
+		key, val, failure = c.DB.BPop(w, keys, left)
+		return failure != nil || key != ""
+	})
+
+	if failure != nil {
+		return fail(c, failure.Error())
+	}
+	if key == "" {
+		c.propagateAs()
+		c.W.WriteNullArray()
+		return true
+	}
+
+	// only the key that was served matters for the AOF, and replaying never waits
+	c.propagateAs([][]byte{args[0], []byte(key), []byte("0")})
+
+	c.W.WriteArray(2)
+	c.W.WriteBulkString(key)
+	c.W.WriteBulk(val)
+	return true
+}
+
+// blmove implements BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout.
+func blmove(c *Client, args [][]byte) bool {
+	srcLeft, ok := parseDirection(args[3])
+	if !ok {
+		return fail(c, errSyntax)
+	}
+	dstLeft, ok := parseDirection(args[4])
+	if !ok {
+		return fail(c, errSyntax)
+	}
+	timeout, msg := parseTimeoutSec(args[5])
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	src, dst := string(args[1]), string(args[2])
+
+	var val []byte
+	var moved bool
+	var failure error
+	blockPop(c, []string{src}, srcLeft, timeout, func(w *database.Waiter) bool {
+		val, moved, failure = c.DB.BMove(w, src, dst, srcLeft, dstLeft)
+		return failure != nil || moved
+	})
+
+	if failure != nil {
+		return fail(c, failure.Error())
+	}
+	if !moved {
+		c.propagateAs()
+		c.W.WriteNull()
+		return true
+	}
+
+	c.propagateAs([][]byte{args[0], args[1], args[2], args[3], args[4], []byte("0")})
+	c.W.WriteBulk(val)
+	return true
+}
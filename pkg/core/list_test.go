@@ -0,0 +1,310 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"redis-lite/pkg/database"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestListCommands replays the examples of the Redis documentation for each list command.
+func TestListCommands(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// LRANGE
+		{"RPUSH mylist one two three", ":3\r\n"},
+		{"LRANGE mylist 0 0", "*1\r\n$3\r\none\r\n"},
+		{"LRANGE mylist -3 2", "*3\r\n$3\r\none\r\n$3\r\ntwo\r\n$5\r\nthree\r\n"},
+		{"LRANGE mylist -100 100", "*3\r\n$3\r\none\r\n$3\r\ntwo\r\n$5\r\nthree\r\n"},
+		{"LRANGE mylist 5 10", "*0\r\n"},
+
+		// LPUSH, LPUSHX, RPUSHX, LLEN
+		{"LPUSH pushed a b c", ":3\r\n"},
+		{"LRANGE pushed 0 -1", "*3\r\n$1\r\nc\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"LPUSHX pushed d", ":4\r\n"},
+		{"LPUSHX nolist d", ":0\r\n"},
+		{"RPUSHX pushed z", ":5\r\n"},
+		{"RPUSHX nolist z", ":0\r\n"},
+		{"LLEN pushed", ":5\r\n"},
+		{"LLEN nolist", ":0\r\n"},
+
+		// LINDEX
+		{"LINDEX mylist 0", "$3\r\none\r\n"},
+		{"LINDEX mylist -1", "$5\r\nthree\r\n"},
+		{"LINDEX mylist 3", "$-1\r\n"},
+
+		// LSET
+		{"LSET mylist 0 four", "+OK\r\n"},
+		{"LSET mylist -2 five", "+OK\r\n"},
+		{"LRANGE mylist 0 -1", "*3\r\n$4\r\nfour\r\n$4\r\nfive\r\n$5\r\nthree\r\n"},
+		{"LSET mylist 3 six", "-ERR index out of range\r\n"},
+		{"LSET nolist 0 six", "-ERR no such key\r\n"},
+
+		// LINSERT
+		{"RPUSH greeting Hello World", ":2\r\n"},
+		{"LINSERT greeting BEFORE World There", ":3\r\n"},
+		{"LINSERT greeting AFTER World !", ":4\r\n"},
+		{"LRANGE greeting 0 -1", "*4\r\n$5\r\nHello\r\n$5\r\nThere\r\n$5\r\nWorld\r\n$1\r\n!\r\n"},
+		{"LINSERT greeting BEFORE nope x", ":-1\r\n"},
+		{"LINSERT nolist BEFORE World x", ":0\r\n"},
+
+		// LREM
+		{"RPUSH rem hello hello foo hello", ":4\r\n"},
+		{"LREM rem -2 hello", ":2\r\n"},
+		{"LRANGE rem 0 -1", "*2\r\n$5\r\nhello\r\n$3\r\nfoo\r\n"},
+		{"LREM rem 0 foo", ":1\r\n"},
+		{"LREM rem 1 hello", ":1\r\n"},
+		{"LLEN rem", ":0\r\n"},
+
+		// LTRIM
+		{"RPUSH trim one two three", ":3\r\n"},
+		{"LTRIM trim 1 -1", "+OK\r\n"},
+		{"LRANGE trim 0 -1", "*2\r\n$3\r\ntwo\r\n$5\r\nthree\r\n"},
+		{"LTRIM trim 5 10", "+OK\r\n"},
+		{"LLEN trim", ":0\r\n"},
+
+		// LPOS
+		{"RPUSH pos a b c d 1 2 3 4 3 3 3", ":11\r\n"},
+		{"LPOS pos 3", ":6\r\n"},
+		{"LPOS pos 3 COUNT 0 RANK 2", "*3\r\n:8\r\n:9\r\n:10\r\n"},
+		{"LPOS pos 3 RANK -1 COUNT 2", "*2\r\n:10\r\n:9\r\n"},
+		{"LPOS pos 3 COUNT 0 MAXLEN 7", "*1\r\n:6\r\n"},
+		{"LPOS pos x", "$-1\r\n"},
+		{"LPOS pos x COUNT 2", "*0\r\n"},
+		{"LPOS pos 3 RANK 0", "-ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list\r\n"},
+		{"LPOS pos 3 RANK -9223372036854775808", "-ERR value is out of range, value must between -9223372036854775807 and 9223372036854775807\r\n"},
+		{"LPOS pos 3 RANK -9223372036854775807", "$-1\r\n"},
+
+		// LMOVE
+		{"RPUSH move one two three", ":3\r\n"},
+		{"LMOVE move other RIGHT LEFT", "$5\r\nthree\r\n"},
+		{"LMOVE move other LEFT RIGHT", "$3\r\none\r\n"},
+		{"LRANGE move 0 -1", "*1\r\n$3\r\ntwo\r\n"},
+		{"LRANGE other 0 -1", "*2\r\n$5\r\nthree\r\n$3\r\none\r\n"},
+		{"LMOVE other other LEFT RIGHT", "$5\r\nthree\r\n"},
+		{"LRANGE other 0 -1", "*2\r\n$3\r\none\r\n$5\r\nthree\r\n"},
+
+		// LPOP, RPOP
+		{"RPUSH pop one two three four five", ":5\r\n"},
+		{"LPOP pop", "$3\r\none\r\n"},
+		{"RPOP pop", "$4\r\nfive\r\n"},
+		{"RPOP pop 2", "*2\r\n$4\r\nfour\r\n$5\r\nthree\r\n"},
+		{"LPOP pop 5", "*1\r\n$3\r\ntwo\r\n"},
+		{"LPOP pop", "$-1\r\n"},
+		{"LPOP pop 1", "*-1\r\n"},
+
+		// errors
+		{"SET str value", "+OK\r\n"},
+		{"RPUSH str x", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"LRANGE str 0 -1", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"LPOP mylist -1", "-ERR value is out of range, must be positive\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// blockingClient starts cmd on a new client of db in the background.
+// The reply arrives on the returned channel, cancel unblocks the client.
+func blockingClient(t *testing.T, db *database.Store, cmd string) (<-chan string, context.CancelFunc) {
+	t.Helper()
+
+	tc := newTestClientOn(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	tc.Ctx = ctx
+
+	reply := make(chan string, 1)
+	go func() {
+		reply <- tc.do(cmd)
+	}()
+
+	// give the client time to park, so the waiters queue up in a known order
+	time.Sleep(20 * time.Millisecond)
+	return reply, cancel
+}
+
+func waitReply(t *testing.T, reply <-chan string, what string) string {
+	t.Helper()
+
+	select {
+	case got := <-reply:
+		return got
+	case <-time.After(time.Second):
+		t.Fatalf("%s is still blocked", what)
+		return ""
+	}
+}
+
+// TestLegacyLPush replays the LPUSH key value ttl lines older versions wrote
+// to the AOF.
+func TestLegacyLPush(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+	tc.replay(t, "LPUSH list a 10s\nLPUSH list b 10s\nLPUSH other a b\n")
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"LRANGE list 0 -1", "*2\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"TTL list", ":10\r\n"},
+		{"LRANGE other 0 -1", "*2\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"TTL other", ":-1\r\n"},
+	}
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+
+	// the deadline is only set by the push creating the list
+	if len(aof) != 4 || string(aof[1][0]) != "PEXPIREAT" || len(aof[2]) != 3 {
+		t.Errorf("Expected LPUSH, PEXPIREAT, LPUSH and LPUSH to be propagated, got %q", aof)
+	}
+}
+
+func TestBlockingPops(t *testing.T) {
+	db := database.NewStore()
+	writer := newTestClientOn(db)
+
+	reply, _ := blockingClient(t, db, "BLPOP a b 0")
+	writer.do("LPUSH b x")
+	if got, want := waitReply(t, reply, "BLPOP"), "*2\r\n$1\r\nb\r\n$1\r\nx\r\n"; got != want {
+		t.Errorf("BLPOP: got %q, want %q", got, want)
+	}
+	if got := writer.do("LRANGE b 0 -1"); got != "*0\r\n" {
+		t.Errorf("The served element should be gone, got %q", got)
+	}
+
+	reply, _ = blockingClient(t, db, "BLMOVE src dst RIGHT LEFT 0")
+	writer.do("LPUSH src y")
+	if got, want := waitReply(t, reply, "BLMOVE"), "$1\r\ny\r\n"; got != want {
+		t.Errorf("BLMOVE: got %q, want %q", got, want)
+	}
+	if got, want := writer.do("LPOP dst"), "$1\r\ny\r\n"; got != want {
+		t.Errorf("BLMOVE should push to the destination, got %q", got)
+	}
+
+	reply, cancel := blockingClient(t, db, "BRPOP a 0")
+	cancel()
+	if got := waitReply(t, reply, "cancelled BRPOP"); got != "*-1\r\n" {
+		t.Errorf("A cancelled BRPOP should reply with a null array, got %q", got)
+	}
+
+	tc := newTestClientOn(db)
+	tc.Ctx = context.Background()
+	start := time.Now()
+	if got := tc.do("BRPOP a 0.03"); got != "*-1\r\n" {
+		t.Errorf("BRPOP should time out with a null array, got %q", got)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("BRPOP with a 30ms timeout returned after %v", elapsed)
+	}
+}
+
+func TestBlockingPopsAreFIFO(t *testing.T) {
+	db := database.NewStore()
+	writer := newTestClientOn(db)
+
+	first, _ := blockingClient(t, db, "BLPOP q 0")
+	second, _ := blockingClient(t, db, "BLPOP other q 0")
+	third, cancelThird := blockingClient(t, db, "BLPOP q 0")
+
+	// every push is handed to the first in line
+	for i, next := range []<-chan string{first, second} {
+		writer.do("LPUSH q x")
+		if got := waitReply(t, next, "the next client"); got != "*2\r\n$1\r\nq\r\n$1\r\nx\r\n" {
+			t.Errorf("Client %d should get the element, got %q", i+1, got)
+		}
+		select {
+		case got := <-third:
+			t.Fatalf("The third client should still be waiting, got %q", got)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	// a waiter leaving the queue hands its turn to the next one
+	cancelThird()
+	waitReply(t, third, "the cancelled client")
+	fourth, _ := blockingClient(t, db, "BLPOP q 0")
+	writer.do("LPUSH q 3")
+	if got := waitReply(t, fourth, "the fourth client"); got != "*2\r\n$1\r\nq\r\n$1\r\n3\r\n" {
+		t.Errorf("The fourth client should get the element, got %q", got)
+	}
+}
+
+// TestBlockingPopHandOff checks that an element pushed while clients wait
+// goes to them, even when another client pops right after the push.
+func TestBlockingPopHandOff(t *testing.T) {
+	db := database.NewStore()
+	writer := newTestClientOn(db)
+
+	reply, _ := blockingClient(t, db, "BLPOP q 0")
+	writer.do("RPUSH q x y")
+	if got := writer.do("LPOP q"); got != "$1\r\ny\r\n" {
+		t.Errorf("LPOP should get the element left, got %q", got)
+	}
+	if got := waitReply(t, reply, "BLPOP"); got != "*2\r\n$1\r\nq\r\n$1\r\nx\r\n" {
+		t.Errorf("BLPOP should get the first element, got %q", got)
+	}
+
+	// an element moved for a client lands in the destination even if the
+	// client goes away before it is done
+	reply, cancel := blockingClient(t, db, "BLMOVE src dst LEFT LEFT 0")
+	writer.do("RPUSH src z")
+	cancel()
+	if got := waitReply(t, reply, "BLMOVE"); got != "$1\r\nz\r\n" {
+		t.Errorf("BLMOVE should get the element, got %q", got)
+	}
+	if got := writer.do("LRANGE dst 0 -1"); got != "*1\r\n$1\r\nz\r\n" {
+		t.Errorf("BLMOVE should push to the destination, got %q", got)
+	}
+
+	// a served element goes back when the destination isn't a list
+	writer.do("SET str v")
+	reply, _ = blockingClient(t, db, "BLMOVE src str LEFT LEFT 0")
+	writer.do("RPUSH src w")
+	if got := waitReply(t, reply, "BLMOVE"); !strings.HasPrefix(got, "-WRONGTYPE") {
+		t.Errorf("BLMOVE to a string should fail, got %q", got)
+	}
+	if got := writer.do("LRANGE src 0 -1"); got != "*1\r\n$1\r\nw\r\n" {
+		t.Errorf("The element should be back in the source, got %q", got)
+	}
+}
+
+func TestBlockingPopPropagation(t *testing.T) {
+	db := database.NewStore()
+	tc := newTestClientOn(db)
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+
+	tc.do("LPUSH b x")
+	tc.do("LPUSH src y")
+	tc.do("BLPOP a b 5")
+	tc.do("BLMOVE src dst LEFT RIGHT 5")
+	tc.do("BRPOP a 1")
+
+	want := []string{"LPUSH b x", "LPUSH src y", "BLPOP b 0", "BLMOVE src dst LEFT RIGHT 0"}
+	if len(aof) != len(want) {
+		t.Fatalf("Expected %d propagated commands, got %q", len(want), aof)
+	}
+	for i, cmd := range aof {
+		if got := string(bytes.Join(cmd, []byte(" "))); got != want[i] {
+			t.Errorf("Propagated command %d: got %q, want %q", i, got, want[i])
+		}
+	}
+}
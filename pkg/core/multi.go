@@ -0,0 +1,189 @@
+package core
+
+import (
+	"redis-lite/pkg/database"
+	"slices"
+	"strconv"
+)
+
+// transaction holds the commands queued between MULTI and EXEC.
+type transaction struct {
+	queue [][][]byte
+	cmds  []*Command
+	// aborted is set when a command failed to queue (unknown, wrong arity),
+	// EXEC then discards the whole transaction.
+	aborted bool
+}
+
+// queuesInMulti reports whether cmd is queued instead of executed inside MULTI.
+func queuesInMulti(cmd *Command) bool {
+	switch cmd.Name {
+	case "exec", "discard", "multi", "watch":
+		return false
+	}
+	return true
+}
+
+// multi implements MULTI.
+func multi(c *Client, args [][]byte) bool {
+	if c.tx != nil {
+		return fail(c, "ERR MULTI calls can not be nested")
+	}
+	c.tx = &transaction{}
+	c.W.WriteOK()
+	return true
+}
+
+// discard implements DISCARD.
+func discard(c *Client, args [][]byte) bool {
+	if c.tx == nil {
+		return fail(c, "ERR DISCARD without MULTI")
+	}
+	c.tx = nil
+	c.unwatchAll()
+	c.W.WriteOK()
+	return true
+}
+
+// exec implements EXEC. The queued commands run with the shards of all their
+// keys locked, so other clients see either none or all of their effects.
+func exec(c *Client, args [][]byte) bool {
+	if c.tx == nil {
+		return fail(c, "ERR EXEC without MULTI")
+	}
+
+	tx := c.tx
+	c.tx = nil
+	defer c.unwatchAll()
+
+	if tx.aborted {
+		return fail(c, "EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	keys, allShards, allDBs := tx.keys()
+	for w := range c.watched {
+		if w.db != c.db {
+			allDBs = true
+		}
+		keys = append(keys, w.key)
+	}
+
+	if allDBs {
+		c.DBs.WithLocked(func(views database.Databases) {
+			c.execLocked(tx, views)
+		})
+		return true
+	}
+	c.DB.WithLocked(keys, allShards, func(view *database.Store) {
+		// the transaction stays in the selected database, whose view is the only one used
+		views := slices.Clone(c.DBs)
+		views[c.db] = view
+		c.execLocked(tx, views)
+	})
+	return true
+}
+
+// execLocked runs the commands of tx against views, the databases with the
+// locks EXEC took, unless a WATCHed key changed.
+func (c *Client) execLocked(tx *transaction, views database.Databases) {
+	for w, version := range c.watched {
+		if views[w.db].Modified(w.key, version) {
+			// a watched key changed: the transaction is not executed
+			c.W.WriteNullArray()
+			return
+		}
+	}
+
+	// blocking commands must not wait while we hold the locks
+	dbs, ctx := c.DBs, c.Ctx
+	c.DBs, c.DB, c.Ctx = views, views[c.db], nil
+	defer func() { c.DBs, c.DB, c.Ctx = dbs, dbs[c.db], ctx }()
+
+	start := c.db
+	var writes [][][]byte
+	wrote := false
+	c.W.WriteArray(len(tx.queue))
+	for i, cmd := range tx.cmds {
+		c.rewrite, c.rewritten = nil, false
+		db := c.db
+		if cmd.Handler(c, tx.queue[i]) && cmd.Has(FlagWrite) {
+			writes = append(writes, c.propagated(tx.queue[i])...)
+			wrote = true
+		}
+		if c.db != db {
+			// the writes that follow happen in the newly selected database
+			writes = append(writes, [][]byte{[]byte("SELECT"), strconv.AppendInt(nil, int64(c.db), 10)})
+		}
+	}
+
+	// propagate while the shards are still locked so the AOF order
+	// matches the order in which the keys were modified
+	if wrote && c.Propagate != nil {
+		wrapped := make([][][]byte, 0, len(writes)+2)
+		wrapped = append(wrapped, [][]byte{[]byte("MULTI")})
+		wrapped = append(wrapped, writes...)
+		wrapped = append(wrapped, [][]byte{[]byte("EXEC")})
+		c.Propagate(start, wrapped...)
+	}
+}
+
+// keys returns the keys touched by the queued commands. Commands that read or
+// write the dataset without declaring their keys need every shard locked,
+// those reaching other databases every shard of every database.
+func (tx *transaction) keys() (keys []string, allShards, allDBs bool) {
+	for i, cmd := range tx.cmds {
+		if cmd.Has(FlagAllDBs) {
+			return nil, true, true
+		}
+		if cmd.FirstKey == 0 {
+			if cmd.Has(FlagWrite) || cmd.Has(FlagReadOnly) {
+				allShards = true
+			}
+			continue
+		}
+		for _, key := range cmd.Keys(tx.queue[i]) {
+			keys = append(keys, string(key))
+		}
+	}
+	return keys, allShards, false
+}
+
+// watchedKey is a WATCHed key with the number of its database.
+type watchedKey struct {
+	db  int
+	key string
+}
+
+// watch implements WATCH key [key ...].
+func watch(c *Client, args [][]byte) bool {
+	if c.tx != nil {
+		return fail(c, "ERR WATCH inside MULTI is not allowed")
+	}
+	if c.watched == nil {
+		c.watched = make(map[watchedKey]uint64)
+	}
+	for _, arg := range args[1:] {
+		w := watchedKey{c.db, string(arg)}
+		if _, ok := c.watched[w]; ok {
+			continue
+		}
+		c.watched[w] = c.DB.Watch(w.key)
+	}
+	c.W.WriteOK()
+	return true
+}
+
+// unwatch implements UNWATCH.
+func unwatch(c *Client, args [][]byte) bool {
+	c.unwatchAll()
+	c.W.WriteOK()
+	return true
+}
+
+// unwatchAll forgets every WATCHed key of the client.
+func (c *Client) unwatchAll() {
+	for w := range c.watched {
+		c.DBs[w.db].Unwatch(w.key)
+	}
+	c.watched = nil
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"bytes"
+	"redis-lite/pkg/database"
+	"redis-lite/pkg/resp"
+	"sync"
+	"testing"
+)
+
+// newTestClientOn creates a client sharing db with other test clients.
+func newTestClientOn(db *database.Store) *testClient {
+	out := &bytes.Buffer{}
+	return &testClient{
+		Client: NewClient(database.Databases{db}, resp.NewWriter(out)),
+		out:    out,
+	}
+}
+
+func TestMultiExec(t *testing.T) {
+	tc := newTestClient()
+
+	steps := []struct{ cmd, want string }{
+		{"MULTI", "+OK\r\n"},
+		{"SET a 1", "+QUEUED\r\n"},
+		{"LPUSH l x", "+QUEUED\r\n"},
+		{"GET a", "+QUEUED\r\n"},
+		{"HSET a f v", "+QUEUED\r\n"},
+		{"EXEC", "*4\r\n+OK\r\n:1\r\n$1\r\n1\r\n-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"GET a", "$1\r\n1\r\n"},
+	}
+
+	for _, step := range steps {
+		if got := tc.do(step.cmd); got != step.want {
+			t.Errorf("%s: got %q, want %q", step.cmd, got, step.want)
+		}
+	}
+}
+
+func TestMultiDiscardAndErrors(t *testing.T) {
+	tc := newTestClient()
+
+	steps := []struct{ cmd, want string }{
+		{"EXEC", "-ERR EXEC without MULTI\r\n"},
+		{"DISCARD", "-ERR DISCARD without MULTI\r\n"},
+		{"MULTI", "+OK\r\n"},
+		{"MULTI", "-ERR MULTI calls can not be nested\r\n"},
+		{"WATCH a", "-ERR WATCH inside MULTI is not allowed\r\n"},
+		{"SET a 1", "+QUEUED\r\n"},
+		{"DISCARD", "+OK\r\n"},
+		{"GET a", "$-1\r\n"},
+		{"MULTI", "+OK\r\n"},
+		{"SET a", "-ERR wrong number of arguments for 'set' command\r\n"},
+		{"SET a 1", "+QUEUED\r\n"},
+		{"EXEC", "-EXECABORT Transaction discarded because of previous errors.\r\n"},
+		{"GET a", "$-1\r\n"},
+	}
+
+	for _, step := range steps {
+		if got := tc.do(step.cmd); got != step.want {
+			t.Errorf("%s: got %q, want %q", step.cmd, got, step.want)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	db := database.NewStore()
+	alice := newTestClientOn(db)
+	bob := newTestClientOn(db)
+
+	// untouched watched key: the transaction runs
+	alice.do("WATCH balance")
+	alice.do("MULTI")
+	alice.do("SET balance 100")
+	if got := alice.do("EXEC"); got != "*1\r\n+OK\r\n" {
+		t.Errorf("Expected EXEC to run, got %q", got)
+	}
+
+	// another client modifies the watched key: the transaction is aborted
+	alice.do("WATCH balance")
+	bob.do("SET balance 50")
+	alice.do("MULTI")
+	alice.do("SET balance 200")
+	if got := alice.do("EXEC"); got != "*-1\r\n" {
+		t.Errorf("Expected EXEC to abort with a null reply, got %q", got)
+	}
+	if got := alice.do("GET balance"); got != "$2\r\n50\r\n" {
+		t.Errorf("Expected bob's write to win, got %q", got)
+	}
+
+	// EXEC unwatches everything, so the next transaction runs
+	bob.do("SET balance 10")
+	alice.do("MULTI")
+	alice.do("SET balance 300")
+	if got := alice.do("EXEC"); got != "*1\r\n+OK\r\n" {
+		t.Errorf("Expected EXEC to run after the previous EXEC unwatched, got %q", got)
+	}
+
+	// UNWATCH forgets the keys
+	alice.do("WATCH balance")
+	bob.do("SET balance 1")
+	alice.do("UNWATCH")
+	alice.do("MULTI")
+	alice.do("SET balance 400")
+	if got := alice.do("EXEC"); got != "*1\r\n+OK\r\n" {
+		t.Errorf("Expected EXEC to run after UNWATCH, got %q", got)
+	}
+}
+
+func TestExecPropagatesAsOneBlock(t *testing.T) {
+	tc := newTestClient()
+
+	var propagated [][][]byte
+	calls := 0
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		calls++
+		propagated = append(propagated, cmds...)
+	}
+
+	tc.do("MULTI")
+	tc.do("SET a 1")
+	tc.do("GET a")
+	tc.do("LPUSH l x")
+	tc.do("EXEC")
+
+	var names []string
+	for _, cmd := range propagated {
+		names = append(names, string(cmd[0]))
+	}
+	if calls != 1 || len(names) != 4 || names[0] != "MULTI" || names[1] != "SET" || names[2] != "LPUSH" || names[3] != "EXEC" {
+		t.Errorf("Expected one MULTI/SET/LPUSH/EXEC block, got %d calls with %v", calls, names)
+	}
+}
+
+// TestExecIsAtomic moves money between two counters from many clients at once.
+// Without atomic EXEC, readers could observe a total different from 100.
+func TestExecIsAtomic(t *testing.T) {
+	db := database.NewStore()
+	setup := newTestClientOn(db)
+	setup.do("SET a 100")
+	setup.do("SET b 0")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tc := newTestClientOn(db)
+			for j := 0; j < 100; j++ {
+				if i%2 == 0 {
+					tc.do("MULTI")
+					tc.do("SET a 40")
+					tc.do("SET b 60")
+					tc.do("EXEC")
+				} else {
+					tc.do("MULTI")
+					tc.do("SET a 100")
+					tc.do("SET b 0")
+					tc.do("EXEC")
+				}
+
+				tc.do("MULTI")
+				tc.do("GET a")
+				tc.do("GET b")
+				got := tc.do("EXEC")
+				if got != "*2\r\n$2\r\n40\r\n$2\r\n60\r\n" && got != "*2\r\n$3\r\n100\r\n$1\r\n0\r\n" {
+					t.Errorf("Observed a partial transaction: %q", got)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestWatchAcrossDatabases checks that a WATCHed key belongs to the database
+// selected when WATCH ran, whatever EXEC runs in.
+func TestWatchAcrossDatabases(t *testing.T) {
+	alice := newTestClient()
+	bob := newTestClient()
+	bob.DBs, bob.DB = alice.DBs, alice.DBs[0]
+
+	alice.do("WATCH balance")
+	alice.do("SELECT 1")
+	alice.do("SET balance 1")
+	alice.do("MULTI")
+	alice.do("SET balance 2")
+	if got := alice.do("EXEC"); got != "*1\r\n+OK\r\n" {
+		t.Errorf("Expected EXEC to ignore the same key in another database, got %q", got)
+	}
+
+	alice.do("SELECT 0")
+	alice.do("WATCH balance")
+	alice.do("SELECT 1")
+	bob.do("SET balance 0")
+	alice.do("MULTI")
+	alice.do("SET balance 3")
+	if got := alice.do("EXEC"); got != "*-1\r\n" {
+		t.Errorf("Expected EXEC to abort after the watched key changed, got %q", got)
+	}
+}
@@ -0,0 +1,14 @@
+package core
+
+// publish implements PUBLISH topic message.
+func publish(c *Client, args [][]byte) bool {
+	count := c.DB.PubSub.Publish(string(args[1]), string(args[2]))
+	c.W.WriteInteger(int64(count))
+	return true
+}
+
+// subscribe is listed in the command table for introspection only:
+// subscribing takes over the connection, so the server handles it before Eval.
+func subscribe(c *Client, args [][]byte) bool {
+	return fail(c, "ERR SUBSCRIBE is not allowed in this context")
+}
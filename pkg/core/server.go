@@ -0,0 +1,122 @@
+package core
+
+import "strings"
+
+// command implements COMMAND [COUNT | LIST | INFO [name ...] | DOCS [name ...]].
+func command(c *Client, args [][]byte) bool {
+	w := c.W
+
+	if len(args) == 1 {
+		all := Commands()
+		w.WriteArray(len(all))
+		for _, cmd := range all {
+			writeCommandInfo(c, cmd)
+		}
+		return true
+	}
+
+	switch strings.ToUpper(string(args[1])) {
+	case "COUNT":
+		if len(args) != 2 {
+			return fail(c, "ERR wrong number of arguments for 'command|count' command")
+		}
+		w.WriteInteger(int64(len(commands)))
+
+	case "LIST":
+		if len(args) != 2 {
+			return fail(c, "ERR wrong number of arguments for 'command|list' command")
+		}
+		all := Commands()
+		w.WriteArray(len(all))
+		for _, cmd := range all {
+			w.WriteBulkString(cmd.Name)
+		}
+
+	case "INFO":
+		list := requestedCommands(args[2:])
+		w.WriteArray(len(list))
+		for _, cmd := range list {
+			if cmd == nil {
+				w.WriteNullArray()
+				continue
+			}
+			writeCommandInfo(c, cmd)
+		}
+
+	case "DOCS":
+		// unknown names are skipped, not reported as null
+		var list []*Command
+		for _, cmd := range requestedCommands(args[2:]) {
+			if cmd != nil {
+				list = append(list, cmd)
+			}
+		}
+		w.WriteMap(len(list))
+		for _, cmd := range list {
+			w.WriteBulkString(cmd.Name)
+			writeCommandDocs(c, cmd)
+		}
+
+	default:
+		return fail(c, "ERR unknown subcommand '"+string(args[1])+"'. Try COMMAND HELP.")
+	}
+
+	return true
+}
+
+// requestedCommands resolves the names given to COMMAND INFO/DOCS.
+// No names means every command; unknown names are returned as nil.
+func requestedCommands(names [][]byte) []*Command {
+	if len(names) == 0 {
+		return Commands()
+	}
+	list := make([]*Command, len(names))
+	for i, name := range names {
+		list[i] = Lookup(string(name))
+	}
+	return list
+}
+
+// writeCommandInfo writes the 10 element COMMAND INFO reply of cmd:
+// name, arity, flags, first key, last key, step, ACL categories, tips, key specs, subcommands.
+func writeCommandInfo(c *Client, cmd *Command) {
+	w := c.W
+
+	w.WriteArray(10)
+	w.WriteBulkString(cmd.Name)
+	w.WriteInteger(int64(cmd.Arity))
+
+	flags := cmd.FlagNames()
+	w.WriteSet(len(flags))
+	for _, f := range flags {
+		w.WriteSimpleString(f)
+	}
+
+	w.WriteInteger(int64(cmd.FirstKey))
+	w.WriteInteger(int64(cmd.LastKey))
+	w.WriteInteger(int64(cmd.Step))
+
+	cats := cmd.Categories()
+	w.WriteSet(len(cats))
+	for _, cat := range cats {
+		w.WriteSimpleString(cat)
+	}
+
+	// tips, key specifications and subcommands are not tracked
+	w.WriteArray(0)
+	w.WriteArray(0)
+	w.WriteArray(0)
+}
+
+// writeCommandDocs writes the documentation map of cmd used by COMMAND DOCS.
+func writeCommandDocs(c *Client, cmd *Command) {
+	w := c.W
+
+	w.WriteMap(3)
+	w.WriteBulkString("summary")
+	w.WriteBulkString(cmd.Summary)
+	w.WriteBulkString("since")
+	w.WriteBulkString(cmd.Since)
+	w.WriteBulkString("group")
+	w.WriteBulkString(cmd.Group)
+}
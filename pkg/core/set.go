@@ -0,0 +1,257 @@
+package core
+
+import (
+	"math"
+	"redis-lite/pkg/database"
+	"strings"
+)
+
+// writeStringSet writes members as a set reply (an array in RESP2).
+func writeStringSet(c *Client, members []string) {
+	c.W.WriteSet(len(members))
+	for _, m := range members {
+		c.W.WriteBulkString(m)
+	}
+}
+
+// sadd implements SADD key member [member ...].
+func sadd(c *Client, args [][]byte) bool {
+	added, err := c.DB.SAdd(string(args[1]), argStrings(args[2:]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(added))
+	return true
+}
+
+// srem implements SREM key member [member ...].
+func srem(c *Client, args [][]byte) bool {
+	removed, err := c.DB.SRem(string(args[1]), argStrings(args[2:]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(removed))
+	return true
+}
+
+// smembers implements SMEMBERS key.
+func smembers(c *Client, args [][]byte) bool {
+	members, err := c.DB.SMembers(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeStringSet(c, members)
+	return true
+}
+
+// sscan implements SSCAN key cursor [MATCH pattern] [COUNT count].
+func sscan(c *Client, args [][]byte) bool {
+	cursor, opts, msg := parseScan(args, 2, false)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	next, members, err := c.DB.SScan(string(args[1]), cursor, opts)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	writeScanCursor(c, next, len(members))
+	for _, m := range members {
+		c.W.WriteBulkString(m)
+	}
+	return true
+}
+
+// sismember implements SISMEMBER key member.
+func sismember(c *Client, args [][]byte) bool {
+	found, err := c.DB.SIsMember(string(args[1]), string(args[2]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeBoolInteger(c, found)
+	return true
+}
+
+// smismember implements SMISMEMBER key member [member ...].
+func smismember(c *Client, args [][]byte) bool {
+	found, err := c.DB.SMIsMember(string(args[1]), argStrings(args[2:]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteArray(len(found))
+	for _, f := range found {
+		writeBoolInteger(c, f)
+	}
+	return true
+}
+
+// scard implements SCARD key.
+func scard(c *Client, args [][]byte) bool {
+	n, err := c.DB.SCard(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// spop implements SPOP key [count].
+func spop(c *Client, args [][]byte) bool {
+	if len(args) > 3 {
+		return fail(c, errSyntax)
+	}
+
+	count := int64(1)
+	if len(args) == 3 {
+		var ok bool
+		if count, ok = parseInt(args[2]); !ok || count < 0 {
+			return fail(c, errNotPositive)
+		}
+	}
+
+	popped, err := c.DB.SPop(string(args[1]), int(count))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	// the members were picked at random, so replaying SPOP would pick others
+	if len(popped) > 0 {
+		srem := [][]byte{[]byte("SREM"), args[1]}
+		for _, m := range popped {
+			srem = append(srem, []byte(m))
+		}
+		c.propagateAs(srem)
+	} else {
+		c.propagateAs()
+	}
+
+	if len(args) == 3 {
+		writeStringSet(c, popped)
+	} else if len(popped) == 0 {
+		c.W.WriteNull()
+	} else {
+		c.W.WriteBulkString(popped[0])
+	}
+	return true
+}
+
+// srandmember implements SRANDMEMBER key [count].
+func srandmember(c *Client, args [][]byte) bool {
+	if len(args) > 3 {
+		return fail(c, errSyntax)
+	}
+
+	count := int64(1)
+	if len(args) == 3 {
+		var ok bool
+		if count, ok = parseInt(args[2]); !ok {
+			return fail(c, errNotInteger)
+		}
+		if count < -math.MaxInt64/2 || count > math.MaxInt64/2 {
+			return fail(c, "ERR value is out of range")
+		}
+	}
+
+	members, err := c.DB.SRandMember(string(args[1]), int(count))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	if len(args) == 3 {
+		c.W.WriteArray(len(members))
+		for _, m := range members {
+			c.W.WriteBulkString(m)
+		}
+	} else if len(members) == 0 {
+		c.W.WriteNull()
+	} else {
+		c.W.WriteBulkString(members[0])
+	}
+	return true
+}
+
+// smove implements SMOVE source destination member.
+func smove(c *Client, args [][]byte) bool {
+	moved, err := c.DB.SMove(string(args[1]), string(args[2]), string(args[3]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeBoolInteger(c, moved)
+	return true
+}
+
+// setAlgebra implements SINTER, SUNION and SDIFF key [key ...].
+func setAlgebra(c *Client, args [][]byte, op database.SetOp) bool {
+	members, err := c.DB.SCombine(op, argStrings(args[1:]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeStringSet(c, members)
+	return true
+}
+
+// setAlgebraStore implements SINTERSTORE, SUNIONSTORE and SDIFFSTORE destination key [key ...].
+func setAlgebraStore(c *Client, args [][]byte, op database.SetOp) bool {
+	n, err := c.DB.SCombineStore(op, string(args[1]), argStrings(args[2:]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+func sinter(c *Client, args [][]byte) bool {
+	return setAlgebra(c, args, database.SetInter)
+}
+
+func sunion(c *Client, args [][]byte) bool {
+	return setAlgebra(c, args, database.SetUnion)
+}
+
+func sdiff(c *Client, args [][]byte) bool {
+	return setAlgebra(c, args, database.SetDiff)
+}
+
+func sinterstore(c *Client, args [][]byte) bool {
+	return setAlgebraStore(c, args, database.SetInter)
+}
+
+func sunionstore(c *Client, args [][]byte) bool {
+	return setAlgebraStore(c, args, database.SetUnion)
+}
+
+func sdiffstore(c *Client, args [][]byte) bool {
+	return setAlgebraStore(c, args, database.SetDiff)
+}
+
+// sintercard implements SINTERCARD numkeys key [key ...] [LIMIT limit].
+func sintercard(c *Client, args [][]byte) bool {
+	numkeys, ok := parseInt(args[1])
+	if !ok || numkeys <= 0 {
+		return fail(c, "ERR numkeys should be greater than 0")
+	}
+	if numkeys > int64(len(args)-2) {
+		return fail(c, "ERR Number of keys can't be greater than number of args")
+	}
+	keys := argStrings(args[2 : 2+numkeys])
+
+	limit := int64(0)
+	rest := args[2+numkeys:]
+	switch {
+	case len(rest) == 0:
+	case len(rest) == 2 && strings.ToUpper(string(rest[0])) == "LIMIT":
+		if limit, ok = parseInt(rest[1]); !ok || limit < 0 {
+			return fail(c, "ERR LIMIT can't be negative")
+		}
+	default:
+		return fail(c, errSyntax)
+	}
+
+	n, err := c.DB.SInterCard(keys, int(limit))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestSetCommands replays the examples of the Redis documentation for each set command.
+// Members come back in no particular order, so the multi-member replies are
+// only checked through commands with a deterministic reply.
+func TestSetCommands(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// SADD, SREM, SCARD
+		{"SADD myset Hello World", ":2\r\n"},
+		{"SADD myset World", ":0\r\n"},
+		{"SCARD myset", ":2\r\n"},
+		{"SREM myset Hello nosuch", ":1\r\n"},
+		{"SMEMBERS myset", "*1\r\n$5\r\nWorld\r\n"},
+		{"SREM myset World", ":1\r\n"},
+		{"SCARD myset", ":0\r\n"},
+		{"SMEMBERS myset", "*0\r\n"},
+
+		// SISMEMBER, SMISMEMBER
+		{"SADD one one", ":1\r\n"},
+		{"SISMEMBER one one", ":1\r\n"},
+		{"SISMEMBER one two", ":0\r\n"},
+		{"SMISMEMBER one one notamember", "*2\r\n:1\r\n:0\r\n"},
+		{"SMISMEMBER nosuch one", "*1\r\n:0\r\n"},
+
+		// SMOVE
+		{"SADD src one two", ":2\r\n"},
+		{"SADD dst three", ":1\r\n"},
+		{"SMOVE src dst two", ":1\r\n"},
+		{"SMOVE src dst two", ":0\r\n"},
+		{"SMEMBERS src", "*1\r\n$3\r\none\r\n"},
+		{"SCARD dst", ":2\r\n"},
+		{"SMOVE src src one", ":1\r\n"},
+		{"SMOVE src dst one", ":1\r\n"},
+		{"SCARD src", ":0\r\n"},
+
+		// SINTER, SUNION, SDIFF and their STORE variants
+		{"SADD key1 a b c d", ":4\r\n"},
+		{"SADD key2 c", ":1\r\n"},
+		{"SADD key3 a c e", ":3\r\n"},
+		{"SINTER key1 key2 key3", "*1\r\n$1\r\nc\r\n"},
+		{"SINTER key1 nosuch", "*0\r\n"},
+		{"SINTERSTORE key key1 key3", ":2\r\n"},
+		{"SISMEMBER key a", ":1\r\n"},
+		{"SUNIONSTORE key key1 key2 key3", ":5\r\n"},
+		{"SDIFFSTORE key key1 key2 key3", ":2\r\n"},
+		{"SMISMEMBER key a b c d", "*4\r\n:0\r\n:1\r\n:0\r\n:1\r\n"},
+		{"SINTERSTORE key key1 nosuch", ":0\r\n"},
+		{"SCARD key", ":0\r\n"},
+
+		// SINTERCARD
+		{"SINTERCARD 2 key1 key3", ":2\r\n"},
+		{"SINTERCARD 2 key1 key3 LIMIT 1", ":1\r\n"},
+		{"SINTERCARD 2 key1 key3 LIMIT 0", ":2\r\n"},
+		{"SINTERCARD 1 key1 LIMIT 10", ":4\r\n"},
+		{"SINTERCARD 2 key1", "-ERR Number of keys can't be greater than number of args\r\n"},
+
+		// SPOP, SRANDMEMBER
+		{"SADD single x", ":1\r\n"},
+		{"SRANDMEMBER single", "$1\r\nx\r\n"},
+		{"SRANDMEMBER single -3", "*3\r\n$1\r\nx\r\n$1\r\nx\r\n$1\r\nx\r\n"},
+		{"SRANDMEMBER single 3", "*1\r\n$1\r\nx\r\n"},
+		{"SPOP single", "$1\r\nx\r\n"},
+		{"SPOP single", "$-1\r\n"},
+		{"SPOP single 2", "*0\r\n"},
+		{"SRANDMEMBER single", "$-1\r\n"},
+
+		// errors
+		{"SET str value", "+OK\r\n"},
+		{"SADD str a", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"SMEMBERS str", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"SINTER key1 str", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"SMOVE key1 str a", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"SPOP key1 -1", "-ERR value is out of range, must be positive\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestSPopPropagation checks that SPOP reaches the AOF as the SREM of the
+// members it picked.
+func TestSPopPropagation(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+
+	tc.do("SADD s a b c d")
+	popped := tc.do("SPOP s 2")
+	tc.do("SPOP missing")
+
+	if len(aof) != 2 {
+		t.Fatalf("Expected 2 propagated commands, got %q", aof)
+	}
+	srem := aof[1]
+	if string(srem[0]) != "SREM" || len(srem) != 4 {
+		t.Fatalf("SPOP should be propagated as SREM, got %q", srem)
+	}
+
+	var members []string
+	for _, m := range srem[2:] {
+		members = append(members, string(m))
+		if !strings.Contains(popped, "\r\n"+string(m)+"\r\n") {
+			t.Errorf("Propagated %q, which SPOP didn't reply: %q", m, popped)
+		}
+	}
+	sort.Strings(members)
+	if members[0] == members[1] {
+		t.Errorf("Propagated the same member twice: %q", members)
+	}
+}
@@ -0,0 +1,936 @@
+package core
+
+import (
+	"redis-lite/pkg/database"
+	"redis-lite/pkg/resp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const errInvalidStreamID = "ERR Invalid stream ID specified as stream command argument"
+
+// parseStreamID parses an explicit entry ID, "ms" alone meaning ms-missingSeq.
+func parseStreamID(arg []byte, missingSeq uint64) (database.StreamID, bool) {
+	return database.ParseStreamID(string(arg), missingSeq)
+}
+
+// parseRangeID parses a bound of XRANGE / XPENDING: "-", "+", an ID, or an
+// exclusive "(ID". An incomplete start ID ("5") means 5-0, an end one 5-max.
+func parseRangeID(arg []byte, isEnd bool) (database.StreamID, string) {
+	switch string(arg) {
+	case "-":
+		return database.StreamID{}, ""
+	case "+":
+		return database.MaxStreamID, ""
+	}
+
+	exclusive := len(arg) > 0 && arg[0] == '('
+	if exclusive {
+		arg = arg[1:]
+	}
+
+	missingSeq := uint64(0)
+	if isEnd {
+		missingSeq = database.MaxStreamID.Seq
+	}
+	id, ok := parseStreamID(arg, missingSeq)
+	if !ok {
+		return id, errInvalidStreamID
+	}
+	if !exclusive {
+		return id, ""
+	}
+
+	if isEnd {
+		if id, ok = id.Prev(); !ok {
+			return id, "ERR invalid end ID for the interval"
+		}
+	} else if id, ok = id.Next(); !ok {
+		return id, "ERR invalid start ID for the interval"
+	}
+	return id, ""
+}
+
+// writeStreamEntry writes [id, [field, value, ...]], the fields being null
+// for a pending entry that was trimmed from the stream.
+func writeStreamEntry(c *Client, e database.StreamEntry) {
+	c.W.WriteArray(2)
+	c.W.WriteBulkString(e.ID.String())
+	if e.Fields == nil {
+		c.W.WriteNullArray()
+		return
+	}
+	c.W.WriteArray(len(e.Fields))
+	for _, f := range e.Fields {
+		c.W.WriteBulk(f)
+	}
+}
+
+func writeStreamEntries(c *Client, entries []database.StreamEntry) {
+	c.W.WriteArray(len(entries))
+	for _, e := range entries {
+		writeStreamEntry(c, e)
+	}
+}
+
+func writeStreamIDs(c *Client, ids []database.StreamID) {
+	c.W.WriteArray(len(ids))
+	for _, id := range ids {
+		c.W.WriteBulkString(id.String())
+	}
+}
+
+// streamRead is what XREAD and XREADGROUP return for one stream.
+type streamRead struct {
+	key     string
+	entries []database.StreamEntry
+}
+
+// writeStreamReads replies with the streams read: a map in RESP3 and an
+// array of [key, entries] pairs in RESP2.
+func writeStreamReads(c *Client, reads []streamRead) {
+	if c.W.Protocol() == resp.RESP3 {
+		c.W.WriteMap(len(reads))
+	} else {
+		c.W.WriteArray(len(reads))
+	}
+	for _, r := range reads {
+		if c.W.Protocol() != resp.RESP3 {
+			c.W.WriteArray(2)
+		}
+		c.W.WriteBulkString(r.key)
+		writeStreamEntries(c, r.entries)
+	}
+}
+
+// xadd implements XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...].
+func xadd(c *Client, args [][]byte) bool {
+	var xargs database.XAddArgs
+	approx, limit := false, false
+
+	i := 2
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NOMKSTREAM":
+			xargs.NoMkStream = true
+		case "MAXLEN", "MINID":
+			strategy := strings.ToUpper(string(args[i]))
+			if i+1 < len(args) && (string(args[i+1]) == "=" || string(args[i+1]) == "~") {
+				approx = string(args[i+1]) == "~"
+				i++
+			}
+			if i+1 >= len(args) {
+				return fail(c, errSyntax)
+			}
+			i++
+			if strategy == "MAXLEN" {
+				n, ok := parseInt(args[i])
+				if !ok {
+					return fail(c, errNotInteger)
+				}
+				if n < 0 {
+					return fail(c, "ERR The MAXLEN argument must be >= 0.")
+				}
+				xargs.Trim.Strategy, xargs.Trim.MaxLen = database.TrimMaxLen, int(n)
+			} else {
+				id, ok := parseStreamID(args[i], 0)
+				if !ok {
+					return fail(c, errInvalidStreamID)
+				}
+				xargs.Trim.Strategy, xargs.Trim.MinID = database.TrimMinID, id
+			}
+		case "LIMIT":
+			if i+1 >= len(args) {
+				return fail(c, errSyntax)
+			}
+			i++
+			n, ok := parseInt(args[i])
+			if !ok || n < 0 {
+				return fail(c, "ERR The LIMIT argument must be >= 0.")
+			}
+			xargs.Trim.Limit, limit = int(n), true
+		default:
+			break options
+		}
+	}
+
+	if limit && !approx {
+		return fail(c, "ERR syntax error, LIMIT cannot be used without the special ~ option")
+	}
+
+	if i >= len(args) || len(args[i+1:]) == 0 || len(args[i+1:])%2 != 0 {
+		return fail(c, "ERR wrong number of arguments for 'xadd' command")
+	}
+
+	idArg := string(args[i])
+	switch {
+	case idArg == "*":
+		xargs.AutoID = true
+	case strings.HasSuffix(idArg, "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(idArg, "-*"), 10, 64)
+		if err != nil {
+			return fail(c, errInvalidStreamID)
+		}
+		xargs.ID.Ms, xargs.AutoSeq = ms, true
+	default:
+		id, ok := parseStreamID(args[i], 0)
+		if !ok {
+			return fail(c, errInvalidStreamID)
+		}
+		xargs.ID = id
+	}
+	xargs.Fields = args[i+1:]
+
+	id, added, err := c.DB.XAdd(string(args[1]), xargs)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	if !added {
+		c.W.WriteNull()
+		c.propagateAs()
+		return true
+	}
+
+	if xargs.AutoID || xargs.AutoSeq {
+		// the AOF must recreate the entry with the ID we generated
+		rewritten := append([][]byte(nil), args...)
+		rewritten[i] = []byte(id.String())
+		c.propagateAs(rewritten)
+	}
+
+	c.W.WriteBulkString(id.String())
+	return true
+}
+
+// xlen implements XLEN key.
+func xlen(c *Client, args [][]byte) bool {
+	n, err := c.DB.XLen(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// xrange implements XRANGE key start end [COUNT count].
+func xrange(c *Client, args [][]byte) bool {
+	return xrangeGeneric(c, args, false)
+}
+
+// xrevrange implements XREVRANGE key end start [COUNT count].
+func xrevrange(c *Client, args [][]byte) bool {
+	return xrangeGeneric(c, args, true)
+}
+
+func xrangeGeneric(c *Client, args [][]byte, rev bool) bool {
+	startArg, endArg := args[2], args[3]
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+
+	start, msg := parseRangeID(startArg, false)
+	if msg != "" {
+		return fail(c, msg)
+	}
+	end, msg := parseRangeID(endArg, true)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	count := -1
+	if len(args) > 4 {
+		if len(args) != 6 || !strings.EqualFold(string(args[4]), "COUNT") {
+			return fail(c, errSyntax)
+		}
+		n, ok := parseInt(args[5])
+		if !ok {
+			return fail(c, errNotInteger)
+		}
+		count = int(max(n, 0))
+	}
+	if count == 0 {
+		c.W.WriteArray(0)
+		return true
+	}
+
+	entries, err := c.DB.XRange(string(args[1]), start, end, rev, count)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeStreamEntries(c, entries)
+	return true
+}
+
+// xreadOptions are the options shared by XREAD and XREADGROUP.
+type xreadOptions struct {
+	count    int
+	block    bool
+	timeout  time.Duration
+	noAck    bool
+	keys     []string
+	ids      [][]byte
+	group    string
+	consumer string
+}
+
+// parseXRead parses [GROUP group consumer] [COUNT count] [BLOCK ms] [NOACK] STREAMS key... id...
+func parseXRead(args [][]byte, withGroup bool) (xreadOptions, string) {
+	var opts xreadOptions
+	name := "xread"
+	if withGroup {
+		name = "xreadgroup"
+	}
+
+	i := 1
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		if opt == "STREAMS" {
+			break
+		}
+		switch {
+		case opt == "COUNT" && i+1 < len(args):
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				return opts, errNotInteger
+			}
+			opts.count = int(max(n, 0))
+			i++
+		case opt == "BLOCK" && i+1 < len(args):
+			timeout, msg := parseTimeoutMs(args[i+1])
+			if msg != "" {
+				return opts, msg
+			}
+			opts.block, opts.timeout = true, timeout
+			i++
+		case opt == "GROUP" && withGroup && i+2 < len(args):
+			opts.group, opts.consumer = string(args[i+1]), string(args[i+2])
+			i += 2
+		case opt == "NOACK" && withGroup:
+			opts.noAck = true
+		default:
+			return opts, errSyntax
+		}
+	}
+
+	if withGroup && opts.group == "" {
+		return opts, "ERR Missing GROUP option for XREADGROUP"
+	}
+
+	streams := args[min(i+1, len(args)):]
+	if i == len(args) || len(streams) == 0 || len(streams)%2 != 0 {
+		return opts, "ERR Unbalanced '" + name + "' list of streams: for each stream key an ID or '$' must be specified."
+	}
+
+	n := len(streams) / 2
+	for _, key := range streams[:n] {
+		opts.keys = append(opts.keys, string(key))
+	}
+	opts.ids = streams[n:]
+	return opts, ""
+}
+
+// xread implements XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...].
+func xread(c *Client, args [][]byte) bool {
+	opts, msg := parseXRead(args, false)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	// "$" means the entries added from now on, so it is resolved once before blocking
+	after := make([]database.StreamID, len(opts.keys))
+	for i, arg := range opts.ids {
+		if string(arg) == "$" {
+			last, err := c.DB.XLastID(opts.keys[i])
+			if err != nil {
+				return fail(c, err.Error())
+			}
+			after[i] = last
+			continue
+		}
+		id, ok := parseStreamID(arg, 0)
+		if !ok {
+			return fail(c, errInvalidStreamID)
+		}
+		after[i] = id
+	}
+
+	var reads []streamRead
+	var failure error
+	try := func() bool {
+		for i, key := range opts.keys {
+			entries, err := c.DB.XRead(key, after[i], opts.count)
+			if err != nil {
+				failure = err
+				return true
+			}
+			if len(entries) > 0 {
+				reads = append(reads, streamRead{key: key, entries: entries})
+			}
+		}
+		return len(reads) > 0
+	}
+
+	if opts.block {
+		blockOn(c, opts.keys, opts.timeout, try)
+	} else {
+		try()
+	}
+
+	if failure != nil {
+		return fail(c, failure.Error())
+	}
+	if len(reads) == 0 {
+		c.W.WriteNullArray()
+		return true
+	}
+	writeStreamReads(c, reads)
+	return true
+}
+
+// noGroup builds the NOGROUP error of XREADGROUP.
+func noGroup(key, group string) string {
+	return "NOGROUP No such key '" + key + "' or consumer group '" + group + "' in XREADGROUP with GROUP option"
+}
+
+// xreadgroup implements XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...].
+func xreadgroup(c *Client, args [][]byte) bool {
+	opts, msg := parseXRead(args, true)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	readArgs := make([]database.XReadGroupArgs, len(opts.keys))
+	onlyNew := true
+	for i, arg := range opts.ids {
+		readArgs[i] = database.XReadGroupArgs{
+			Group:    opts.group,
+			Consumer: opts.consumer,
+			Count:    opts.count,
+			NoAck:    opts.noAck,
+		}
+		if string(arg) == ">" {
+			readArgs[i].New = true
+			continue
+		}
+		id, ok := parseStreamID(arg, 0)
+		if !ok {
+			return fail(c, errInvalidStreamID)
+		}
+		readArgs[i].ID = id
+		onlyNew = false
+	}
+
+	// like Redis, fail before delivering anything when a group is missing
+	for _, key := range opts.keys {
+		exists, err := c.DB.XGroupExists(key, opts.group)
+		if err != nil {
+			return fail(c, err.Error())
+		}
+		if !exists {
+			return fail(c, noGroup(key, opts.group))
+		}
+	}
+
+	var reads []streamRead
+	var failure string
+	var now int64
+	try := func() bool {
+		now = time.Now().UnixMilli()
+		for i, key := range opts.keys {
+			readArgs[i].Now = now
+			entries, err := c.DB.XReadGroup(key, readArgs[i])
+			if err == database.ErrNoGroup {
+				failure = noGroup(key, opts.group)
+				return true
+			}
+			if err != nil {
+				failure = err.Error()
+				return true
+			}
+			// history reads always report their stream, even when empty
+			if len(entries) > 0 || !readArgs[i].New {
+				reads = append(reads, streamRead{key: key, entries: entries})
+			}
+		}
+		return len(reads) > 0
+	}
+
+	// only reads of new entries wait, history is served right away
+	if opts.block && onlyNew {
+		blockOn(c, opts.keys, opts.timeout, try)
+	} else {
+		try()
+	}
+
+	if failure != "" {
+		return fail(c, failure)
+	}
+
+	c.propagateAs(xreadgroupEffects(opts, readArgs, reads, now)...)
+
+	if len(reads) == 0 {
+		c.W.WriteNullArray()
+		return true
+	}
+	writeStreamReads(c, reads)
+	return true
+}
+
+// xreadgroupEffects turns what XREADGROUP delivered into commands that
+// reproduce it exactly, whenever the AOF gets replayed: one XCLAIM per
+// entry added to or re-delivered from the pending list, and XGROUP SETID
+// for the entries read with NOACK.
+func xreadgroupEffects(opts xreadOptions, readArgs []database.XReadGroupArgs, reads []streamRead, now int64) [][][]byte {
+	var cmds [][][]byte
+	group, consumer := []byte(opts.group), []byte(opts.consumer)
+	deliveredAt := []byte(strconv.FormatInt(now, 10))
+
+	for _, r := range reads {
+		var ra database.XReadGroupArgs
+		for i, key := range opts.keys {
+			if key == r.key {
+				ra = readArgs[i]
+				break
+			}
+		}
+		key := []byte(r.key)
+
+		if ra.New && ra.NoAck {
+			if len(r.entries) > 0 {
+				last := r.entries[len(r.entries)-1].ID.String()
+				cmds = append(cmds, [][]byte{[]byte("XGROUP"), []byte("SETID"), key, group, []byte(last)})
+			}
+			continue
+		}
+
+		for _, e := range r.entries {
+			id := []byte(e.ID.String())
+			if ra.New {
+				cmds = append(cmds, [][]byte{
+					[]byte("XCLAIM"), key, group, consumer, []byte("0"), id,
+					[]byte("TIME"), deliveredAt, []byte("RETRYCOUNT"), []byte("1"),
+					[]byte("FORCE"), []byte("JUSTID"), []byte("LASTID"), id,
+				})
+			} else if e.Fields != nil {
+				cmds = append(cmds, [][]byte{
+					[]byte("XCLAIM"), key, group, consumer, []byte("0"), id,
+					[]byte("TIME"), deliveredAt,
+				})
+			}
+		}
+	}
+
+	return cmds
+}
+
+// xgroupArity is the arity of each XGROUP subcommand, counting XGROUP itself.
+var xgroupArity = map[string]int{"CREATE": -5, "SETID": -5, "DESTROY": 4, "CREATECONSUMER": 5, "DELCONSUMER": 5}
+
+// xgroup implements XGROUP CREATE | SETID | DESTROY | CREATECONSUMER | DELCONSUMER.
+func xgroup(c *Client, args [][]byte) bool {
+	sub := strings.ToUpper(string(args[1]))
+
+	want, known := xgroupArity[sub]
+	if !known {
+		return fail(c, "ERR unknown subcommand '"+string(args[1])+"'. Try XGROUP HELP.")
+	}
+	if want > 0 && len(args) != want || want < 0 && len(args) < -want {
+		return fail(c, "ERR wrong number of arguments for 'xgroup|"+strings.ToLower(sub)+"' command")
+	}
+
+	key, group := string(args[2]), string(args[3])
+	noSuchGroup := "NOGROUP No such consumer group '" + group + "' for key name '" + key + "'"
+
+	switch sub {
+	case "CREATE", "SETID":
+		useLast := string(args[4]) == "$"
+		var id database.StreamID
+		if !useLast {
+			var ok bool
+			if id, ok = parseStreamID(args[4], 0); !ok {
+				return fail(c, errInvalidStreamID)
+			}
+		}
+
+		mkStream := false
+		for i := 5; i < len(args); i++ {
+			switch opt := strings.ToUpper(string(args[i])); {
+			case opt == "MKSTREAM" && sub == "CREATE":
+				mkStream = true
+			case opt == "ENTRIESREAD" && i+1 < len(args):
+				// we don't track the lag of groups, the value is accepted and ignored
+				if _, ok := parseInt(args[i+1]); !ok {
+					return fail(c, errNotInteger)
+				}
+				i++
+			default:
+				return fail(c, errSyntax)
+			}
+		}
+
+		var err error
+		if sub == "CREATE" {
+			id, err = c.DB.XGroupCreate(key, group, id, useLast, mkStream)
+		} else {
+			id, err = c.DB.XGroupSetID(key, group, id, useLast)
+		}
+		if err == database.ErrNoGroup {
+			return fail(c, noSuchGroup)
+		}
+		if err != nil {
+			return fail(c, err.Error())
+		}
+
+		if useLast {
+			// "$" depends on the stream at the time the command runs
+			rewritten := append([][]byte(nil), args...)
+			rewritten[4] = []byte(id.String())
+			c.propagateAs(rewritten)
+		}
+		c.W.WriteOK()
+		return true
+
+	case "DESTROY":
+		destroyed, err := c.DB.XGroupDestroy(key, group)
+		if err != nil {
+			return fail(c, err.Error())
+		}
+		if destroyed {
+			c.W.WriteInteger(1)
+		} else {
+			c.W.WriteInteger(0)
+		}
+		return true
+
+	case "CREATECONSUMER":
+		created, err := c.DB.XGroupCreateConsumer(key, group, string(args[4]))
+		if err == database.ErrNoGroup {
+			return fail(c, noSuchGroup)
+		}
+		if err != nil {
+			return fail(c, err.Error())
+		}
+		if created {
+			c.W.WriteInteger(1)
+		} else {
+			c.W.WriteInteger(0)
+		}
+		return true
+	}
+
+	// DELCONSUMER
+	pending, err := c.DB.XGroupDelConsumer(key, group, string(args[4]))
+	if err == database.ErrNoGroup {
+		return fail(c, noSuchGroup)
+	}
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(pending))
+	return true
+}
+
+// parseStreamIDs parses a list of explicit entry IDs.
+func parseStreamIDs(args [][]byte) ([]database.StreamID, bool) {
+	ids := make([]database.StreamID, len(args))
+	for i, arg := range args {
+		id, ok := parseStreamID(arg, 0)
+		if !ok {
+			return nil, false
+		}
+		ids[i] = id
+	}
+	return ids, true
+}
+
+// xack implements XACK key group id [id ...].
+func xack(c *Client, args [][]byte) bool {
+	ids, ok := parseStreamIDs(args[3:])
+	if !ok {
+		return fail(c, errInvalidStreamID)
+	}
+	n, err := c.DB.XAck(string(args[1]), string(args[2]), ids)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// xpending implements XPENDING key group [[IDLE min-idle-time] start end count [consumer]].
+func xpending(c *Client, args [][]byte) bool {
+	key, group := string(args[1]), string(args[2])
+	noSuchGroup := "NOGROUP No such key '" + key + "' or consumer group '" + group + "'"
+
+	if len(args) == 3 {
+		summary, err := c.DB.XPendingSummary(key, group)
+		if err == database.ErrNoGroup {
+			return fail(c, noSuchGroup)
+		}
+		if err != nil {
+			return fail(c, err.Error())
+		}
+
+		c.W.WriteArray(4)
+		c.W.WriteInteger(int64(summary.Count))
+		if summary.Count == 0 {
+			c.W.WriteNull()
+			c.W.WriteNull()
+			c.W.WriteNullArray()
+			return true
+		}
+		c.W.WriteBulkString(summary.Min.String())
+		c.W.WriteBulkString(summary.Max.String())
+		c.W.WriteArray(len(summary.Consumers))
+		for _, cp := range summary.Consumers {
+			c.W.WriteArray(2)
+			c.W.WriteBulkString(cp.Name)
+			c.W.WriteBulkString(strconv.Itoa(cp.Count))
+		}
+		return true
+	}
+
+	var pargs database.XPendingArgs
+	rest := args[3:]
+	if strings.EqualFold(string(rest[0]), "IDLE") {
+		if len(rest) < 2 {
+			return fail(c, errSyntax)
+		}
+		idle, ok := parseInt(rest[1])
+		if !ok {
+			return fail(c, errNotInteger)
+		}
+		pargs.MinIdle = idle
+		rest = rest[2:]
+	}
+	if len(rest) < 3 || len(rest) > 4 {
+		return fail(c, errSyntax)
+	}
+
+	var msg string
+	if pargs.Start, msg = parseRangeID(rest[0], false); msg != "" {
+		return fail(c, msg)
+	}
+	if pargs.End, msg = parseRangeID(rest[1], true); msg != "" {
+		return fail(c, msg)
+	}
+	count, ok := parseInt(rest[2])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+	pargs.Count = int(max(count, 0))
+	if len(rest) == 4 {
+		pargs.Consumer = string(rest[3])
+	}
+	pargs.Now = time.Now().UnixMilli()
+
+	pending, err := c.DB.XPending(key, group, pargs)
+	if err == database.ErrNoGroup {
+		return fail(c, noSuchGroup)
+	}
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	c.W.WriteArray(len(pending))
+	for _, p := range pending {
+		c.W.WriteArray(4)
+		c.W.WriteBulkString(p.ID.String())
+		c.W.WriteBulkString(p.Consumer)
+		c.W.WriteInteger(pargs.Now - p.DeliveredAt)
+		c.W.WriteInteger(int64(p.Deliveries))
+	}
+	return true
+}
+
+// parseMinIdle parses the min-idle-time argument of XCLAIM and XAUTOCLAIM.
+func parseMinIdle(arg []byte) (int64, string) {
+	ms, ok := parseInt(arg)
+	if !ok {
+		return 0, "ERR Invalid min-idle-time argument for XCLAIM"
+	}
+	return max(ms, 0), ""
+}
+
+// claimEffects is the XCLAIM that reproduces a claim in the AOF: it names
+// the IDs that were claimed or dropped, with an absolute delivery time in
+// place of the idle times, which depend on when the AOF is replayed.
+func claimEffects(args [][]byte, cargs database.XClaimArgs, claimed []database.StreamEntry, deleted []database.StreamID, options ...string) [][][]byte {
+	if len(claimed)+len(deleted) == 0 {
+		return nil
+	}
+
+	cmd := [][]byte{[]byte("XCLAIM"), args[1], args[2], args[3], []byte("0")}
+	for _, e := range claimed {
+		cmd = append(cmd, []byte(e.ID.String()))
+	}
+	for _, id := range deleted {
+		cmd = append(cmd, []byte(id.String()))
+	}
+	cmd = append(cmd, []byte("TIME"), []byte(strconv.FormatInt(cargs.DeliveredAt, 10)))
+	for _, opt := range options {
+		cmd = append(cmd, []byte(opt))
+	}
+	return [][][]byte{cmd}
+}
+
+// xclaim implements XCLAIM key group consumer min-idle-time id [id ...]
+// [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid].
+func xclaim(c *Client, args [][]byte) bool {
+	minIdle, msg := parseMinIdle(args[4])
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	now := time.Now().UnixMilli()
+	cargs := database.XClaimArgs{
+		Group:       string(args[2]),
+		Consumer:    string(args[3]),
+		MinIdle:     minIdle,
+		Now:         now,
+		DeliveredAt: now,
+		RetryCount:  -1,
+	}
+
+	// IDs come first, the options start at the first argument that isn't one
+	i := 5
+	for ; i < len(args); i++ {
+		id, ok := parseStreamID(args[i], 0)
+		if !ok {
+			break
+		}
+		cargs.IDs = append(cargs.IDs, id)
+	}
+	if len(cargs.IDs) == 0 {
+		return fail(c, errInvalidStreamID)
+	}
+
+	var options []string
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch {
+		case opt == "FORCE":
+			cargs.Force = true
+			options = append(options, opt)
+		case opt == "JUSTID":
+			cargs.JustID = true
+			options = append(options, opt)
+		case (opt == "IDLE" || opt == "TIME" || opt == "RETRYCOUNT") && i+1 < len(args):
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				return fail(c, "ERR Invalid "+opt+" option argument for XCLAIM")
+			}
+			switch opt {
+			case "IDLE":
+				cargs.DeliveredAt = now - n
+			case "TIME":
+				cargs.DeliveredAt = n
+			default:
+				cargs.RetryCount = int(n)
+				options = append(options, opt, string(args[i+1]))
+			}
+			i++
+		case opt == "LASTID" && i+1 < len(args):
+			id, ok := parseStreamID(args[i+1], 0)
+			if !ok {
+				return fail(c, errInvalidStreamID)
+			}
+			cargs.LastID = id
+			options = append(options, opt, string(args[i+1]))
+			i++
+		default:
+			return fail(c, "ERR Unrecognized XCLAIM option '"+string(args[i])+"'")
+		}
+	}
+	// a delivery time in the future makes no sense
+	cargs.DeliveredAt = min(cargs.DeliveredAt, now)
+
+	claimed, deleted, err := c.DB.XClaim(string(args[1]), cargs)
+	if err == database.ErrNoGroup {
+		return fail(c, "NOGROUP No such key '"+string(args[1])+"' or consumer group '"+cargs.Group+"'")
+	}
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	c.propagateAs(claimEffects(args, cargs, claimed, deleted, options...)...)
+
+	if cargs.JustID {
+		ids := make([]database.StreamID, len(claimed))
+		for i, e := range claimed {
+			ids[i] = e.ID
+		}
+		writeStreamIDs(c, ids)
+		return true
+	}
+	writeStreamEntries(c, claimed)
+	return true
+}
+
+// xautoclaim implements XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID].
+func xautoclaim(c *Client, args [][]byte) bool {
+	minIdle, msg := parseMinIdle(args[4])
+	if msg != "" {
+		return fail(c, msg)
+	}
+	start, msg := parseRangeID(args[5], false)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	now := time.Now().UnixMilli()
+	cargs := database.XClaimArgs{
+		Group:       string(args[2]),
+		Consumer:    string(args[3]),
+		MinIdle:     minIdle,
+		Now:         now,
+		DeliveredAt: now,
+		RetryCount:  -1,
+	}
+
+	count := 100
+	var options []string
+	for i := 6; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch {
+		case opt == "JUSTID":
+			cargs.JustID = true
+			options = append(options, opt)
+		case opt == "COUNT" && i+1 < len(args):
+			n, ok := parseInt(args[i+1])
+			if !ok || n < 1 || n > 1<<20 {
+				return fail(c, "ERR COUNT must be > 0")
+			}
+			count = int(n)
+			i++
+		default:
+			return fail(c, errSyntax)
+		}
+	}
+
+	next, claimed, deleted, err := c.DB.XAutoClaim(string(args[1]), start, count, cargs)
+	if err == database.ErrNoGroup {
+		return fail(c, "NOGROUP No such key '"+string(args[1])+"' or consumer group '"+cargs.Group+"'")
+	}
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	c.propagateAs(claimEffects(args, cargs, claimed, deleted, options...)...)
+
+	c.W.WriteArray(3)
+	c.W.WriteBulkString(next.String())
+	if cargs.JustID {
+		ids := make([]database.StreamID, len(claimed))
+		for i, e := range claimed {
+			ids[i] = e.ID
+		}
+		writeStreamIDs(c, ids)
+	} else {
+		writeStreamEntries(c, claimed)
+	}
+	writeStreamIDs(c, deleted)
+	return true
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"redis-lite/pkg/database"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamCommands(t *testing.T) {
+	tc := newTestClient()
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"XADD s 5-1 a 1", "$3\r\n5-1\r\n"},
+		{"XADD s 5-* b 2", "$3\r\n5-2\r\n"},
+		{"XADD s 5 c 3", "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n"},
+		{"XADD s 6 c 3", "$3\r\n6-0\r\n"},
+		{"XADD s 0-0 d 4", "-ERR The ID specified in XADD must be greater than 0-0\r\n"},
+		{"XADD e 0-* a 1", "$3\r\n0-1\r\n"},
+		{"XLEN s", ":3\r\n"},
+		{"XRANGE s - +", "*3\r\n*2\r\n$3\r\n5-1\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n" +
+			"*2\r\n$3\r\n5-2\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n*2\r\n$3\r\n6-0\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n"},
+		{"XRANGE s 5 5", "*2\r\n*2\r\n$3\r\n5-1\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n*2\r\n$3\r\n5-2\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"XRANGE s (5-1 + COUNT 1", "*1\r\n*2\r\n$3\r\n5-2\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"XREVRANGE s + - COUNT 1", "*1\r\n*2\r\n$3\r\n6-0\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n"},
+		{"XREAD COUNT 1 STREAMS s e 5-2 0", "*2\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n6-0\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n" +
+			"*2\r\n$1\r\ne\r\n*1\r\n*2\r\n$3\r\n0-1\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"XREAD STREAMS s $", "*-1\r\n"},
+		{"XADD s MAXLEN 2 7-0 d 4", "$3\r\n7-0\r\n"},
+		{"XRANGE s - + COUNT 1", "*1\r\n*2\r\n$3\r\n6-0\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n"},
+		{"XADD s MINID 7 8-0 e 5", "$3\r\n8-0\r\n"},
+		{"XLEN s", ":2\r\n"},
+		{"XADD missing NOMKSTREAM * a 1", "$-1\r\n"},
+		{"XLEN missing", ":0\r\n"},
+	}
+
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestConsumerGroups(t *testing.T) {
+	tc := newTestClient()
+	tc.do("XADD s 1-0 a 1")
+	tc.do("XADD s 2-0 b 2")
+	tc.do("XADD s 3-0 c 3")
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"XGROUP CREATE s g 0", "+OK\r\n"},
+		{"XGROUP CREATE s g $", "-BUSYGROUP Consumer Group name already exists\r\n"},
+		{"XGROUP CREATE nokey g $", "-ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.\r\n"},
+		{"XREADGROUP GROUP g alice COUNT 2 STREAMS s >", "*1\r\n*2\r\n$1\r\ns\r\n*2\r\n" +
+			"*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"XREADGROUP GROUP g bob STREAMS s >", "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n3-0\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n"},
+		{"XREADGROUP GROUP g bob STREAMS s >", "*-1\r\n"},
+		{"XREADGROUP GROUP g bob STREAMS s 0", "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n3-0\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n"},
+		{"XREADGROUP GROUP g carol STREAMS s 0", "*1\r\n*2\r\n$1\r\ns\r\n*0\r\n"},
+		{"XREADGROUP GROUP nope alice STREAMS s >", "-NOGROUP No such key 's' or consumer group 'nope' in XREADGROUP with GROUP option\r\n"},
+		{"XPENDING s g", "*4\r\n:3\r\n$3\r\n1-0\r\n$3\r\n3-0\r\n*2\r\n*2\r\n$5\r\nalice\r\n$1\r\n2\r\n*2\r\n$3\r\nbob\r\n$1\r\n1\r\n"},
+		{"XACK s g 1-0 9-0", ":1\r\n"},
+		{"XACK s nope 1-0", ":0\r\n"},
+		{"XCLAIM s g carol 3600000 2-0", "*0\r\n"},
+		{"XCLAIM s g carol 0 2-0 JUSTID", "*1\r\n$3\r\n2-0\r\n"},
+		{"XAUTOCLAIM s g dave 0 0 COUNT 1 JUSTID", "*3\r\n$3\r\n3-0\r\n*1\r\n$3\r\n2-0\r\n*0\r\n"},
+		{"XAUTOCLAIM s g dave 0 3-0", "*3\r\n$3\r\n0-0\r\n*1\r\n*2\r\n$3\r\n3-0\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n*0\r\n"},
+		{"XGROUP DELCONSUMER s g dave", ":2\r\n"},
+		{"XPENDING s g", "*4\r\n:0\r\n$-1\r\n$-1\r\n*-1\r\n"},
+		{"XGROUP SETID s g 0", "+OK\r\n"},
+		{"XREADGROUP GROUP g erin NOACK COUNT 1 STREAMS s >", "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"XPENDING s g - + 10", "*0\r\n"},
+		{"XGROUP DESTROY s g", ":1\r\n"},
+		{"XGROUP DESTROY s g", ":0\r\n"},
+	}
+
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestXPendingExtended(t *testing.T) {
+	tc := newTestClient()
+	tc.do("XADD s 1-0 a 1")
+	tc.do("XADD s 2-0 b 2")
+	tc.do("XGROUP CREATE s g 0")
+	tc.do("XREADGROUP GROUP g alice STREAMS s >")
+	tc.do("XREADGROUP GROUP g alice STREAMS s 0")
+
+	got := tc.do("XPENDING s g - + 1 alice")
+	if !strings.HasPrefix(got, "*1\r\n*4\r\n$3\r\n1-0\r\n$5\r\nalice\r\n:") || !strings.HasSuffix(got, ":2\r\n") {
+		t.Errorf("XPENDING should report the entry re-read from history as delivered twice, got %q", got)
+	}
+	if got := tc.do("XPENDING s g IDLE 3600000 - + 10"); got != "*0\r\n" {
+		t.Errorf("Nothing should be idle for an hour, got %q", got)
+	}
+}
+
+// TestXClaimForce checks that FORCE creates the pending entries of IDs
+// never delivered, counting them as delivered once.
+func TestXClaimForce(t *testing.T) {
+	tc := newTestClient()
+	tc.do("XADD s 1-0 a 1")
+	tc.do("XADD s 2-0 b 2")
+	tc.do("XGROUP CREATE s g $")
+
+	if got := tc.do("XCLAIM s g alice 0 1-0 JUSTID"); got != "*0\r\n" {
+		t.Errorf("Without FORCE, an entry never delivered should not be claimed, got %q", got)
+	}
+	if got := tc.do("XCLAIM s g alice 0 1-0 3-0 FORCE JUSTID"); got != "*1\r\n$3\r\n1-0\r\n" {
+		t.Errorf("FORCE should only claim the IDs in the stream, got %q", got)
+	}
+	tc.do("XCLAIM s g bob 0 2-0 FORCE")
+
+	got := tc.do("XPENDING s g - + 10")
+	want := []string{"$3\r\n1-0\r\n$5\r\nalice\r\n:", ":1\r\n", "$3\r\n2-0\r\n$3\r\nbob\r\n:", ":2\r\n"}
+	for _, part := range want {
+		if !strings.Contains(got, part) {
+			t.Fatalf("Expected alice to have 1-0 delivered once and bob 2-0 twice, got %q", got)
+		}
+	}
+}
+
+// TestPendingOrder walks the pending list from cursors while acks,
+// re-deliveries and trimmed entries change it.
+func TestPendingOrder(t *testing.T) {
+	tc := newTestClient()
+	for i := 1; i <= 6; i++ {
+		tc.do(fmt.Sprintf("XADD s %d-0 f v", i))
+	}
+	tc.do("XGROUP CREATE s g 0")
+	tc.do("XREADGROUP GROUP g alice STREAMS s >")
+	tc.do("XACK s g 2-0")
+	// trimming leaves 1-0 and 3-0 pending but gone from the stream
+	tc.do("XADD s MAXLEN 4 7-0 f v")
+	// delivering 4-0 again replaces its pending entry
+	tc.do("XGROUP SETID s g 0")
+	tc.do("XREADGROUP GROUP g bob COUNT 1 STREAMS s >")
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"XPENDING s g - + 10 bob", "*1\r\n*4\r\n$3\r\n4-0\r\n$3\r\nbob\r\n"},
+		{"XAUTOCLAIM s g carol 0 0 COUNT 2 JUSTID", "*3\r\n$3\r\n6-0\r\n*2\r\n$3\r\n4-0\r\n$3\r\n5-0\r\n*2\r\n$3\r\n1-0\r\n$3\r\n3-0\r\n"},
+		{"XAUTOCLAIM s g carol 0 6-0 COUNT 2 JUSTID", "*3\r\n$3\r\n0-0\r\n*1\r\n$3\r\n6-0\r\n*0\r\n"},
+		{"XPENDING s g", "*4\r\n:3\r\n$3\r\n4-0\r\n$3\r\n6-0\r\n*1\r\n*2\r\n$5\r\ncarol\r\n$1\r\n3\r\n"},
+	}
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+	if got := tc.do("XPENDING s g 4-0 5-0 10"); strings.Count(got, "carol") != 2 {
+		t.Errorf("Expected 4-0 and 5-0 from XPENDING 4-0 5-0, got %q", got)
+	}
+}
+
+// TestStreamPropagation checks that replaying what the AOF receives
+// rebuilds the same stream and consumer group state.
+func TestStreamPropagation(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+
+	tc.do("XADD s * a 1")
+	tc.do("XADD s * b 2")
+	tc.do("XADD s MAXLEN 5 * c 3")
+	tc.do("XGROUP CREATE s g $")
+	tc.do("XGROUP SETID s g 0")
+	tc.do("XREADGROUP GROUP g alice COUNT 2 STREAMS s >")
+	tc.do("XREADGROUP GROUP g alice STREAMS s 0")
+	tc.do("XCLAIM s g bob 0 " + firstID(tc))
+	tc.do("XREADGROUP GROUP g carol NOACK STREAMS s >")
+
+	for _, cmd := range aof {
+		if string(cmd[0]) == "XADD" && strings.Contains(string(cmd[len(cmd)-3]), "*") {
+			t.Errorf("XADD should be propagated with the generated ID, got %q", cmd)
+		}
+	}
+
+	replay := newTestClient()
+	for _, cmd := range aof {
+		parts := make([]string, len(cmd))
+		for i, arg := range cmd {
+			parts[i] = string(arg)
+		}
+		replay.doArgs(parts...)
+	}
+
+	for _, cmd := range []string{"XRANGE s - +", "XPENDING s g", "XREADGROUP GROUP g dave STREAMS s >"} {
+		if want, got := tc.do(cmd), replay.do(cmd); got != want {
+			t.Errorf("%s after replay:\n got %q\nwant %q", cmd, got, want)
+		}
+	}
+}
+
+func firstID(tc *testClient) string {
+	entries, _ := tc.DB.XRange("s", database.StreamID{}, database.MaxStreamID, false, 1)
+	return entries[0].ID.String()
+}
+
+func TestXReadBlocks(t *testing.T) {
+	db := database.NewStore()
+	reader := newTestClientOn(db)
+	writer := newTestClientOn(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader.Ctx = ctx
+
+	done := make(chan string)
+	go func() {
+		done <- reader.do("XREAD BLOCK 0 STREAMS s $")
+	}()
+
+	// give the reader time to park, XREAD must not see entries added before it
+	time.Sleep(20 * time.Millisecond)
+	writer.do("XADD s 1-0 a 1")
+
+	select {
+	case got := <-done:
+		if !strings.Contains(got, "$3\r\n1-0\r\n") {
+			t.Errorf("Blocked XREAD should return the new entry, got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("XADD did not wake up the blocked XREAD")
+	}
+
+	go func() {
+		done <- reader.do("XREAD BLOCK 0 STREAMS s $")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case got := <-done:
+		if got != "*-1\r\n" {
+			t.Errorf("A cancelled XREAD should reply with a null array, got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Cancelling the client did not unblock XREAD")
+	}
+
+	reader.Ctx = context.Background()
+	start := time.Now()
+	if got := reader.do("XREAD BLOCK 30 STREAMS s $"); got != "*-1\r\n" {
+		t.Errorf("XREAD should time out with a null array, got %q", got)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("XREAD BLOCK 30 returned after %v", elapsed)
+	}
+}
@@ -0,0 +1,358 @@
+package core
+
+import (
+	"math"
+	"redis-lite/pkg/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// set implements SET key value [NX | XX] [GET] [EX seconds | PX milliseconds |
+// EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL].
+func set(c *Client, args [][]byte) bool {
+	var opts database.SetArgs
+	expiry := false
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); opt {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GET":
+			opts.Get = true
+		case "KEEPTTL":
+			opts.KeepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if expiry || i+1 == len(args) {
+				return fail(c, errSyntax)
+			}
+			at, msg := parseExpireTime(opt, args[i+1], "set")
+			if msg != "" {
+				return fail(c, msg)
+			}
+			opts.At, expiry = at, true
+			i++
+		default:
+			// AOF files written before the options existed hold SET key value ttl
+			ttl, ok := legacyTTL(args, 4)
+			if i != 3 || !ok {
+				return fail(c, errSyntax)
+			}
+			opts.At, expiry = time.Now().Add(ttl), true
+		}
+	}
+	if opts.NX && opts.XX || opts.KeepTTL && expiry {
+		return fail(c, errSyntax)
+	}
+
+	old, stored, err := c.DB.SetWith(string(args[1]), args[2], opts)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	// the conditions are settled and relative times would move on replay,
+	// so the AOF gets a plain SET with an absolute deadline
+	if stored {
+		cmd := [][]byte{[]byte("SET"), args[1], args[2]}
+		switch {
+		case expiry:
+			cmd = append(cmd, []byte("PXAT"), strconv.AppendInt(nil, opts.At.UnixMilli(), 10))
+		case opts.KeepTTL:
+			cmd = append(cmd, []byte("KEEPTTL"))
+		}
+		c.propagateAs(cmd)
+	} else {
+		c.propagateAs()
+	}
+
+	switch {
+	case opts.Get:
+		writeStringOrNull(c, old)
+	case stored:
+		c.W.WriteOK()
+	default:
+		c.W.WriteNull()
+	}
+	return true
+}
+
+// legacyTTL returns the ttl older versions appended to SET key value, LPUSH
+// key value and HSET key field value, which their AOF files still hold: a
+// positive Go duration like 10s ending args, when there are n of them.
+// ParseDuration only takes a number without unit for 0, which is rejected.
+func legacyTTL(args [][]byte, n int) (time.Duration, bool) {
+	if len(args) != n {
+		return 0, false
+	}
+	ttl, err := time.ParseDuration(string(args[n-1]))
+	return ttl, err == nil && ttl > 0
+}
+
+// parseExpireTime parses the time given to the EX, PX, EXAT or PXAT option
+// of cmd into a deadline. It returns an error message when the time is invalid.
+func parseExpireTime(opt string, arg []byte, cmd string) (time.Time, string) {
+	n, ok := parseInt(arg)
+	if !ok {
+		return time.Time{}, errNotInteger
+	}
+
+	unit := time.Second
+	if opt == "PX" || opt == "PXAT" {
+		unit = time.Millisecond
+	}
+	at, ok := toDeadline(n, unit, opt == "EXAT" || opt == "PXAT")
+	if n <= 0 || !ok {
+		return time.Time{}, "ERR invalid expire time in '" + cmd + "' command"
+	}
+	return at, ""
+}
+
+// get implements GET key.
+func get(c *Client, args [][]byte) bool {
+	val, found := c.DB.Get(string(args[1]))
+	if !found {
+		c.W.WriteNull()
+		return true
+	}
+	strVal, ok := val.([]byte)
+	if !ok {
+		return fail(c, database.ErrWrongType.Error())
+	}
+	c.W.WriteBulk(strVal)
+	return true
+}
+
+// incrGeneric implements INCR, DECR, INCRBY and DECRBY, adding delta to key.
+func incrGeneric(c *Client, key []byte, delta int64) bool {
+	n, err := c.DB.IncrBy(string(key), delta)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(n)
+	return true
+}
+
+// incr implements INCR key.
+func incr(c *Client, args [][]byte) bool {
+	return incrGeneric(c, args[1], 1)
+}
+
+// decr implements DECR key.
+func decr(c *Client, args [][]byte) bool {
+	return incrGeneric(c, args[1], -1)
+}
+
+// incrby implements INCRBY key increment.
+func incrby(c *Client, args [][]byte) bool {
+	delta, ok := parseInt(args[2])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+	return incrGeneric(c, args[1], delta)
+}
+
+// decrby implements DECRBY key decrement.
+func decrby(c *Client, args [][]byte) bool {
+	delta, ok := parseInt(args[2])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+	if delta == math.MinInt64 {
+		return fail(c, "ERR decrement would overflow")
+	}
+	return incrGeneric(c, args[1], -delta)
+}
+
+// incrbyfloat implements INCRBYFLOAT key increment.
+func incrbyfloat(c *Client, args [][]byte) bool {
+	delta, ok := parseFloat(args[2])
+	if !ok {
+		return fail(c, errNotFloat)
+	}
+
+	val, err := c.DB.IncrByFloat(string(args[1]), delta)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteBulk(val)
+	return true
+}
+
+// appendCmd implements APPEND key value.
+func appendCmd(c *Client, args [][]byte) bool {
+	n, err := c.DB.Append(string(args[1]), args[2])
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// strlen implements STRLEN key.
+func strlen(c *Client, args [][]byte) bool {
+	n, err := c.DB.StrLen(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// getrange implements GETRANGE key start end.
+func getrange(c *Client, args [][]byte) bool {
+	start, ok1 := parseInt(args[2])
+	end, ok2 := parseInt(args[3])
+	if !ok1 || !ok2 {
+		return fail(c, errNotInteger)
+	}
+
+	val, err := c.DB.GetRange(string(args[1]), int(start), int(end))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteBulk(val)
+	return true
+}
+
+// setrange implements SETRANGE key offset value.
+func setrange(c *Client, args [][]byte) bool {
+	offset, ok := parseInt(args[2])
+	if !ok {
+		return fail(c, errNotInteger)
+	}
+	if offset < 0 {
+		return fail(c, "ERR offset is out of range")
+	}
+	if offset > database.MaxStringLen {
+		return fail(c, database.ErrStringTooBig.Error())
+	}
+
+	n, err := c.DB.SetRange(string(args[1]), int(offset), args[3])
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// mget implements MGET key [key ...].
+func mget(c *Client, args [][]byte) bool {
+	values := c.DB.MGet(argStrings(args[1:]))
+
+	c.W.WriteArray(len(values))
+	for _, v := range values {
+		if v == nil {
+			c.W.WriteNull()
+		} else {
+			c.W.WriteBulk(v)
+		}
+	}
+	return true
+}
+
+// keyValuePairs parses the key value [key value ...] arguments of MSET and MSETNX.
+func keyValuePairs(c *Client, args [][]byte) ([]database.KeyValue, bool) {
+	if len(args)%2 == 0 {
+		fail(c, "ERR wrong number of arguments for '"+strings.ToLower(string(args[0]))+"' command")
+		return nil, false
+	}
+
+	pairs := make([]database.KeyValue, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		pairs = append(pairs, database.KeyValue{Key: string(args[i]), Value: args[i+1]})
+	}
+	return pairs, true
+}
+
+// mset implements MSET key value [key value ...].
+func mset(c *Client, args [][]byte) bool {
+	pairs, ok := keyValuePairs(c, args)
+	if !ok {
+		return false
+	}
+	c.DB.MSet(pairs)
+	c.W.WriteOK()
+	return true
+}
+
+// msetnx implements MSETNX key value [key value ...].
+func msetnx(c *Client, args [][]byte) bool {
+	pairs, ok := keyValuePairs(c, args)
+	if !ok {
+		return false
+	}
+	writeBoolInteger(c, c.DB.MSetNX(pairs))
+	return true
+}
+
+// writeStringOrNull writes val, or a null reply when the key was missing.
+func writeStringOrNull(c *Client, val []byte) {
+	if val == nil {
+		c.W.WriteNull()
+	} else {
+		c.W.WriteBulk(val)
+	}
+}
+
+// getset implements GETSET key value.
+func getset(c *Client, args [][]byte) bool {
+	old, err := c.DB.GetSet(string(args[1]), args[2])
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeStringOrNull(c, old)
+	return true
+}
+
+// getdel implements GETDEL key.
+func getdel(c *Client, args [][]byte) bool {
+	val, err := c.DB.GetDel(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeStringOrNull(c, val)
+	return true
+}
+
+// getex implements GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds |
+// PXAT unix-time-milliseconds | PERSIST].
+func getex(c *Client, args [][]byte) bool {
+	var opts database.GetExArgs
+	switch {
+	case len(args) == 2:
+		// nothing changes, there is nothing to propagate
+		c.propagateAs()
+	case len(args) == 3 && strings.ToUpper(string(args[2])) == "PERSIST":
+		opts.Persist = true
+	case len(args) == 4:
+		opt := strings.ToUpper(string(args[2]))
+		if opt != "EX" && opt != "PX" && opt != "EXAT" && opt != "PXAT" {
+			return fail(c, errSyntax)
+		}
+		at, msg := parseExpireTime(opt, args[3], "getex")
+		if msg != "" {
+			return fail(c, msg)
+		}
+		opts.At = at
+	default:
+		return fail(c, errSyntax)
+	}
+
+	val, err := c.DB.GetEx(string(args[1]), opts)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	switch {
+	case val == nil:
+		c.propagateAs()
+	case opts.Persist:
+		c.propagateAs([][]byte{[]byte("PERSIST"), args[1]})
+	case !opts.At.IsZero():
+		c.propagateAs(expireCommand(args[1], opts.At))
+	}
+
+	writeStringOrNull(c, val)
+	return true
+}
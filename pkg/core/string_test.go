@@ -0,0 +1,261 @@
+package core
+
+import (
+	"bytes"
+	"redis-lite/pkg/database"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestStringCommands replays the examples of the Redis documentation for each string command.
+func TestStringCommands(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// INCR, DECR, INCRBY, DECRBY
+		{"SET mykey 10", "+OK\r\n"},
+		{"INCR mykey", ":11\r\n"},
+		{"GET mykey", "$2\r\n11\r\n"},
+		{"DECR mykey", ":10\r\n"},
+		{"INCRBY mykey 5", ":15\r\n"},
+		{"DECRBY mykey 3", ":12\r\n"},
+		{"INCR counter", ":1\r\n"},
+		{"SET big 9223372036854775807", "+OK\r\n"},
+		{"INCR big", "-ERR increment or decrement would overflow\r\n"},
+		{"SET small -9223372036854775808", "+OK\r\n"},
+		{"DECR small", "-ERR increment or decrement would overflow\r\n"},
+		{"SET notanumber 234293482390480948029348230948", "+OK\r\n"},
+		{"INCR notanumber", "-ERR value is not an integer or out of range\r\n"},
+		{"SET word abc", "+OK\r\n"},
+		{"INCR word", "-ERR value is not an integer or out of range\r\n"},
+
+		// INCRBYFLOAT
+		{"SET float 10.50", "+OK\r\n"},
+		{"INCRBYFLOAT float 0.1", "$4\r\n10.6\r\n"},
+		{"INCRBYFLOAT float -5", "$3\r\n5.6\r\n"},
+		{"SET float 5.0e3", "+OK\r\n"},
+		{"INCRBYFLOAT float 2.0e2", "$4\r\n5200\r\n"},
+		{"INCRBYFLOAT float inf", "-ERR increment would produce NaN or Infinity\r\n"},
+		{"INCRBYFLOAT word 1", "-ERR value is not a valid float\r\n"},
+
+		// APPEND, STRLEN
+		{"APPEND greeting Hello", ":5\r\n"},
+		{"APPEND greeting World", ":10\r\n"},
+		{"GET greeting", "$10\r\nHelloWorld\r\n"},
+		{"STRLEN greeting", ":10\r\n"},
+		{"STRLEN nonexisting", ":0\r\n"},
+
+		// GETRANGE
+		{"SET mystr This_is_a_string", "+OK\r\n"},
+		{"GETRANGE mystr 0 3", "$4\r\nThis\r\n"},
+		{"GETRANGE mystr -3 -1", "$3\r\ning\r\n"},
+		{"GETRANGE mystr 0 -1", "$16\r\nThis_is_a_string\r\n"},
+		{"GETRANGE mystr 10 100", "$6\r\nstring\r\n"},
+		{"GETRANGE mystr 5 3", "$0\r\n\r\n"},
+		{"GETRANGE nonexisting 0 -1", "$0\r\n\r\n"},
+
+		// SETRANGE
+		{"SET key1 Hello_World", "+OK\r\n"},
+		{"SETRANGE key1 6 Redis", ":11\r\n"},
+		{"GET key1", "$11\r\nHello_Redis\r\n"},
+		{"SETRANGE key2 6 Redis", ":11\r\n"},
+		{"GET key2", "$11\r\n\x00\x00\x00\x00\x00\x00Redis\r\n"},
+		{"SETRANGE key1 536870912 x", "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n"},
+
+		// MGET, MSET, MSETNX
+		{"MSET m1 Hello m2 World", "+OK\r\n"},
+		{"MGET m1 m2 nonexisting", "*3\r\n$5\r\nHello\r\n$5\r\nWorld\r\n$-1\r\n"},
+		{"MSETNX m3 Hello m4 there", ":1\r\n"},
+		{"MSETNX m4 there m5 World", ":0\r\n"},
+		{"MGET m3 m4 m5", "*3\r\n$5\r\nHello\r\n$5\r\nthere\r\n$-1\r\n"},
+		{"MSET m1 a m1 b", "+OK\r\n"},
+		{"GET m1", "$1\r\nb\r\n"},
+
+		// GETSET, GETDEL
+		{"INCR gs", ":1\r\n"},
+		{"GETSET gs 0", "$1\r\n1\r\n"},
+		{"GET gs", "$1\r\n0\r\n"},
+		{"GETSET gs2 v", "$-1\r\n"},
+		{"GETDEL gs", "$1\r\n0\r\n"},
+		{"GET gs", "$-1\r\n"},
+
+		// GETEX
+		{"GETEX greeting", "$10\r\nHelloWorld\r\n"},
+		{"GETEX greeting EX 60", "$10\r\nHelloWorld\r\n"},
+		{"GETEX greeting PERSIST", "$10\r\nHelloWorld\r\n"},
+		{"GETEX greeting EXAT 1", "$10\r\nHelloWorld\r\n"},
+		{"GET greeting", "$-1\r\n"},
+		{"GETEX greeting EX 0", "-ERR invalid expire time in 'getex' command\r\n"},
+		{"GETEX nonexisting PX 10", "$-1\r\n"},
+
+		// errors
+		{"LPUSH list a", ":1\r\n"},
+		{"INCR list", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"APPEND list a", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"GETDEL list", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"MGET list", "*1\r\n$-1\r\n"},
+	}
+
+	tc := newTestClient()
+	// SETRANGE with an empty value doesn't create the key
+	if got := tc.doArgs("SETRANGE", "key3", "6", ""); got != ":0\r\n" {
+		t.Errorf("SETRANGE key3 6 \"\": got %q", got)
+	}
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestIncrIsAtomic runs concurrent INCRs, which used to need a GET and a SET.
+func TestIncrIsAtomic(t *testing.T) {
+	db := database.NewStore()
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tc := newTestClientOn(db)
+			for i := 0; i < 100; i++ {
+				tc.do("INCR counter")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := newTestClientOn(db).do("GET counter"); got != "$4\r\n1000\r\n" {
+		t.Errorf("Expected 1000 increments, got %q", got)
+	}
+}
+
+// TestIncrKeepsTTL checks that the counter commands don't make a key persistent.
+func TestIncrKeepsTTL(t *testing.T) {
+	tc := newTestClient()
+	tc.do("SET n 1 PX 20")
+	tc.do("INCR n")
+	tc.do("APPEND n 0")
+	tc.do("SETRANGE n 0 3")
+	time.Sleep(30 * time.Millisecond)
+
+	if got := tc.do("GET n"); got != "$-1\r\n" {
+		t.Errorf("The key should have expired, got %q", got)
+	}
+	if got := tc.do("INCRBY n 5"); got != ":5\r\n" {
+		t.Errorf("INCRBY on an expired key: got %q", got)
+	}
+}
+
+// TestSetOptions replays the examples of the Redis documentation for the options of SET.
+func TestSetOptions(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"SET mykey Hello", "+OK\r\n"},
+		{"SET anotherkey will_expire_in_a_minute EX 60", "+OK\r\n"},
+		{"GETEX anotherkey", "$23\r\nwill_expire_in_a_minute\r\n"},
+
+		// NX, XX
+		{"SET mykey World NX", "$-1\r\n"},
+		{"SET newkey World NX", "+OK\r\n"},
+		{"SET missing World XX", "$-1\r\n"},
+		{"GET missing", "$-1\r\n"},
+		{"SET mykey World XX", "+OK\r\n"},
+
+		// GET
+		{"SET mykey Again GET", "$5\r\nWorld\r\n"},
+		{"SET fresh v GET", "$-1\r\n"},
+		{"SET mykey Other NX GET", "$5\r\nAgain\r\n"},
+		{"GET mykey", "$5\r\nAgain\r\n"},
+
+		// expiration
+		{"SET gone v PX 1", "+OK\r\n"},
+		{"SET past v EXAT 1", "+OK\r\n"},
+		{"GET past", "$-1\r\n"},
+		{"SET future v PXAT 4000000000000", "+OK\r\n"},
+		{"GET future", "$1\r\nv\r\n"},
+		// deadlines past 2262 are cut to the last one that fits
+		{"SET far v EX 100000000000", "+OK\r\n"},
+		{"PEXPIRETIME far", ":9223372036854\r\n"},
+
+		// errors
+		{"SET k v NX XX", "-ERR syntax error\r\n"},
+		{"SET k v EX 10 PX 10", "-ERR syntax error\r\n"},
+		{"SET k v EX 10 KEEPTTL", "-ERR syntax error\r\n"},
+		{"SET k v EX", "-ERR syntax error\r\n"},
+		{"SET k v EX ten", "-ERR value is not an integer or out of range\r\n"},
+		{"SET k v EX 0", "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET k v PX -1", "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET k v EX 9223372036854775807", "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET k v EX 10 bogus", "-ERR syntax error\r\n"},
+		{"SET k v 0", "-ERR syntax error\r\n"},
+		{"SET k v 0s", "-ERR syntax error\r\n"},
+		{"SET k v -5s", "-ERR syntax error\r\n"},
+		{"EXISTS k", ":0\r\n"},
+		{"LPUSH list a", ":1\r\n"},
+		{"SET list v GET", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"SET list v", "+OK\r\n"},
+	}
+
+	tc := newTestClient()
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// TestSetKeepTTL checks that KEEPTTL keeps the deadline of the key replaced,
+// and that a plain SET drops it.
+func TestSetKeepTTL(t *testing.T) {
+	tc := newTestClient()
+	tc.do("SET a 1 PX 20")
+	tc.do("SET a 2 KEEPTTL")
+	tc.do("SET b 1 PX 20")
+	tc.do("SET b 2")
+	time.Sleep(30 * time.Millisecond)
+
+	if got := tc.do("GET a"); got != "$-1\r\n" {
+		t.Errorf("SET KEEPTTL should keep the deadline, got %q", got)
+	}
+	if got := tc.do("GET b"); got != "$1\r\n2\r\n" {
+		t.Errorf("SET should drop the deadline, got %q", got)
+	}
+}
+
+// TestSetPropagation checks that SET reaches the AOF with an absolute
+// deadline, and not at all when its condition failed.
+func TestSetPropagation(t *testing.T) {
+	tc := newTestClient()
+	var aof [][][]byte
+	tc.Propagate = func(_ int, cmds ...[][]byte) {
+		aof = append(aof, cmds...)
+	}
+
+	before := time.Now().Add(10 * time.Second).UnixMilli()
+	tc.do("SET k v EX 10 NX GET")
+	tc.do("SET k w NX")
+	tc.do("SET k w XX KEEPTTL")
+	tc.do("SET legacy v 10s")
+
+	if len(aof) != 3 {
+		t.Fatalf("Expected 3 propagated commands, got %q", aof)
+	}
+	cmd := aof[0]
+	if len(cmd) != 5 || string(cmd[3]) != "PXAT" {
+		t.Fatalf("Expected SET k v PXAT <ms>, got %q", cmd)
+	}
+	if at, _ := strconv.ParseInt(string(cmd[4]), 10, 64); at < before || at > before+1000 {
+		t.Errorf("PXAT %d is not 10s from now", at)
+	}
+	if got := string(bytes.Join(aof[1], []byte(" "))); got != "SET k w KEEPTTL" {
+		t.Errorf("Propagated %q, want SET k w KEEPTTL", got)
+	}
+	if string(aof[2][3]) != "PXAT" {
+		t.Errorf("The legacy ttl should be propagated as PXAT, got %q", aof[2])
+	}
+}
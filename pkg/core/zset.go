@@ -0,0 +1,400 @@
+package core
+
+import (
+	"redis-lite/pkg/database"
+	"redis-lite/pkg/resp"
+	"strings"
+)
+
+const (
+	errNotFloatRange = "ERR min or max is not a float"
+	errNotLexRange   = "ERR min or max not valid string range item"
+)
+
+// zadd implements ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...].
+func zadd(c *Client, args [][]byte) bool {
+	var flags database.ZAddFlags
+	incr := false
+
+	i := 2
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			flags.NX = true
+		case "XX":
+			flags.XX = true
+		case "GT":
+			flags.GT = true
+		case "LT":
+			flags.LT = true
+		case "CH":
+			flags.CH = true
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
+
+	pairs := args[i:]
+	switch {
+	case len(pairs) == 0 || len(pairs)%2 != 0:
+		return fail(c, errSyntax)
+	case flags.NX && flags.XX:
+		return fail(c, "ERR XX and NX options at the same time are not compatible")
+	case flags.GT && flags.LT || flags.NX && (flags.GT || flags.LT):
+		return fail(c, "ERR GT, LT, and/or NX options at the same time are not compatible")
+	case incr && len(pairs) > 2:
+		return fail(c, "ERR INCR option supports a single increment-element pair")
+	}
+
+	members := make([]database.ZMember, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, ok := parseFloat(pairs[j])
+		if !ok {
+			return fail(c, errNotFloat)
+		}
+		members = append(members, database.ZMember{Member: string(pairs[j+1]), Score: score})
+	}
+
+	if incr {
+		score, applied, err := c.DB.ZIncrBy(string(args[1]), flags, members[0].Member, members[0].Score)
+		if err != nil {
+			return fail(c, err.Error())
+		}
+		if !applied {
+			c.W.WriteNull()
+			return true
+		}
+		c.W.WriteDouble(score)
+		return true
+	}
+
+	n, err := c.DB.ZAdd(string(args[1]), flags, members)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// zincrby implements ZINCRBY key increment member.
+func zincrby(c *Client, args [][]byte) bool {
+	incr, ok := parseFloat(args[2])
+	if !ok {
+		return fail(c, errNotFloat)
+	}
+	score, _, err := c.DB.ZIncrBy(string(args[1]), database.ZAddFlags{}, string(args[3]), incr)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteDouble(score)
+	return true
+}
+
+// zrem implements ZREM key member [member ...].
+func zrem(c *Client, args [][]byte) bool {
+	members := make([]string, len(args)-2)
+	for i, m := range args[2:] {
+		members[i] = string(m)
+	}
+	removed, err := c.DB.ZRem(string(args[1]), members)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(removed))
+	return true
+}
+
+// zcard implements ZCARD key.
+func zcard(c *Client, args [][]byte) bool {
+	n, err := c.DB.ZCard(string(args[1]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
+
+// zscore implements ZSCORE key member.
+func zscore(c *Client, args [][]byte) bool {
+	score, found, err := c.DB.ZScore(string(args[1]), string(args[2]))
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	if !found {
+		c.W.WriteNull()
+		return true
+	}
+	c.W.WriteDouble(score)
+	return true
+}
+
+// zscan implements ZSCAN key cursor [MATCH pattern] [COUNT count].
+func zscan(c *Client, args [][]byte) bool {
+	cursor, opts, msg := parseScan(args, 2, false)
+	if msg != "" {
+		return fail(c, msg)
+	}
+
+	next, members, err := c.DB.ZScan(string(args[1]), cursor, opts)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	writeScanCursor(c, next, 2*len(members))
+	for _, m := range members {
+		c.W.WriteBulkString(m.Member)
+		c.W.WriteDouble(m.Score)
+	}
+	return true
+}
+
+// zrank implements ZRANK key member [WITHSCORE].
+func zrank(c *Client, args [][]byte) bool {
+	return zrankGeneric(c, args, false)
+}
+
+// zrevrank implements ZREVRANK key member [WITHSCORE].
+func zrevrank(c *Client, args [][]byte) bool {
+	return zrankGeneric(c, args, true)
+}
+
+func zrankGeneric(c *Client, args [][]byte, reverse bool) bool {
+	withScore := false
+	if len(args) == 4 {
+		if !strings.EqualFold(string(args[3]), "WITHSCORE") {
+			return fail(c, errSyntax)
+		}
+		withScore = true
+	} else if len(args) > 4 {
+		return fail(c, errSyntax)
+	}
+
+	rank, score, found, err := c.DB.ZRank(string(args[1]), string(args[2]), reverse)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+
+	switch {
+	case !found && withScore:
+		c.W.WriteNullArray()
+	case !found:
+		c.W.WriteNull()
+	case withScore:
+		c.W.WriteArray(2)
+		c.W.WriteInteger(int64(rank))
+		c.W.WriteDouble(score)
+	default:
+		c.W.WriteInteger(int64(rank))
+	}
+	return true
+}
+
+// Options accepted after the range of the ZRANGE family. ZRANGE takes them all,
+// the older commands fix BYSCORE/BYLEX and REV through their name.
+const (
+	zrangeBy = 1 << iota
+	zrangeRev
+	zrangeLimit
+	zrangeWithScores
+)
+
+// zrangeRequest is a parsed ZRANGE-style call.
+type zrangeRequest struct {
+	spec       database.ZRangeSpec
+	withScores bool
+}
+
+// parseZRange parses "min max [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]"
+// for a command accepting the options in allowed. by and rev are the defaults
+// implied by the command name. It returns an error message on failure.
+func parseZRange(args [][]byte, by database.ZRangeBy, rev bool, allowed int) (zrangeRequest, string) {
+	req := zrangeRequest{}
+	req.spec.Count = -1
+
+	limit := false
+	opts := args[2:]
+	for i := 0; i < len(opts); i++ {
+		opt := strings.ToUpper(string(opts[i]))
+		switch {
+		case opt == "BYSCORE" && allowed&zrangeBy != 0:
+			by = database.ZByScore
+		case opt == "BYLEX" && allowed&zrangeBy != 0:
+			by = database.ZByLex
+		case opt == "REV" && allowed&zrangeRev != 0:
+			rev = true
+		case opt == "WITHSCORES" && allowed&zrangeWithScores != 0:
+			req.withScores = true
+		case opt == "LIMIT" && allowed&zrangeLimit != 0 && i+2 < len(opts):
+			offset, ok1 := parseInt(opts[i+1])
+			count, ok2 := parseInt(opts[i+2])
+			if !ok1 || !ok2 {
+				return req, errNotInteger
+			}
+			limit = true
+			req.spec.Offset, req.spec.Count = int(offset), int(count)
+			i += 2
+		default:
+			return req, errSyntax
+		}
+	}
+
+	if limit && by == database.ZByRank {
+		return req, "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX"
+	}
+	if req.withScores && by == database.ZByLex {
+		return req, "ERR syntax error, WITHSCORES not supported in combination with BYLEX"
+	}
+	if req.spec.Offset < 0 {
+		// Redis returns nothing for a negative offset
+		req.spec.Offset, req.spec.Count = 0, 0
+	}
+
+	req.spec.By, req.spec.Rev = by, rev
+	min, max := args[0], args[1]
+	if rev && by != database.ZByRank {
+		// score and lex ranges are given from max to min when reversed
+		min, max = max, min
+	}
+
+	switch by {
+	case database.ZByRank:
+		start, ok1 := parseInt(min)
+		stop, ok2 := parseInt(max)
+		if !ok1 || !ok2 {
+			return req, errNotInteger
+		}
+		req.spec.Start, req.spec.Stop = int(start), int(stop)
+	case database.ZByScore:
+		var ok1, ok2 bool
+		req.spec.Min, ok1 = parseScoreBound(min)
+		req.spec.Max, ok2 = parseScoreBound(max)
+		if !ok1 || !ok2 {
+			return req, errNotFloatRange
+		}
+	case database.ZByLex:
+		var ok1, ok2 bool
+		req.spec.LexMin, ok1 = parseLexBound(min)
+		req.spec.LexMax, ok2 = parseLexBound(max)
+		if !ok1 || !ok2 {
+			return req, errNotLexRange
+		}
+	}
+
+	return req, ""
+}
+
+// parseScoreBound parses a score range end: 1.5, (1.5, -inf or +inf.
+func parseScoreBound(arg []byte) (database.ScoreBound, bool) {
+	var b database.ScoreBound
+	if len(arg) > 0 && arg[0] == '(' {
+		b.Exclusive = true
+		arg = arg[1:]
+	}
+	value, ok := parseFloat(arg)
+	b.Value = value
+	return b, ok
+}
+
+// parseLexBound parses a lexicographical range end: [a, (a, - or +.
+func parseLexBound(arg []byte) (database.LexBound, bool) {
+	if len(arg) == 0 {
+		return database.LexBound{}, false
+	}
+	switch arg[0] {
+	case '-':
+		return database.LexBound{Inf: -1}, len(arg) == 1
+	case '+':
+		return database.LexBound{Inf: 1}, len(arg) == 1
+	case '(':
+		return database.LexBound{Value: string(arg[1:]), Exclusive: true}, true
+	case '[':
+		return database.LexBound{Value: string(arg[1:])}, true
+	}
+	return database.LexBound{}, false
+}
+
+// zrangeGeneric runs a ZRANGE-style read on args[1] and writes the members.
+func zrangeGeneric(c *Client, args [][]byte, by database.ZRangeBy, rev bool, allowed int) bool {
+	req, msg := parseZRange(args[2:], by, rev, allowed)
+	if msg != "" {
+		return fail(c, msg)
+	}
+	members, err := c.DB.ZRange(string(args[1]), req.spec)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	writeZMembers(c, members, req.withScores)
+	return true
+}
+
+// writeZMembers replies with members, followed by their scores when asked:
+// flat in RESP2, as [member, score] pairs in RESP3.
+func writeZMembers(c *Client, members []database.ZMember, withScores bool) {
+	switch {
+	case !withScores:
+		c.W.WriteArray(len(members))
+		for _, m := range members {
+			c.W.WriteBulkString(m.Member)
+		}
+	case c.W.Protocol() == resp.RESP3:
+		c.W.WriteArray(len(members))
+		for _, m := range members {
+			c.W.WriteArray(2)
+			c.W.WriteBulkString(m.Member)
+			c.W.WriteDouble(m.Score)
+		}
+	default:
+		c.W.WriteArray(2 * len(members))
+		for _, m := range members {
+			c.W.WriteBulkString(m.Member)
+			c.W.WriteDouble(m.Score)
+		}
+	}
+}
+
+// zrange implements ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES].
+func zrange(c *Client, args [][]byte) bool {
+	return zrangeGeneric(c, args, database.ZByRank, false, zrangeBy|zrangeRev|zrangeLimit|zrangeWithScores)
+}
+
+// zrevrange implements ZREVRANGE key start stop [WITHSCORES].
+func zrevrange(c *Client, args [][]byte) bool {
+	return zrangeGeneric(c, args, database.ZByRank, true, zrangeWithScores)
+}
+
+// zrangebyscore implements ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count].
+func zrangebyscore(c *Client, args [][]byte) bool {
+	return zrangeGeneric(c, args, database.ZByScore, false, zrangeLimit|zrangeWithScores)
+}
+
+// zrevrangebyscore implements ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count].
+func zrevrangebyscore(c *Client, args [][]byte) bool {
+	return zrangeGeneric(c, args, database.ZByScore, true, zrangeLimit|zrangeWithScores)
+}
+
+// zrangebylex implements ZRANGEBYLEX key min max [LIMIT offset count].
+func zrangebylex(c *Client, args [][]byte) bool {
+	return zrangeGeneric(c, args, database.ZByLex, false, zrangeLimit)
+}
+
+// zrevrangebylex implements ZREVRANGEBYLEX key max min [LIMIT offset count].
+func zrevrangebylex(c *Client, args [][]byte) bool {
+	return zrangeGeneric(c, args, database.ZByLex, true, zrangeLimit)
+}
+
+// zrangestore implements ZRANGESTORE dst src min max [BYSCORE|BYLEX] [REV] [LIMIT offset count].
+func zrangestore(c *Client, args [][]byte) bool {
+	req, msg := parseZRange(args[3:], database.ZByRank, false, zrangeBy|zrangeRev|zrangeLimit)
+	if msg != "" {
+		return fail(c, msg)
+	}
+	n, err := c.DB.ZRangeStore(string(args[1]), string(args[2]), req.spec)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	c.W.WriteInteger(int64(n))
+	return true
+}
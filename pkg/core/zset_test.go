@@ -0,0 +1,120 @@
+package core
+
+import "testing"
+
+func TestSortedSetCommands(t *testing.T) {
+	tc := newTestClient()
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"ZADD board 10 alice 20 bob 30 carol", ":3\r\n"},
+		{"ZADD board 10 alice 25 bob", ":0\r\n"},
+		{"ZADD board CH 10 alice 20 bob", ":1\r\n"},
+		{"ZADD board NX 99 alice 5 dave", ":1\r\n"},
+		{"ZADD board XX 1 erin", ":0\r\n"},
+		{"ZADD board GT CH 1 alice 40 bob", ":1\r\n"},
+		{"ZADD board LT CH 50 carol", ":0\r\n"},
+		{"ZSCORE board bob", "$2\r\n40\r\n"},
+		{"ZADD board INCR 0.5 dave", "$3\r\n5.5\r\n"},
+		{"ZADD board XX INCR 1 nobody", "$-1\r\n"},
+		{"ZINCRBY board 1234557 alice", "$7\r\n1234567\r\n"},
+		{"ZINCRBY board -1234567 alice", "$1\r\n0\r\n"},
+		{"ZCARD board", ":4\r\n"},
+
+		// alice 0, dave 5.5, carol 30, bob 40
+		{"ZRANGE board 0 -1", "*4\r\n$5\r\nalice\r\n$4\r\ndave\r\n$5\r\ncarol\r\n$3\r\nbob\r\n"},
+		{"ZRANGE board 0 1 REV WITHSCORES", "*4\r\n$3\r\nbob\r\n$2\r\n40\r\n$5\r\ncarol\r\n$2\r\n30\r\n"},
+		{"ZREVRANGE board -1 -1", "*1\r\n$5\r\nalice\r\n"},
+		{"ZRANGE board 2 100", "*2\r\n$5\r\ncarol\r\n$3\r\nbob\r\n"},
+		{"ZRANGE board 3 1", "*0\r\n"},
+		{"ZRANGEBYSCORE board (0 30", "*2\r\n$4\r\ndave\r\n$5\r\ncarol\r\n"},
+		{"ZRANGEBYSCORE board -inf +inf LIMIT 1 2", "*2\r\n$4\r\ndave\r\n$5\r\ncarol\r\n"},
+		{"ZRANGEBYSCORE board -inf +inf LIMIT -1 2", "*0\r\n"},
+		{"ZREVRANGEBYSCORE board (40 5.5", "*2\r\n$5\r\ncarol\r\n$4\r\ndave\r\n"},
+		{"ZRANGE board 40 (5.5 BYSCORE REV LIMIT 0 1", "*1\r\n$3\r\nbob\r\n"},
+		{"ZRANK board carol", ":2\r\n"},
+		{"ZREVRANK board carol WITHSCORE", "*2\r\n:1\r\n$2\r\n30\r\n"},
+		{"ZRANK board nobody", "$-1\r\n"},
+		{"ZREM board alice nobody", ":1\r\n"},
+		{"ZREM board dave carol bob", ":3\r\n"},
+		{"ZCARD board", ":0\r\n"},
+		{"ZINCRBY board +inf x", "$3\r\ninf\r\n"},
+		{"ZINCRBY board -inf x", "-ERR resulting score is not a number (NaN)\r\n"},
+	}
+
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestSortedSetLexRanges(t *testing.T) {
+	tc := newTestClient()
+	tc.do("ZADD letters 0 a 0 b 0 c 0 d")
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"ZRANGEBYLEX letters - +", "*4\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n"},
+		{"ZRANGEBYLEX letters (a [c", "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"ZRANGEBYLEX letters [b + LIMIT 1 1", "*1\r\n$1\r\nc\r\n"},
+		{"ZREVRANGEBYLEX letters (d -", "*3\r\n$1\r\nc\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"ZRANGE letters [c - BYLEX REV", "*3\r\n$1\r\nc\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"ZRANGEBYLEX letters a c", "-ERR min or max not valid string range item\r\n"},
+	}
+
+	for _, tt := range tests {
+		if got := tc.do(tt.cmd); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestSortedSetWithScoresRESP3(t *testing.T) {
+	tc := newTestClient()
+	tc.do("HELLO 3")
+	tc.do("ZADD z 1.5 a 2 b")
+
+	want := "*2\r\n*2\r\n$1\r\na\r\n,1.5\r\n*2\r\n$1\r\nb\r\n,2\r\n"
+	if got := tc.do("ZRANGE z 0 -1 WITHSCORES"); got != want {
+		t.Errorf("RESP3 WITHSCORES should nest pairs:\n got %q\nwant %q", got, want)
+	}
+	if got := tc.do("ZSCORE z a"); got != ",1.5\r\n" {
+		t.Errorf("ZSCORE should reply with a RESP3 double, got %q", got)
+	}
+}
+
+func TestZRangeStore(t *testing.T) {
+	tc := newTestClient()
+	tc.do("ZADD src 1 a 2 b 3 c")
+	tc.do("SET dst string")
+
+	if got := tc.do("ZRANGESTORE dst src (1 +inf BYSCORE"); got != ":2\r\n" {
+		t.Fatalf("ZRANGESTORE: got %q", got)
+	}
+	if got := tc.do("ZRANGE dst 0 -1 WITHSCORES"); got != "*4\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n3\r\n" {
+		t.Errorf("ZRANGESTORE should replace dst with the range, got %q", got)
+	}
+
+	if got := tc.do("ZRANGESTORE dst src 10 20 BYSCORE"); got != ":0\r\n" {
+		t.Fatalf("ZRANGESTORE of an empty range: got %q", got)
+	}
+	if got := tc.do("ZCARD dst"); got != ":0\r\n" {
+		t.Errorf("An empty ZRANGESTORE should delete dst, got %q", got)
+	}
+}
+
+func TestSortedSetWrongType(t *testing.T) {
+	tc := newTestClient()
+	tc.do("SET str value")
+
+	for _, cmd := range []string{"ZADD str 1 a", "ZSCORE str a", "ZRANGE str 0 -1", "ZRANGESTORE dst str 0 -1"} {
+		if got := tc.do(cmd); got != "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n" {
+			t.Errorf("%s: expected WRONGTYPE, got %q", cmd, got)
+		}
+	}
+}
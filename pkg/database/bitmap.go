@@ -0,0 +1,446 @@
+package database
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Bitmaps are plain strings: bit 0 is the most significant bit of the first
+// byte, and the bits past the end of a string read as zero. Writes modify the
+// string in place when its item owns it, see string.go.
+
+// MaxBitOffset bounds the offsets of the bitmap commands, so that they never
+// grow a string past MaxStringLen.
+const MaxBitOffset = MaxStringLen*8 - 1
+
+// getBit returns the bit of buf at offset, 0 past its end.
+func getBit(buf []byte, offset int64) byte {
+	i := offset >> 3
+	if i >= int64(len(buf)) {
+		return 0
+	}
+	return buf[i] >> (7 - offset&7) & 1
+}
+
+// setBit sets the bit of buf at offset, which must be in range.
+func setBit(buf []byte, offset int64, bit byte) {
+	mask := byte(1) << (7 - offset&7)
+	if bit == 0 {
+		buf[offset>>3] &^= mask
+	} else {
+		buf[offset>>3] |= mask
+	}
+}
+
+// growString returns the string held by item (empty when nil) zero-padded
+// to at least n bytes, for the caller to modify and store back. It is a copy
+// unless item owns the string.
+func growString(item *Item, n int64) []byte {
+	var val []byte
+	if item != nil {
+		val = item.Value.([]byte)
+		if !item.owned.Load() {
+			val = append(make([]byte, 0, max(int64(len(val)), n)), val...)
+		}
+	}
+	if pad := n - int64(len(val)); pad > 0 {
+		// append doubles the capacity, so that growing a bitmap bit by
+		// bit copies it a logarithmic number of times
+		val = append(val, make([]byte, pad)...)
+	}
+	return val
+}
+
+// SetBit sets the bit at offset of the string at key, zero-padding the string
+// as needed, and returns the bit it replaced.
+func (s *Store) SetBit(key string, offset int64, bit byte) (byte, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := stringForWrite(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	val := growString(item, offset>>3+1)
+	old := getBit(val, offset)
+	setBit(val, offset, bit)
+	storeString(shard, key, item, val)
+	return old, nil
+}
+
+// GetBit returns the bit at offset of the string at key, 0 when missing.
+func (s *Store) GetBit(key string, offset int64) (byte, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	item, err := getString(shard, key)
+	if item == nil {
+		return 0, err
+	}
+	return getBit(item.Value.([]byte), offset), nil
+}
+
+// BitRange selects the part of a string BITCOUNT and BITPOS look at.
+type BitRange struct {
+	// Start and End are inclusive, negative ones counting from the end.
+	Start, End int64
+	// Bits counts Start and End in bits rather than bytes.
+	Bits bool
+}
+
+// bitsOf returns the first and last bits r selects in a string of n bytes,
+// and false when it selects none.
+func (r BitRange) bitsOf(n int) (start, end int64, ok bool) {
+	total := int64(n)
+	if r.Bits {
+		total *= 8
+	}
+	start, end = r.Start, r.End
+	if start < 0 {
+		start = max(start+total, 0)
+	}
+	if end < 0 {
+		end = max(end+total, 0)
+	}
+	end = min(end, total-1)
+	if start > end {
+		return 0, 0, false
+	}
+	if !r.Bits {
+		start, end = start*8, end*8+7
+	}
+	return start, end, true
+}
+
+// BitCount counts the bits set in range r of the string at key.
+func (s *Store) BitCount(key string, r BitRange) (int64, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	item, err := getString(shard, key)
+	if item == nil {
+		return 0, err
+	}
+	val := item.Value.([]byte)
+	start, end, ok := r.bitsOf(len(val))
+	if !ok {
+		return 0, nil
+	}
+
+	// the first and last bytes may only be partly in range
+	first, last := start>>3, end>>3
+	head := byte(0xff) >> (start & 7)
+	tail := byte(0xff) << (7 - end&7)
+	if first == last {
+		return int64(bits.OnesCount8(val[first] & head & tail)), nil
+	}
+	count := bits.OnesCount8(val[first]&head) + bits.OnesCount8(val[last]&tail)
+	for _, b := range val[first+1 : last] {
+		count += bits.OnesCount8(b)
+	}
+	return int64(count), nil
+}
+
+// BitPos returns the position of the first bit set to bit in range r of the
+// string at key, or -1 when there is none. Unless endGiven, the string counts
+// as padded with zeros on the right, so a clear bit is always found.
+func (s *Store) BitPos(key string, bit byte, r BitRange, endGiven bool) (int64, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	item, err := getString(shard, key)
+	if err != nil {
+		return 0, err
+	}
+	if item == nil {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+
+	val := item.Value.([]byte)
+	start, end, ok := r.bitsOf(len(val))
+	if !ok {
+		return -1, nil
+	}
+
+	// whole bytes without the bit are skipped at once
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for pos := start; pos <= end; {
+		if pos&7 == 0 && pos+7 <= end && val[pos>>3] == skip {
+			pos += 8
+			continue
+		}
+		if getBit(val, pos) == bit {
+			return pos, nil
+		}
+		pos++
+	}
+
+	if bit == 0 && !endGiven {
+		return end + 1, nil
+	}
+	return -1, nil
+}
+
+// BitOp is a bitwise operation of BITOP.
+type BitOp int
+
+const (
+	BitAnd BitOp = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// BitOpStore stores at dst the result of op on the strings at keys, missing
+// keys and the ends of shorter strings counting as zero bytes, and returns
+// its length. BitNot takes a single key. An empty result deletes dst.
+func (s *Store) BitOpStore(op BitOp, dst string, keys []string) (int, error) {
+	unlock := s.lockKeys(append([]string{dst}, keys...)...)
+	defer unlock()
+
+	srcs := make([][]byte, len(keys))
+	n := 0
+	for i, key := range keys {
+		item, err := stringForWrite(s.getShard(key), key)
+		if err != nil {
+			return 0, err
+		}
+		if item != nil {
+			srcs[i] = item.Value.([]byte)
+		}
+		n = max(n, len(srcs[i]))
+	}
+
+	shard := s.getShard(dst)
+	if n == 0 {
+		if shard.writeItem(dst) != nil {
+			shard.remove(dst)
+			shard.touch(dst)
+		}
+		return 0, nil
+	}
+
+	res := make([]byte, n)
+	copy(res, srcs[0])
+	for _, src := range srcs[1:] {
+		for i := range res {
+			var b byte
+			if i < len(src) {
+				b = src[i]
+			}
+			switch op {
+			case BitAnd:
+				res[i] &= b
+			case BitOr:
+				res[i] |= b
+			case BitXor:
+				res[i] ^= b
+			}
+		}
+	}
+	if op == BitNot {
+		for i := range res {
+			res[i] = ^res[i]
+		}
+	}
+
+	// like SET, the result replaces any value and its TTL
+	shard.put(dst, &Item{Value: res, Type: TypeString})
+	shard.touch(dst)
+	return n, nil
+}
+
+// BitFieldKind is the subcommand of a BITFIELD operation.
+type BitFieldKind int
+
+const (
+	BitFieldGet BitFieldKind = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// Overflow is how BITFIELD handles values that don't fit their field.
+type Overflow int
+
+const (
+	// OverflowWrap keeps the low bits of the value, like integer arithmetic.
+	OverflowWrap Overflow = iota
+	// OverflowSat clamps the value to the smallest or largest of the field.
+	OverflowSat
+	// OverflowFail leaves the field alone and gives a nil result.
+	OverflowFail
+)
+
+// BitFieldOp is one operation of BITFIELD on the integer of Bits bits at Offset.
+type BitFieldOp struct {
+	Kind BitFieldKind
+	// Signed fields hold up to 64 bits, unsigned ones up to 63.
+	Signed bool
+	Bits   int
+	Offset int64
+	// Value is the value to set or the increment.
+	Value    int64
+	Overflow Overflow
+}
+
+// BitFieldResult is the reply to a BitFieldOp: the value the field held for
+// GET and SET, its new value for INCRBY, Nil when OverflowFail prevented the write.
+type BitFieldResult struct {
+	Value int64
+	Nil   bool
+}
+
+// BitField runs ops in order on the string at key, which is zero-padded to
+// fit the fields written to, even when the writes fail.
+func (s *Store) BitField(key string, ops []BitFieldOp) ([]BitFieldResult, error) {
+	var size int64
+	for _, op := range ops {
+		if op.Kind != BitFieldGet {
+			size = max(size, (op.Offset+int64(op.Bits)+7)>>3)
+		}
+	}
+
+	shard := s.getShard(key)
+	if size == 0 {
+		s.rlock(shard)
+		defer s.runlock(shard)
+
+		item, err := getString(shard, key)
+		if err != nil {
+			return nil, err
+		}
+		var val []byte
+		if item != nil {
+			val = item.Value.([]byte)
+		}
+		return runBitField(val, ops), nil
+	}
+
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := stringForWrite(shard, key)
+	if err != nil {
+		return nil, err
+	}
+	val := growString(item, size)
+	results := runBitField(val, ops)
+	storeString(shard, key, item, val)
+	return results, nil
+}
+
+// runBitField runs ops on val, which is long enough for the writes.
+func runBitField(val []byte, ops []BitFieldOp) []BitFieldResult {
+	results := make([]BitFieldResult, len(ops))
+	for i, op := range ops {
+		old := getField(val, op)
+		switch op.Kind {
+		case BitFieldGet:
+			results[i].Value = old
+		case BitFieldSet:
+			v, ok := fitField(op.Value, 0, op)
+			if !ok {
+				results[i].Nil = true
+				continue
+			}
+			setField(val, op, v)
+			results[i].Value = old
+		case BitFieldIncrBy:
+			v, ok := fitField(old, op.Value, op)
+			if !ok {
+				results[i].Nil = true
+				continue
+			}
+			setField(val, op, v)
+			results[i].Value = v
+		}
+	}
+	return results
+}
+
+// getField reads the field of op in val, sign-extending signed ones.
+func getField(val []byte, op BitFieldOp) int64 {
+	var u uint64
+	for i := range int64(op.Bits) {
+		u = u<<1 | uint64(getBit(val, op.Offset+i))
+	}
+	if op.Signed && op.Bits < 64 && u>>(op.Bits-1) != 0 {
+		u |= math.MaxUint64 << op.Bits
+	}
+	return int64(u)
+}
+
+// setField writes the low bits of v to the field of op in val.
+func setField(val []byte, op BitFieldOp, v int64) {
+	u := uint64(v)
+	for i := range int64(op.Bits) {
+		setBit(val, op.Offset+i, byte(u>>(int64(op.Bits)-1-i)&1))
+	}
+}
+
+// fitField returns value+incr as it must be stored in the field of op,
+// following its overflow policy, or false when the operation fails.
+// For unsigned fields value is taken as a uint64, like Redis does, so
+// setting a negative value overflows.
+func fitField(value, incr int64, op BitFieldOp) (int64, bool) {
+	// over is 1 when the result is too large, -1 when too small
+	over := 0
+	var maxv, minv int64
+	if op.Signed {
+		maxv = math.MaxInt64 >> (64 - op.Bits)
+		minv = -maxv - 1
+		sum := value + incr
+		switch {
+		case incr > 0 && sum < value:
+			over = 1
+		case incr < 0 && sum > value:
+			over = -1
+		case sum > maxv:
+			over = 1
+		case sum < minv:
+			over = -1
+		}
+	} else {
+		maxv = int64(uint64(math.MaxUint64) >> (64 - op.Bits))
+		u := uint64(value)
+		switch {
+		case u > uint64(maxv):
+			over = 1
+		case incr >= 0 && uint64(incr) > uint64(maxv)-u:
+			over = 1
+		case incr < 0 && uint64(-(incr+1))+1 > u:
+			over = -1
+		}
+	}
+
+	if over == 0 {
+		return value + incr, true
+	}
+	switch op.Overflow {
+	case OverflowSat:
+		if over > 0 {
+			return maxv, true
+		}
+		return minv, true
+	case OverflowFail:
+		return 0, false
+	}
+
+	// wrap around: keep the low bits, sign-extended for signed fields
+	u := uint64(value+incr) & (math.MaxUint64 >> (64 - op.Bits))
+	if op.Signed && u>>(op.Bits-1) != 0 {
+		u |= math.MaxUint64 << op.Bits
+	}
+	return int64(u), true
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// TestBitRanges compares BitCount and BitPos with a bit-by-bit scan over
+// random strings and ranges, in bytes and in bits.
+func TestBitRanges(t *testing.T) {
+	s := NewStore()
+	rng := rand.New(rand.NewSource(1))
+
+	for range 500 {
+		val := make([]byte, rng.Intn(6))
+		for i := range val {
+			// mostly all-zero or all-one bytes, which BitPos skips whole
+			switch rng.Intn(3) {
+			case 0:
+				val[i] = 0xff
+			case 1:
+				val[i] = byte(rng.Intn(256))
+			}
+		}
+		s.Set("key", val, 0)
+
+		r := BitRange{Start: int64(rng.Intn(60) - 30), End: int64(rng.Intn(60) - 30), Bits: rng.Intn(2) == 0}
+		start, end, ok := r.bitsOf(len(val))
+		var count int64
+		first := [2]int64{-1, -1}
+		for pos := start; ok && pos <= end; pos++ {
+			bit := getBit(val, pos)
+			count += int64(bit)
+			if first[bit] < 0 {
+				first[bit] = pos
+			}
+		}
+
+		if got, _ := s.BitCount("key", r); got != count {
+			t.Errorf("BitCount(%x, %+v) = %d, want %d", val, r, got, count)
+		}
+		for bit := range byte(2) {
+			if got, _ := s.BitPos("key", bit, r, true); got != first[bit] {
+				t.Errorf("BitPos(%x, %d, %+v) = %d, want %d", val, bit, r, got, first[bit])
+			}
+		}
+	}
+}
+
+// TestSetBitInPlace checks that SetBit modifies the strings the store owns in
+// place, and copies those shared with a caller first.
+func TestSetBitInPlace(t *testing.T) {
+	s := NewStore()
+	arg := []byte{0}
+	s.Set("key", arg, 0)
+	s.SetBit("key", 7, 1)
+	if arg[0] != 0 {
+		t.Error("SetBit modified the argument the string was stored from")
+	}
+
+	// a megabyte-long bitmap is not copied by each write
+	s.SetBit("big", 8<<20-1, 1)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := range int64(100) {
+		s.SetBit("big", i, 1)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 64<<10 {
+		t.Errorf("100 SetBit on an owned string allocated %d bytes", n)
+	}
+
+	val, _ := s.Get("key")
+	s.SetBit("key", 0, 1)
+	if val.([]byte)[0] != 0x01 {
+		t.Error("SetBit modified a string already returned by Get")
+	}
+
+	old, _, _ := s.SetWith("key", []byte("new"), SetArgs{NX: true, Get: true})
+	s.SetBit("key", 1, 1)
+	if old[0] != 0x81 {
+		t.Error("SetBit modified a string already returned by SET NX GET")
+	}
+
+	s.Copy("key", s, "copy", false)
+	s.SetBit("copy", 2, 1)
+	if got, _ := s.Get("key"); got.([]byte)[0] != 0xc1 {
+		t.Errorf("SetBit on a copy changed the source to %x", got)
+	}
+}
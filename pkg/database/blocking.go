@@ -0,0 +1,139 @@
+package database
+
+import "sync"
+
+// Waiter is a client parked on some keys by a blocking command (XREAD BLOCK, BLPOP...).
+// Writers signal it through Ready when one of the keys may have new data.
+// A client reading the data (XREAD) then retries its command and waits
+// again if it got nothing. A client consuming it (BLPOP...) is handed an
+// element by the writer instead, under the shard lock, so that no other
+// client can take it first: waiters are queued per key in arrival order and
+// an element pushed goes to the first consumer not served yet.
+type Waiter struct {
+	keys []string
+	// Ready has room for one signal, so a write happening between the
+	// client's last attempt and its wait is never lost.
+	Ready chan struct{}
+
+	// consumer is set for the waiters of BlockPop, which pop the lists at
+	// their keys from the head when left, from the tail otherwise.
+	consumer, left bool
+
+	// mu guards what the consumer was handed: the writers of all its keys
+	// may serve it, the first one does.
+	mu     sync.Mutex
+	served bool
+	key    string
+	val    []byte
+}
+
+// Block registers a waiter on keys. It must be called before the client
+// checks for data, and every call must be paired with Unblock.
+func (s *Store) Block(keys ...string) *Waiter {
+	w := &Waiter{
+		keys:  keys,
+		Ready: make(chan struct{}, 1),
+	}
+	s.register(w)
+	return w
+}
+
+// BlockPop registers a consumer on the lists at keys for BLPOP (left),
+// BRPOP and BLMOVE. It is served an element right away when one of them
+// has some, else by the next push. Like Block, every call must be paired
+// with Unblock; BPop and BMove return what it was served.
+func (s *Store) BlockPop(keys []string, left bool) *Waiter {
+	w := &Waiter{
+		keys:     keys,
+		Ready:    make(chan struct{}, 1),
+		consumer: true,
+		left:     left,
+	}
+	s.register(w)
+	return w
+}
+
+func (s *Store) register(w *Waiter) {
+	for _, key := range w.keys {
+		shard := s.getShard(key)
+		s.lock(shard)
+		shard.blocked[key] = append(shard.blocked[key], w)
+		if w.consumer {
+			shard.handOff(key, w)
+		}
+		s.unlock(shard)
+	}
+}
+
+// Unblock removes the waiter from the keys it was registered on. A
+// consumer is served nothing once it returns.
+func (s *Store) Unblock(w *Waiter) {
+	for _, key := range w.keys {
+		shard := s.getShard(key)
+		s.lock(shard)
+
+		queue := shard.blocked[key]
+		for i, other := range queue {
+			if other == w {
+				queue = append(queue[:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(shard.blocked, key)
+		} else {
+			shard.blocked[key] = queue
+		}
+
+		s.unlock(shard)
+	}
+}
+
+// Served reports whether the consumer w was handed an element.
+func (w *Waiter) Served() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.served
+}
+
+// popped returns the key the consumer w was served from and the element,
+// ok being false while it wasn't served.
+func (w *Waiter) popped() (key string, val []byte, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.key, w.val, w.served
+}
+
+// signalWaiters wakes every client blocked on key, handing the elements of
+// the list at key to the consumers in line as long as it has some.
+// The caller holds the shard write lock.
+func (shard *Shard) signalWaiters(key string) {
+	for _, w := range shard.blocked[key] {
+		if w.consumer && !shard.handOff(key, w) {
+			continue
+		}
+		select {
+		case w.Ready <- struct{}{}:
+		default:
+			// already signalled, the client hasn't retried yet
+		}
+	}
+}
+
+// handOff pops an element of the list at key for the consumer w unless it
+// was served already, and reports whether it did. The caller holds the
+// shard write lock.
+func (shard *Shard) handOff(key string, w *Waiter) bool {
+	l, err := listForWrite(shard, key)
+	if err != nil || l == nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.served {
+		return false
+	}
+	w.served, w.key, w.val = true, key, popList(shard, key, l, w.left)
+	return true
+}
@@ -0,0 +1,134 @@
+package database
+
+// Databases are the numbered keyspaces clients switch between with SELECT.
+// They share a single pub/sub bus, which ignores database numbers like in Redis.
+type Databases []*Store
+
+// NewDatabases creates n empty databases.
+func NewDatabases(n int) Databases {
+	dbs := make(Databases, n)
+	for i := range dbs {
+		dbs[i] = NewStore()
+		dbs[i].index = i
+		if i > 0 {
+			dbs[i].PubSub = dbs[0].PubSub
+		}
+	}
+	return dbs
+}
+
+// lockAcross write-locks the shards owning srcKey in src and dstKey in dst,
+// two views of the same database or of different ones, and returns the
+// function releasing them. Locks are taken in database order, then shard
+// order, so that commands spanning databases can't deadlock each other.
+func lockAcross(src *Store, srcKey string, dst *Store, dstKey string) (unlock func()) {
+	if src.index == dst.index {
+		return src.lockKeys(srcKey, dstKey)
+	}
+	if src.index > dst.index {
+		unlockDst := dst.lockKeys(dstKey)
+		unlockSrc := src.lockKeys(srcKey)
+		return func() {
+			unlockSrc()
+			unlockDst()
+		}
+	}
+	unlockSrc := src.lockKeys(srcKey)
+	unlockDst := dst.lockKeys(dstKey)
+	return func() {
+		unlockDst()
+		unlockSrc()
+	}
+}
+
+// Move moves key, with its TTL, from s to the database to, unless it is
+// missing from s or already exists in to. It reports whether it moved.
+func (s *Store) Move(key string, to *Store) bool {
+	unlock := lockAcross(s, key, to, key)
+	defer unlock()
+
+	srcShard, dstShard := s.getShard(key), to.getShard(key)
+	item := srcShard.writeItem(key)
+	if item == nil || dstShard.writeItem(key) != nil {
+		return false
+	}
+
+	srcShard.remove(key)
+	srcShard.touch(key)
+	dstShard.put(key, item)
+	dstShard.touch(key)
+	dstShard.signalWaiters(key)
+	return true
+}
+
+// Swap exchanges the contents of databases i and j, so that the clients
+// using one see the keys of the other. WATCHed keys and blocked clients stay
+// with their database number: they are notified of the keys that changed.
+func (d Databases) Swap(i, j int) {
+	if i == j {
+		return
+	}
+	a, b := d[min(i, j)], d[max(i, j)]
+	unlockA := a.lockAll()
+	defer unlockA()
+	unlockB := b.lockAll()
+	defer unlockB()
+
+	// a key lives in the shard of the same index in every database
+	for n := range a.Shards {
+		sa, sb := a.Shards[n], b.Shards[n]
+		sa.Items, sb.Items = sb.Items, sa.Items
+		sa.index, sb.index = sb.index, sa.index
+		for _, pair := range [][2]*Shard{{sa, sb}, {sb, sa}} {
+			shard, other := pair[0], pair[1]
+			for key := range shard.watched {
+				if shard.Items[key] != nil || other.Items[key] != nil {
+					shard.touch(key)
+				}
+			}
+			for key := range shard.blocked {
+				if shard.Items[key] != nil {
+					shard.signalWaiters(key)
+				}
+			}
+		}
+	}
+}
+
+// Flush deletes every key of every database at once, like Store.Flush.
+func (d Databases) Flush() {
+	unlocks := make([]func(), len(d))
+	for i, db := range d {
+		unlocks[i] = db.lockAll()
+	}
+	for _, db := range d {
+		db.clear()
+	}
+	for i := len(unlocks) - 1; i >= 0; i-- {
+		unlocks[i]()
+	}
+}
+
+// WithLocked runs fn while holding the write locks of every shard of every
+// database, taken in database then shard order. fn receives views of the
+// databases whose methods skip those locks, like Store.WithLocked does, so
+// that everything fn does, across databases, looks like a single step to
+// other clients.
+func (d Databases) WithLocked(fn func(views Databases)) {
+	held := make(map[*Shard]bool, len(d)*ShardCount)
+	for _, db := range d {
+		unlock := db.lockAll()
+		defer unlock()
+		for _, shard := range db.Shards {
+			held[shard] = true
+		}
+	}
+
+	views := make(Databases, len(d))
+	for i, db := range d {
+		view := *db
+		view.held = mergeHeld(db.held, held)
+		views[i] = &view
+	}
+	fn(views)
+}
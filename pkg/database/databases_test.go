@@ -0,0 +1,57 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMove(t *testing.T) {
+	dbs := NewDatabases(2)
+	dbs[0].Set("key", []byte("v"), time.Minute)
+	dbs[1].Set("taken", []byte("v"), 0)
+	dbs[0].Set("taken", []byte("v"), 0)
+	version := dbs[1].Watch("key")
+
+	if !dbs[0].Move("key", dbs[1]) {
+		t.Fatal("Expected the key to move")
+	}
+	if _, found := dbs[0].Get("key"); found {
+		t.Error("Expected the key to leave its database")
+	}
+	if _, ok := dbs[1].ExpireTime("key"); !ok {
+		t.Error("Expected the key to keep its TTL")
+	}
+	if !dbs[1].Modified("key", version) {
+		t.Error("Moving should mark the key watched in the destination as modified")
+	}
+
+	if dbs[0].Move("taken", dbs[1]) {
+		t.Error("A key existing in the destination should not move")
+	}
+	if dbs[0].Move("missing", dbs[1]) {
+		t.Error("A missing key should not move")
+	}
+}
+
+func TestSwapTouchesWatchedKeys(t *testing.T) {
+	dbs := NewDatabases(3)
+	dbs[0].Set("a", []byte("0"), 0)
+	dbs[2].Set("b", []byte("2"), 0)
+	a := dbs[0].Watch("a")
+	b := dbs[0].Watch("b")
+	c := dbs[0].Watch("c")
+
+	dbs.Swap(2, 0)
+	if _, found := dbs[0].Get("b"); !found {
+		t.Error("Expected database 0 to hold the keys of database 2")
+	}
+	if n := dbs[2].DBSize(); n != 1 {
+		t.Errorf("Expected database 2 to hold the key of database 0, got %d keys", n)
+	}
+	if !dbs[0].Modified("a", a) || !dbs[0].Modified("b", b) {
+		t.Error("Swapping should mark the keys appearing or vanishing as modified")
+	}
+	if dbs[0].Modified("c", c) {
+		t.Error("Swapping should leave keys missing from both databases alone")
+	}
+}
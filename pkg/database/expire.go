@@ -0,0 +1,78 @@
+package database
+
+import "time"
+
+// ExpireFlags are the conditions of the EXPIRE family of commands.
+type ExpireFlags struct {
+	// NX only sets a deadline where there is none, XX only replaces one.
+	NX, XX bool
+	// GT and LT only replace a deadline by a later / earlier one.
+	// No deadline counts as an infinitely late one.
+	GT, LT bool
+}
+
+// allows reports whether the flags let a deadline cur (0 for none) become at.
+func (f ExpireFlags) allows(cur, at int64) bool {
+	switch {
+	case f.NX && cur != 0, f.XX && cur == 0:
+		return false
+	case f.GT && (cur == 0 || at <= cur), f.LT && cur != 0 && at >= cur:
+		return false
+	}
+	return true
+}
+
+// Expire gives key the deadline at, under the conditions of flags, and
+// reports whether it did. A deadline already past deletes the key.
+func (s *Store) Expire(key string, at time.Time, flags ExpireFlags) bool {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item := shard.writeItem(key)
+	if item == nil {
+		return false
+	}
+
+	deadline := at.UnixNano()
+	if !flags.allows(item.ExpiresAt, deadline) {
+		return false
+	}
+
+	if deadline <= time.Now().UnixNano() {
+		shard.remove(key)
+	} else {
+		item.ExpiresAt = deadline
+	}
+	shard.touch(key)
+	return true
+}
+
+// ExpireTime returns the deadline of key in unix nanoseconds, 0 when it has
+// none. ok is false when key is missing.
+func (s *Store) ExpireTime(key string) (deadline int64, ok bool) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	item := shard.readItem(key)
+	if item == nil {
+		return 0, false
+	}
+	return item.ExpiresAt, true
+}
+
+// Persist removes the deadline of key and reports whether it had one.
+func (s *Store) Persist(key string) bool {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item := shard.writeItem(key)
+	if item == nil || item.ExpiresAt == 0 {
+		return false
+	}
+	item.ExpiresAt = 0
+	shard.touch(key)
+	return true
+}
@@ -0,0 +1,520 @@
+package database
+
+import (
+	"errors"
+	"maps"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrHashNotInteger = errors.New("ERR hash value is not an integer")
+	ErrHashNotFloat   = errors.New("ERR hash value is not a float")
+	ErrOverflow       = errors.New("ERR increment or decrement would overflow")
+	ErrNaNOrInfinity  = errors.New("ERR increment would produce NaN or Infinity")
+)
+
+// HashField is a field of a hash with its value.
+type HashField struct {
+	Name  string
+	Value []byte
+}
+
+// Hash is the value of a TypeHash key. Each field may carry its own deadline
+// (HEXPIRE): expired fields are hidden from readers until a writer or the
+// janitor reclaims them.
+type Hash struct {
+	fields map[string][]byte
+	// expires holds the deadline (unix nanoseconds) of the fields that have one.
+	expires map[string]int64
+	// index orders the fields for HSCAN, see scan.go
+	index scanIndex
+}
+
+func newHash() *Hash {
+	return &Hash{fields: make(map[string][]byte)}
+}
+
+// clone returns a copy of the hash sharing nothing mutable with it.
+func (h *Hash) clone() *Hash {
+	return &Hash{fields: maps.Clone(h.fields), expires: maps.Clone(h.expires), index: h.index.clone()}
+}
+
+// get returns the value of field unless it is missing or expired at now.
+func (h *Hash) get(field string, now int64) ([]byte, bool) {
+	val, ok := h.fields[field]
+	if !ok || h.expired(field, now) {
+		return nil, false
+	}
+	return val, true
+}
+
+func (h *Hash) expired(field string, now int64) bool {
+	at, ok := h.expires[field]
+	return ok && now > at
+}
+
+// set stores value in field, dropping its deadline like HSET does, and
+// reports whether the field is new.
+func (h *Hash) set(field string, value []byte) bool {
+	delete(h.expires, field)
+	return h.put(field, value)
+}
+
+// put stores value in field, keeping its deadline, and reports whether the
+// field is new.
+func (h *Hash) put(field string, value []byte) bool {
+	_, exists := h.fields[field]
+	if !exists {
+		h.index.add(field)
+	}
+	h.fields[field] = value
+	return !exists
+}
+
+func (h *Hash) del(field string) bool {
+	if _, exists := h.fields[field]; !exists {
+		return false
+	}
+	delete(h.fields, field)
+	delete(h.expires, field)
+	h.index.remove(field)
+	return true
+}
+
+// allExpired reports whether no field is live at now, which takes every
+// field to have a deadline.
+func (h *Hash) allExpired(now int64) bool {
+	if len(h.expires) < len(h.fields) {
+		return false
+	}
+	for _, at := range h.expires {
+		if now <= at {
+			return false
+		}
+	}
+	return true
+}
+
+// len returns the number of fields live at now.
+func (h *Hash) len(now int64) int {
+	n := len(h.fields)
+	for _, at := range h.expires {
+		if now > at {
+			n--
+		}
+	}
+	return n
+}
+
+// reap deletes the fields expired at now and returns how many there were.
+func (h *Hash) reap(now int64) int {
+	reaped := 0
+	for field, at := range h.expires {
+		if now > at {
+			delete(h.fields, field)
+			delete(h.expires, field)
+			h.index.remove(field)
+			reaped++
+		}
+	}
+	return reaped
+}
+
+// getHash returns the hash at key for reading, nil when missing.
+func getHash(shard *Shard, key string) (*Hash, error) {
+	item := shard.readItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeHash {
+		return nil, ErrWrongType
+	}
+	return item.Value.(*Hash), nil
+}
+
+// hashForWrite returns the hash at key, creating an empty one (not yet
+// stored) when the key is missing. Expired fields are reclaimed on the way,
+// and the key with them when none is left. The shard write lock must be held.
+func hashForWrite(shard *Shard, key string) (*Hash, bool, error) {
+	item := shard.writeItem(key)
+	if item == nil {
+		return newHash(), false, nil
+	}
+	if item.Type != TypeHash {
+		return nil, false, ErrWrongType
+	}
+
+	hash := item.Value.(*Hash)
+	if hash.reap(time.Now().UnixNano()) > 0 && len(hash.fields) == 0 {
+		shard.remove(key)
+		shard.touch(key)
+		return newHash(), false, nil
+	}
+	return hash, true, nil
+}
+
+// HSet sets fields in the hash at key, later fields winning over earlier ones
+// with the same name. It returns the number of fields that were added.
+func (s *Store) HSet(key string, fields ...HashField) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	hash, stored, err := hashForWrite(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, f := range fields {
+		if hash.set(f.Name, f.Value) {
+			added++
+		}
+	}
+
+	if !stored {
+		shard.put(key, &Item{Value: hash, Type: TypeHash})
+	}
+	shard.touch(key)
+	return added, nil
+}
+
+// HSetNX sets field only when it doesn't exist yet, and reports whether it did.
+func (s *Store) HSetNX(key, field string, value []byte) (bool, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	hash, stored, err := hashForWrite(shard, key)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := hash.fields[field]; exists {
+		return false, nil
+	}
+
+	hash.set(field, value)
+	if !stored {
+		shard.put(key, &Item{Value: hash, Type: TypeHash})
+	}
+	shard.touch(key)
+	return true, nil
+}
+
+// HGet returns the value of field in the hash at key.
+func (s *Store) HGet(key, field string) ([]byte, bool, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	hash, err := getHash(shard, key)
+	if hash == nil {
+		return nil, false, err
+	}
+	val, ok := hash.get(field, time.Now().UnixNano())
+	return val, ok, nil
+}
+
+// HMGet returns the values of fields, nil for the missing ones.
+func (s *Store) HMGet(key string, fields []string) ([][]byte, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	hash, err := getHash(shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, len(fields))
+	if hash == nil {
+		return values, nil
+	}
+	now := time.Now().UnixNano()
+	for i, field := range fields {
+		values[i], _ = hash.get(field, now)
+	}
+	return values, nil
+}
+
+// HDel removes fields from the hash at key, and the key with its last field.
+// It returns the number of removed fields.
+func (s *Store) HDel(key string, fields []string) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	hash, stored, err := hashForWrite(shard, key)
+	if !stored {
+		return 0, err
+	}
+
+	removed := 0
+	for _, field := range fields {
+		if hash.del(field) {
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		if len(hash.fields) == 0 {
+			shard.remove(key)
+		}
+		shard.touch(key)
+	}
+	return removed, nil
+}
+
+// HGetAll returns every field of the hash at key, in no particular order.
+func (s *Store) HGetAll(key string) ([]HashField, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	hash, err := getHash(shard, key)
+	if hash == nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	fields := make([]HashField, 0, len(hash.fields))
+	for name, value := range hash.fields {
+		if !hash.expired(name, now) {
+			fields = append(fields, HashField{Name: name, Value: value})
+		}
+	}
+	return fields, nil
+}
+
+// HScan is Scan for the fields of the hash at key.
+func (s *Store) HScan(key string, cursor uint64, args ScanArgs) (uint64, []HashField, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	hash, err := getHash(shard, key)
+	if hash == nil {
+		return 0, nil, err
+	}
+
+	now := time.Now().UnixNano()
+	names, next := hash.index.scan(cursor, args.Count)
+	fields := make([]HashField, 0, len(names))
+	for _, name := range names {
+		if !hash.expired(name, now) && args.keep(name) {
+			fields = append(fields, HashField{Name: name, Value: hash.fields[name]})
+		}
+	}
+	return next, fields, nil
+}
+
+// HLen returns the number of fields of the hash at key.
+func (s *Store) HLen(key string) (int, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	hash, err := getHash(shard, key)
+	if hash == nil {
+		return 0, err
+	}
+	return hash.len(time.Now().UnixNano()), nil
+}
+
+// HExists reports whether field exists in the hash at key.
+func (s *Store) HExists(key, field string) (bool, error) {
+	_, ok, err := s.HGet(key, field)
+	return ok, err
+}
+
+// HIncrBy adds incr to the integer stored in field, a missing field counting
+// as 0, and returns the new value.
+func (s *Store) HIncrBy(key, field string, incr int64) (int64, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	hash, stored, err := hashForWrite(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var cur int64
+	if val, exists := hash.fields[field]; exists {
+		if cur, err = strconv.ParseInt(string(val), 10, 64); err != nil {
+			return 0, ErrHashNotInteger
+		}
+	}
+	if incr > 0 && cur > math.MaxInt64-incr || incr < 0 && cur < math.MinInt64-incr {
+		return 0, ErrOverflow
+	}
+
+	// unlike HSET, incrementing a field keeps its deadline
+	cur += incr
+	hash.put(field, strconv.AppendInt(nil, cur, 10))
+	if !stored {
+		shard.put(key, &Item{Value: hash, Type: TypeHash})
+	}
+	shard.touch(key)
+	return cur, nil
+}
+
+// HIncrByFloat adds incr to the number stored in field, a missing field
+// counting as 0, and returns the new value as stored along with the deadline
+// the field keeps (unix nanoseconds, 0 for none).
+func (s *Store) HIncrByFloat(key, field string, incr float64) ([]byte, int64, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	hash, stored, err := hashForWrite(shard, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var cur float64
+	if val, exists := hash.fields[field]; exists {
+		if cur, err = strconv.ParseFloat(string(val), 64); err != nil || math.IsNaN(cur) {
+			return nil, 0, ErrHashNotFloat
+		}
+	}
+
+	cur += incr
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		return nil, 0, ErrNaNOrInfinity
+	}
+
+	val := strconv.AppendFloat(nil, cur, 'f', -1, 64)
+	hash.put(field, val)
+	if !stored {
+		shard.put(key, &Item{Value: hash, Type: TypeHash})
+	}
+	shard.touch(key)
+	return val, hash.expires[field], nil
+}
+
+// Results of HExpire and HPersist for each field, as HEXPIRE and HPERSIST reply them.
+const (
+	FieldMissing    = -2 // the field (or the whole key) doesn't exist
+	FieldNoTTL      = -1 // HPersist: the field has no deadline
+	FieldNotSet     = 0  // HExpire: the flags ruled the new deadline out
+	FieldUpdated    = 1  // the deadline was set, or removed by HPersist
+	FieldExpiredNow = 2  // HExpire: the deadline is already past, the field was deleted
+)
+
+// HExpire gives fields the deadline at, under the conditions of flags,
+// and returns what happened to each field. A deadline that is already past
+// deletes the field, and the key with its last field.
+func (s *Store) HExpire(key string, at time.Time, flags ExpireFlags, fields []string) ([]int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	results := make([]int, len(fields))
+	hash, stored, err := hashForWrite(shard, key)
+	if !stored {
+		for i := range results {
+			results[i] = FieldMissing
+		}
+		return results, err
+	}
+
+	now, deadline := time.Now().UnixNano(), at.UnixNano()
+	changed := false
+	for i, field := range fields {
+		_, exists := hash.fields[field]
+		switch {
+		case !exists:
+			results[i] = FieldMissing
+		case !flags.allows(hash.expires[field], deadline):
+			results[i] = FieldNotSet
+		case deadline <= now:
+			hash.del(field)
+			results[i] = FieldExpiredNow
+			changed = true
+		default:
+			if hash.expires == nil {
+				hash.expires = make(map[string]int64)
+			}
+			hash.expires[field] = deadline
+			results[i] = FieldUpdated
+			changed = true
+		}
+	}
+
+	if changed {
+		if len(hash.fields) == 0 {
+			shard.remove(key)
+		}
+		shard.touch(key)
+	}
+	return results, nil
+}
+
+// HExpireTime returns the deadline of each of fields in unix nanoseconds,
+// FieldMissing or FieldNoTTL.
+func (s *Store) HExpireTime(key string, fields []string) ([]int64, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	hash, err := getHash(shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	results := make([]int64, len(fields))
+	for i, field := range fields {
+		if hash == nil {
+			results[i] = FieldMissing
+			continue
+		}
+		switch _, ok := hash.get(field, now); {
+		case !ok:
+			results[i] = FieldMissing
+		case hash.expires[field] == 0:
+			results[i] = FieldNoTTL
+		default:
+			results[i] = hash.expires[field]
+		}
+	}
+	return results, nil
+}
+
+// HPersist removes the deadline of fields and returns what happened to each.
+func (s *Store) HPersist(key string, fields []string) ([]int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	results := make([]int, len(fields))
+	hash, stored, err := hashForWrite(shard, key)
+	if !stored {
+		for i := range results {
+			results[i] = FieldMissing
+		}
+		return results, err
+	}
+
+	changed := false
+	for i, field := range fields {
+		switch _, exists := hash.fields[field]; {
+		case !exists:
+			results[i] = FieldMissing
+		case hash.expires[field] == 0:
+			results[i] = FieldNoTTL
+		default:
+			delete(hash.expires, field)
+			results[i] = FieldUpdated
+			changed = true
+		}
+	}
+
+	if changed {
+		shard.touch(key)
+	}
+	return results, nil
+}
@@ -0,0 +1,467 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// HyperLogLogs are strings laid out like in Redis, so GET returns them as
+// Redis would: a 16 byte header ("HYLL", the encoding, 3 unused bytes and
+// the cached cardinality) followed by 16384 registers. The dense encoding
+// packs the registers in 6 bits each; the sparse one, used while few of them
+// are set, run-length encodes them with three opcodes:
+//
+//	00xxxxxx          xxxxxx+1 registers set to 0
+//	01xxxxxx yyyyyyyy xxxxxxyyyyyyyy+1 registers set to 0
+//	1vvvvvxx          xx+1 registers set to vvvvv+1
+//
+// A sparse HyperLogLog becomes dense once a register exceeds 32 or its
+// encoding exceeds hllSparseMaxBytes.
+
+var (
+	ErrNotHLL     = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	ErrCorruptHLL = errors.New("INVALIDOBJ Corrupted HLL object detected")
+)
+
+const (
+	hllP         = 14 // the low hllP bits of a hash pick the register
+	hllQ         = 64 - hllP
+	hllRegisters = 1 << hllP
+	hllBits      = 6
+	hllHeaderLen = 16
+	hllDenseLen  = hllHeaderLen + (hllRegisters*hllBits+7)/8
+
+	hllDense  = 0
+	hllSparse = 1
+
+	hllSparseMaxBytes = 3000
+	hllSparseMaxValue = 32
+	hllSparseMaxXZero = 16384
+	hllSparseMaxZero  = 64
+	hllSparseMaxRun   = 4
+
+	// hllAlphaInf is the bias correction constant of the estimator for an
+	// infinite number of registers.
+	hllAlphaInf = 0.721347520444481703680
+)
+
+// hllHash is MurmurHash64A with the seed Redis uses, so that the elements
+// end up in the same registers as in Redis.
+func hllHash(data []byte) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := uint64(0xadc83b19) ^ uint64(len(data))*m
+
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * i)
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// hllPosition returns the register of element, and the value it proposes
+// for it: the length of the run of zeros at the end of the rest of its
+// hash, plus one.
+func hllPosition(element []byte) (int, byte) {
+	hash := hllHash(element)
+	index := int(hash & (hllRegisters - 1))
+	hash = hash>>hllP | 1<<hllQ
+	return index, byte(bits.TrailingZeros64(hash) + 1)
+}
+
+// checkHLL returns an error unless val looks like a HyperLogLog.
+func checkHLL(val []byte) error {
+	if len(val) < hllHeaderLen || string(val[:4]) != "HYLL" {
+		return ErrNotHLL
+	}
+	switch val[4] {
+	case hllDense:
+		if len(val) != hllDenseLen {
+			return ErrNotHLL
+		}
+	case hllSparse:
+	default:
+		return ErrNotHLL
+	}
+	return nil
+}
+
+// hllRegistersOf decodes the registers of the HyperLogLog val, one per byte,
+// into regs, keeping the largest of the two values of each register.
+func hllRegistersOf(val []byte, regs []byte) error {
+	if err := checkHLL(val); err != nil {
+		return err
+	}
+	data := val[hllHeaderLen:]
+
+	if val[4] == hllDense {
+		for i := range regs {
+			v := denseRegister(data, i)
+			// no hash gives a register more than hllQ+1
+			if v > hllQ+1 {
+				return ErrCorruptHLL
+			}
+			regs[i] = max(regs[i], v)
+		}
+		return nil
+	}
+
+	i := 0
+	for p := 0; p < len(data); p++ {
+		b := data[p]
+		switch {
+		case b&0xc0 == 0:
+			i += int(b&0x3f) + 1
+		case b&0xc0 == 0x40:
+			if p+1 >= len(data) {
+				return ErrCorruptHLL
+			}
+			p++
+			i += (int(b&0x3f)<<8 | int(data[p])) + 1
+		default:
+			v, n := (b>>2)&0x1f+1, int(b&3)+1
+			if i+n > hllRegisters {
+				return ErrCorruptHLL
+			}
+			for j := i; j < i+n; j++ {
+				regs[j] = max(regs[j], v)
+			}
+			i += n
+		}
+		if i > hllRegisters {
+			return ErrCorruptHLL
+		}
+	}
+	if i != hllRegisters {
+		return ErrCorruptHLL
+	}
+	return nil
+}
+
+// denseRegister returns register i of the dense registers data. Registers
+// are stored from the least significant bit of each byte.
+func denseRegister(data []byte, i int) byte {
+	b, shift := i*hllBits/8, uint(i*hllBits%8)
+	v := uint(data[b]) >> shift
+	if b+1 < len(data) {
+		v |= uint(data[b+1]) << (8 - shift)
+	}
+	return byte(v & (1<<hllBits - 1))
+}
+
+// setDenseRegister sets register i of the dense registers data to v.
+func setDenseRegister(data []byte, i int, v byte) {
+	b, shift := i*hllBits/8, uint(i*hllBits%8)
+	mask := uint(1<<hllBits - 1)
+	data[b] = byte(uint(data[b])&^(mask<<shift) | uint(v)<<shift)
+	if b+1 < len(data) {
+		data[b+1] = byte(uint(data[b+1])&^(mask>>(8-shift)) | uint(v)>>(8-shift))
+	}
+}
+
+// encodeHLL returns a HyperLogLog holding regs, sparse unless dense is set
+// or the registers don't fit the sparse encoding. Its cached cardinality is
+// left invalid.
+func encodeHLL(regs []byte, dense bool) []byte {
+	if !dense {
+		if val, ok := encodeSparse(regs); ok {
+			return val
+		}
+	}
+
+	val := make([]byte, hllDenseLen)
+	copy(val, "HYLL")
+	val[4] = hllDense
+	invalidateHLLCache(val)
+	data := val[hllHeaderLen:]
+	for i, v := range regs {
+		if v != 0 {
+			setDenseRegister(data, i, v)
+		}
+	}
+	return val
+}
+
+// encodeSparse is encodeHLL for the sparse encoding, failing when a register
+// is too large for it or the result too long.
+func encodeSparse(regs []byte) ([]byte, bool) {
+	val := make([]byte, hllHeaderLen, hllHeaderLen+64)
+	copy(val, "HYLL")
+	val[4] = hllSparse
+	invalidateHLLCache(val)
+
+	for i := 0; i < len(regs); {
+		v := regs[i]
+		if v > hllSparseMaxValue {
+			return nil, false
+		}
+		run := 1
+		for i+run < len(regs) && regs[i+run] == v {
+			run++
+		}
+		i += run
+
+		for run > 0 {
+			switch {
+			case v != 0:
+				n := min(run, hllSparseMaxRun)
+				val = append(val, 0x80|(v-1)<<2|byte(n-1))
+				run -= n
+			case run <= hllSparseMaxZero:
+				val = append(val, byte(run-1))
+				run = 0
+			default:
+				n := min(run, hllSparseMaxXZero)
+				val = append(val, 0x40|byte((n-1)>>8), byte(n-1))
+				run -= n
+			}
+		}
+		if len(val)-hllHeaderLen > hllSparseMaxBytes {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// The cardinality is cached in the header in little endian, the most
+// significant bit of its last byte flagging it as invalid.
+
+func invalidateHLLCache(val []byte) {
+	val[15] |= 0x80
+}
+
+func cachedHLLCount(val []byte) (int64, bool) {
+	if val[15]&0x80 != 0 {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint64(val[8:16])), true
+}
+
+// hllCount estimates the cardinality of the set observed by regs, with the
+// estimator of Otmar Ertl's "New cardinality estimation algorithms for
+// HyperLogLog sketches", like Redis.
+func hllCount(regs []byte) int64 {
+	// any 6 bit value fits, even one hllRegistersOf would reject
+	var histo [1 << hllBits]int
+	for _, v := range regs {
+		histo[v]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+	return int64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if z == prev {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if z == prev {
+			return z / 3
+		}
+	}
+}
+
+// hllForWrite returns the HyperLogLog at key for callers holding the shard
+// write lock, nil when missing.
+func hllForWrite(shard *Shard, key string) (*Item, error) {
+	item, err := stringForWrite(shard, key)
+	if item == nil {
+		return nil, err
+	}
+	if err := checkHLL(item.Value.([]byte)); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// PFAdd adds elements to the HyperLogLog at key, creating it when missing,
+// and reports whether that changed any register.
+func (s *Store) PFAdd(key string, elements [][]byte) (bool, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := hllForWrite(shard, key)
+	if err != nil {
+		return false, err
+	}
+
+	regs := make([]byte, hllRegisters)
+	var dense []byte
+	if item != nil {
+		val := item.Value.([]byte)
+		if val[4] == hllDense {
+			// dense values are updated without being decoded, in place
+			// unless shared
+			dense = val
+			if !item.owned.Load() {
+				dense = append([]byte(nil), val...)
+			}
+		} else if err := hllRegistersOf(val, regs); err != nil {
+			return false, err
+		}
+	}
+
+	changed := item == nil
+	for _, elem := range elements {
+		i, v := hllPosition(elem)
+		if dense != nil {
+			if v > denseRegister(dense[hllHeaderLen:], i) {
+				setDenseRegister(dense[hllHeaderLen:], i, v)
+				changed = true
+			}
+		} else if v > regs[i] {
+			regs[i] = v
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	val := dense
+	if val != nil {
+		invalidateHLLCache(val)
+	} else {
+		val = encodeHLL(regs, false)
+		if item == nil && len(elements) == 0 {
+			// nothing observed yet: the cache is right
+			clear(val[8:16])
+		}
+	}
+	storeString(shard, key, item, val)
+	return true, nil
+}
+
+// PFCount estimates the number of distinct elements added to the
+// HyperLogLogs at keys, missing keys counting as empty ones. The count of a
+// single key is cached in its header.
+func (s *Store) PFCount(keys []string) (int64, error) {
+	if len(keys) == 1 {
+		return s.pfCountOne(keys[0])
+	}
+
+	runlock := s.rlockKeys(keys...)
+	defer runlock()
+
+	regs := make([]byte, hllRegisters)
+	for _, key := range keys {
+		item, err := getString(s.getShard(key), key)
+		if err != nil {
+			return 0, err
+		}
+		if item == nil {
+			continue
+		}
+		if err := hllRegistersOf(item.Value.([]byte), regs); err != nil {
+			return 0, err
+		}
+	}
+	return hllCount(regs), nil
+}
+
+func (s *Store) pfCountOne(key string) (int64, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := hllForWrite(shard, key)
+	if item == nil {
+		return 0, err
+	}
+	val := item.Value.([]byte)
+	if n, ok := cachedHLLCount(val); ok {
+		return n, nil
+	}
+
+	regs := make([]byte, hllRegisters)
+	if err := hllRegistersOf(val, regs); err != nil {
+		return 0, err
+	}
+	n := hllCount(regs)
+
+	// the registers are unchanged: watchers aren't told about the cache
+	if !item.owned.Load() {
+		val = append([]byte(nil), val...)
+		item.Value = val
+		item.owned.Store(true)
+	}
+	binary.LittleEndian.PutUint64(val[8:16], uint64(n))
+	return n, nil
+}
+
+// PFMerge stores at dst the union of the HyperLogLogs at keys and at dst, if
+// any, keeping the TTL of dst. The result is dense when one of them is.
+func (s *Store) PFMerge(dst string, keys []string) error {
+	unlock := s.lockKeys(append([]string{dst}, keys...)...)
+	defer unlock()
+
+	regs := make([]byte, hllRegisters)
+	dense := false
+	var dstItem *Item
+	for i, key := range append([]string{dst}, keys...) {
+		item, err := hllForWrite(s.getShard(key), key)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			dstItem = item
+		}
+		if item == nil {
+			continue
+		}
+		val := item.Value.([]byte)
+		dense = dense || val[4] == hllDense
+		if err := hllRegistersOf(val, regs); err != nil {
+			return err
+		}
+	}
+
+	storeString(s.getShard(dst), dst, dstItem, encodeHLL(regs, dense))
+	return nil
+}
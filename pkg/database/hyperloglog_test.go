@@ -0,0 +1,175 @@
+package database
+
+import (
+	"bytes"
+	"math"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// hllStdError is the standard error of the estimates with 16384 registers,
+// 1.04/sqrt(16384).
+const hllStdError = 0.0081
+
+// addRange adds the elements prefix0 to prefix<n-1> to the HyperLogLog at
+// key, in batches.
+func addRange(s *Store, key, prefix string, n int) {
+	batch := make([][]byte, 0, 1000)
+	for i := range n {
+		batch = append(batch, []byte(prefix+strconv.Itoa(i)))
+		if len(batch) == cap(batch) || i == n-1 {
+			s.PFAdd(key, batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// TestHLLAccuracy checks that the relative error of the estimates matches
+// the standard error of HyperLogLog, over many sets of distinct elements.
+func TestHLLAccuracy(t *testing.T) {
+	s := NewStore()
+
+	const runs = 50
+	var sumSq float64
+	for run := range runs {
+		key := "hll" + strconv.Itoa(run)
+		addRange(s, key, key+":", 20000)
+		n, _ := s.PFCount([]string{key})
+		e := float64(n)/20000 - 1
+		if math.Abs(e) > 5*hllStdError {
+			t.Errorf("Run %d: estimated %d distinct elements out of 20000", run, n)
+		}
+		sumSq += e * e
+	}
+	if rms := math.Sqrt(sumSq / runs); rms < 0.5*hllStdError || rms > 1.5*hllStdError {
+		t.Errorf("Expected a standard error around %.2f%%, got %.2f%%", hllStdError*100, rms*100)
+	}
+
+	// the estimator stays accurate from tiny to large cardinalities
+	for _, want := range []int{1, 10, 100, 1000, 10000, 100000, 1000000} {
+		key := "sweep" + strconv.Itoa(want)
+		addRange(s, key, key+":", want)
+		n, _ := s.PFCount([]string{key})
+		if e := float64(n)/float64(want) - 1; math.Abs(e) > 4*hllStdError {
+			t.Errorf("Estimated %d distinct elements out of %d", n, want)
+		}
+	}
+}
+
+// TestHLLEncodings checks that HyperLogLogs start sparse, turn dense as they
+// fill up, and that both encodings give the same registers.
+func TestHLLEncodings(t *testing.T) {
+	s := NewStore()
+	for _, n := range []int{0, 10, 1000, 5000} {
+		key := strconv.Itoa(n)
+		s.PFAdd(key, nil)
+		addRange(s, key, "elem", n)
+		got, _ := s.Get(key)
+		val := got.([]byte)
+
+		regs := make([]byte, hllRegisters)
+		if err := hllRegistersOf(val, regs); err != nil {
+			t.Fatalf("%d elements: %v", n, err)
+		}
+		dense := encodeHLL(regs, true)
+		sparse, ok := encodeSparse(regs)
+		if wantSparse := n <= 1000; (val[4] == hllSparse) != wantSparse || ok != wantSparse {
+			t.Errorf("%d elements: unexpected encoding %d", n, val[4])
+		}
+
+		for _, enc := range [][]byte{dense, sparse} {
+			if enc == nil {
+				continue
+			}
+			again := make([]byte, hllRegisters)
+			if err := hllRegistersOf(enc, again); err != nil || !bytes.Equal(regs, again) {
+				t.Errorf("%d elements: encoding %d doesn't round-trip (%v)", n, enc[4], err)
+			}
+		}
+	}
+}
+
+// TestPFAddInPlace checks that PFAdd updates the dense HyperLogLogs the store
+// owns in place, and copies those shared with a caller first.
+func TestPFAddInPlace(t *testing.T) {
+	s := NewStore()
+	addRange(s, "key", "elem", 5000)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := range 100 {
+		s.PFAdd("key", [][]byte{[]byte("more" + strconv.Itoa(i))})
+		s.PFCount([]string{"key"})
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 64<<10 {
+		t.Errorf("100 PFAdd and PFCount on an owned HyperLogLog allocated %d bytes", n)
+	}
+
+	got, _ := s.Get("key")
+	val := got.([]byte)
+	snapshot := append([]byte(nil), val...)
+	if changed, _ := s.PFAdd("key", [][]byte{[]byte("after get")}); !changed {
+		t.Fatal("Expected a new element to change a register")
+	}
+	s.PFCount([]string{"key"})
+	if !bytes.Equal(val, snapshot) {
+		t.Error("PFAdd modified a HyperLogLog already returned by Get")
+	}
+}
+
+// TestHLLUnion checks that PFCOUNT over several keys counts their union,
+// like PFMERGE does.
+func TestHLLUnion(t *testing.T) {
+	s := NewStore()
+	addRange(s, "a", "elem", 3000)
+	addRange(s, "b", "elem", 2000)
+	s.PFAdd("b", [][]byte{[]byte("only in b")})
+
+	union, _ := s.PFCount([]string{"a", "b", "missing"})
+	if e := float64(union)/3001 - 1; math.Abs(e) > 4*hllStdError {
+		t.Errorf("Estimated a union of %d distinct elements out of 3001", union)
+	}
+
+	if err := s.PFMerge("merged", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if merged, _ := s.PFCount([]string{"merged"}); merged != union {
+		t.Errorf("PFMERGE counted %d elements, PFCOUNT %d", merged, union)
+	}
+}
+
+func TestHLLRejectsOtherStrings(t *testing.T) {
+	s := NewStore()
+	s.Set("plain", []byte("not a HyperLogLog"), 0)
+	if _, err := s.PFAdd("plain", nil); err != ErrNotHLL {
+		t.Errorf("Expected ErrNotHLL, got %v", err)
+	}
+
+	s.PFAdd("hll", [][]byte{[]byte("a")})
+	got, _ := s.Get("hll")
+	// one more run of zeros goes past the last register
+	corrupt := append(append([]byte(nil), got.([]byte)...), 0x00)
+	s.Set("corrupt", corrupt, 0)
+	if _, err := s.PFCount([]string{"corrupt"}); err != ErrCorruptHLL {
+		t.Errorf("Expected ErrCorruptHLL, got %v", err)
+	}
+
+	// dense registers set to 63, more than any hash gives
+	dense := make([]byte, hllDenseLen)
+	copy(dense, "HYLL")
+	invalidateHLLCache(dense)
+	for i := hllHeaderLen; i < len(dense); i++ {
+		dense[i] = 0xff
+	}
+	s.Set("dense", dense, 0)
+	for _, keys := range [][]string{{"dense"}, {"dense", "hll"}} {
+		if _, err := s.PFCount(keys); err != ErrCorruptHLL {
+			t.Errorf("PFCount(%q): expected ErrCorruptHLL, got %v", keys, err)
+		}
+	}
+	if err := s.PFMerge("merged", []string{"dense"}); err != ErrCorruptHLL {
+		t.Errorf("Expected PFMerge to reject the dense value, got %v", err)
+	}
+}
@@ -18,14 +18,16 @@ func NewJanitor(config *cfg.Config) *Janitor {
 	}
 }
 
-func (j *Janitor) Run(target *Store) {
+func (j *Janitor) Run(dbs Databases) {
 	ticker := time.NewTicker(j.Interval)
 	slog.Warn("Starting janitor ticker", "the interval of ", j.Interval.String())
 
 	for {
 		select {
 		case <-ticker.C:
-			j.vacuum(target)
+			for _, db := range dbs {
+				j.vacuum(db)
+			}
 		case <-j.stop:
 			j.Stop()
 			return
@@ -38,8 +40,15 @@ func (j *Janitor) vacuum(s *Store) {
 	for _, shard := range s.Shards {
 		shard.Mu.Lock()
 		for key, item := range shard.Items {
-			if item.ExpiresAt > 0 && now > item.ExpiresAt {
-				delete(shard.Items, key)
+			// this includes the hashes left without live fields
+			if item.isExpired(now) {
+				shard.remove(key)
+				shard.touch(key)
+				continue
+			}
+			// hashes also expire field by field
+			if hash, ok := item.Value.(*Hash); ok && hash.reap(now) > 0 {
+				shard.touch(key)
 			}
 		}
 		shard.Mu.Unlock()
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"math/rand/v2"
+	"redis-lite/pkg/glob"
+	"time"
+)
+
+// Exists returns how many of keys exist, a key given twice counting twice.
+func (s *Store) Exists(keys ...string) int {
+	runlock := s.rlockKeys(keys...)
+	defer runlock()
+
+	n := 0
+	for _, key := range keys {
+		if s.getShard(key).readItem(key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Type returns the type name of the value at key, "none" when missing.
+func (s *Store) Type(key string) string {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	item := shard.readItem(key)
+	if item == nil {
+		return "none"
+	}
+	return item.Type.String()
+}
+
+// Rename moves the value at src, with its TTL, to dst, replacing whatever dst
+// held unless onlyNew is set, in which case it only renames to a missing dst.
+// It reports whether it renamed; renaming a key to itself is a no-op that
+// only succeeds without onlyNew, like in Redis.
+func (s *Store) Rename(src, dst string, onlyNew bool) (bool, error) {
+	unlock := s.lockKeys(src, dst)
+	defer unlock()
+
+	srcShard, dstShard := s.getShard(src), s.getShard(dst)
+	item := srcShard.writeItem(src)
+	if item == nil {
+		return false, ErrNoSuchKey
+	}
+	if src == dst {
+		return !onlyNew, nil
+	}
+	if onlyNew && dstShard.writeItem(dst) != nil {
+		return false, nil
+	}
+
+	srcShard.remove(src)
+	srcShard.touch(src)
+	dstShard.put(dst, item)
+	dstShard.touch(dst)
+	dstShard.signalWaiters(dst)
+	return true, nil
+}
+
+// Copy stores a copy of the value at src, with its TTL, at dst in the
+// database to (which may be s), replacing whatever dst held when replace
+// is set. It reports whether it copied: not when src is missing, or dst
+// exists and replace is not set.
+func (s *Store) Copy(src string, to *Store, dst string, replace bool) bool {
+	unlock := lockAcross(s, src, to, dst)
+	defer unlock()
+
+	srcShard, dstShard := s.getShard(src), to.getShard(dst)
+	item := srcShard.writeItem(src)
+	if item == nil {
+		return false
+	}
+	if dstShard.writeItem(dst) != nil && !replace {
+		return false
+	}
+
+	dstShard.put(dst, &Item{Value: cloneValue(item), Type: item.Type, ExpiresAt: item.ExpiresAt})
+	dstShard.touch(dst)
+	dstShard.signalWaiters(dst)
+	return true
+}
+
+// cloneValue returns a copy of the value of item sharing nothing mutable with it.
+func cloneValue(item *Item) interface{} {
+	switch v := item.Value.(type) {
+	case *QuickList:
+		return v.clone()
+	case *Hash:
+		return v.clone()
+	case *Set:
+		return v.clone()
+	case *ZSet:
+		return v.clone()
+	case *Stream:
+		return v.clone()
+	}
+	// strings are copied before being changed once neither item owns them
+	return shareString(item)
+}
+
+// Keys returns the live keys matching the glob pattern. The shards are
+// read-locked one after the other, so it is not a snapshot: keys written
+// while it runs may or may not be returned.
+func (s *Store) Keys(pattern string) []string {
+	var keys []string
+	for _, shard := range s.Shards {
+		keys = s.keysIn(shard, pattern, keys)
+	}
+	return keys
+}
+
+func (s *Store) keysIn(shard *Shard, pattern string, keys []string) []string {
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	now := time.Now().UnixNano()
+	for key, item := range shard.Items {
+		if !item.isExpired(now) && (pattern == "*" || glob.Match(pattern, key)) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// RandomKey returns a random live key, false when there is none.
+// The pick is only roughly uniform: a random shard is tried first, and
+// within a shard the key is the first one map iteration yields.
+func (s *Store) RandomKey() (string, bool) {
+	start := rand.IntN(ShardCount)
+	for i := range ShardCount {
+		if key, ok := s.randomKeyIn(s.Shards[(start+i)%ShardCount]); ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func (s *Store) randomKeyIn(shard *Shard) (string, bool) {
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	for key := range shard.Items {
+		if shard.readItem(key) != nil {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// DBSize returns the number of keys, counting the expired ones the janitor
+// hasn't reaped yet, like Redis does.
+func (s *Store) DBSize() int {
+	n := 0
+	for _, shard := range s.Shards {
+		s.rlock(shard)
+		n += len(shard.Items)
+		s.runlock(shard)
+	}
+	return n
+}
+
+// Flush deletes every key at once. Like Delete, it only drops the keyspace:
+// the garbage collector reclaims the values concurrently, which is what the
+// ASYNC mode of FLUSHDB asks for, so the SYNC mode has nothing left to do.
+func (s *Store) Flush() {
+	unlock := s.lockAll()
+	s.clear()
+	unlock()
+}
+
+// clear deletes every key. The caller holds every shard lock.
+func (s *Store) clear() {
+	for _, shard := range s.Shards {
+		// WATCHers of the flushed keys see them modified
+		for key := range shard.watched {
+			if _, exists := shard.Items[key]; exists {
+				shard.touch(key)
+			}
+		}
+		shard.Items, shard.index = make(map[string]*Item), scanIndex{}
+	}
+}
+
+// lockAll write-locks every shard in index order and returns the function
+// releasing them.
+func (s *Store) lockAll() (unlock func()) {
+	for _, shard := range s.Shards {
+		s.lock(shard)
+	}
+	return func() {
+		for i := len(s.Shards) - 1; i >= 0; i-- {
+			s.unlock(s.Shards[i])
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	ErrNoSuchKey       = errors.New("ERR no such key")
+	ErrIndexOutOfRange = errors.New("ERR index out of range")
+)
+
+// getList returns the list at key for reading, nil when missing.
+func getList(shard *Shard, key string) (*QuickList, error) {
+	item := shard.readItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeList {
+		return nil, ErrWrongType
+	}
+	return item.Value.(*QuickList), nil
+}
+
+// listForWrite is getList for callers holding the shard write lock.
+// Lists are never stored empty, so a nil list means the key is missing.
+func listForWrite(shard *Shard, key string) (*QuickList, error) {
+	item := shard.writeItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeList {
+		return nil, ErrWrongType
+	}
+	return item.Value.(*QuickList), nil
+}
+
+// normalizeRange resolves the inclusive, possibly negative start and stop
+// indexes of LRANGE, LTRIM and ZRANGE against length. ok is false when the
+// range selects nothing.
+func normalizeRange(start, stop, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= length {
+		return 0, 0, false
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	return start, stop, true
+}
+
+// popList removes the head (left) or the tail of l, deleting key once the
+// list is empty. The caller holds the shard write lock and l is not empty.
+func popList(shard *Shard, key string, l *QuickList, left bool) []byte {
+	var val []byte
+	if left {
+		val = l.PopFront()
+	} else {
+		val = l.PopBack()
+	}
+
+	if l.Len() == 0 {
+		shard.remove(key)
+	}
+	shard.touch(key)
+
+	return val
+}
+
+// pushList adds values one after the other at the head (left) or the tail
+// of the list at key, creating it when needed, and wakes the clients blocked
+// on key. The caller holds the shard write lock and has checked the key type.
+func pushList(shard *Shard, key string, left bool, values ...[]byte) int {
+	item := shard.writeItem(key)
+	if item == nil {
+		item = &Item{Value: NewQuickList(), Type: TypeList}
+		shard.put(key, item)
+	}
+
+	l := item.Value.(*QuickList)
+	for _, value := range values {
+		if left {
+			l.PushFront(value)
+		} else {
+			l.PushBack(value)
+		}
+	}
+	shard.touch(key)
+	shard.signalWaiters(key)
+
+	return l.Len()
+}
+
+// LPush inserts values at the head of the list at key, one after the other,
+// so the last one ends up first. It returns the new length of the list.
+func (s *Store) LPush(key string, values ...[]byte) (int, error) {
+	return s.push(key, true, false, values)
+}
+
+// RPush appends values to the list at key and returns its new length.
+func (s *Store) RPush(key string, values ...[]byte) (int, error) {
+	return s.push(key, false, false, values)
+}
+
+// LPushX is LPush for existing lists only, it returns 0 when key is missing.
+func (s *Store) LPushX(key string, values ...[]byte) (int, error) {
+	return s.push(key, true, true, values)
+}
+
+// RPushX is RPush for existing lists only, it returns 0 when key is missing.
+func (s *Store) RPushX(key string, values ...[]byte) (int, error) {
+	return s.push(key, false, true, values)
+}
+
+func (s *Store) push(key string, left, onlyExisting bool, values [][]byte) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	l, err := listForWrite(shard, key)
+	if err != nil || l == nil && onlyExisting {
+		return 0, err
+	}
+	return pushList(shard, key, left, values...), nil
+}
+
+// LPop removes and returns up to count elements from the head of the list
+// at key, nil when the key is missing.
+func (s *Store) LPop(key string, count int) ([][]byte, error) {
+	return s.pop(key, true, count)
+}
+
+// RPop removes and returns up to count elements from the tail of the list at key.
+func (s *Store) RPop(key string, count int) ([][]byte, error) {
+	return s.pop(key, false, count)
+}
+
+func (s *Store) pop(key string, left bool, count int) ([][]byte, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	l, err := listForWrite(shard, key)
+	if l == nil {
+		return nil, err
+	}
+
+	popped := make([][]byte, 0, min(count, l.Len()))
+	for len(popped) < count && l.Len() > 0 {
+		popped = append(popped, popList(shard, key, l, left))
+	}
+	return popped, nil
+}
+
+// LLen returns the length of the list at key, 0 when missing.
+func (s *Store) LLen(key string) (int, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	l, err := getList(shard, key)
+	if l == nil {
+		return 0, err
+	}
+	return l.Len(), nil
+}
+
+// LIndex returns the element at index, negative indexes count from the tail.
+func (s *Store) LIndex(key string, index int) ([]byte, bool, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	l, err := getList(shard, key)
+	if l == nil {
+		return nil, false, err
+	}
+	val, ok := l.Index(index)
+	return val, ok, nil
+}
+
+// LSet replaces the element at index.
+func (s *Store) LSet(key string, index int, value []byte) error {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	l, err := listForWrite(shard, key)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return ErrNoSuchKey
+	}
+	if !l.Set(index, value) {
+		return ErrIndexOutOfRange
+	}
+	shard.touch(key)
+	return nil
+}
+
+// LInsert inserts value before or after the first occurrence of pivot.
+// It returns the new length, -1 when pivot was not found and 0 when key is missing.
+func (s *Store) LInsert(key string, before bool, pivot, value []byte) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	l, err := listForWrite(shard, key)
+	if l == nil {
+		return 0, err
+	}
+
+	at := -1
+	l.Each(false, func(index int, v []byte) bool {
+		if bytes.Equal(v, pivot) {
+			at = index
+			return false
+		}
+		return true
+	})
+	if at < 0 {
+		return -1, nil
+	}
+
+	if !before {
+		at++
+	}
+	l.Insert(at, value)
+	shard.touch(key)
+	return l.Len(), nil
+}
+
+// LRem removes the first count occurrences of value from the head, or from
+// the tail when count is negative, or all of them when count is 0.
+// It returns the number of removed elements.
+func (s *Store) LRem(key string, count int, value []byte) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	l, err := listForWrite(shard, key)
+	if l == nil {
+		return 0, err
+	}
+
+	fromTail := count < 0
+	if fromTail {
+		count = -count
+	}
+
+	removed := l.RemoveEqual(value, count, fromTail)
+	if removed > 0 {
+		if l.Len() == 0 {
+			shard.remove(key)
+		}
+		shard.touch(key)
+	}
+	return removed, nil
+}
+
+// LTrim keeps only the elements between start and stop, both inclusive.
+func (s *Store) LTrim(key string, start, stop int) error {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	l, err := listForWrite(shard, key)
+	if l == nil {
+		return err
+	}
+
+	start, stop, ok := normalizeRange(start, stop, l.Len())
+	if !ok {
+		shard.remove(key)
+		shard.touch(key)
+		return nil
+	}
+
+	l.Trim(start, stop)
+	shard.touch(key)
+	return nil
+}
+
+// LRange returns the elements between start and stop, both inclusive.
+// Negative indexes count from the tail, -1 being the last element.
+func (s *Store) LRange(key string, start, stop int) ([][]byte, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	l, err := getList(shard, key)
+	if l == nil {
+		return nil, err
+	}
+
+	start, stop, ok := normalizeRange(start, stop, l.Len())
+	if !ok {
+		return nil, nil
+	}
+
+	return l.Range(start, stop), nil
+}
+
+// LPosArgs are the options of LPOS.
+type LPosArgs struct {
+	// Rank picks the Rank-th match, counting from the tail when negative. It is never 0.
+	Rank int
+	// Count is the number of matches to return, 0 for all of them.
+	Count int
+	// MaxLen limits how many elements are compared, 0 for the whole list.
+	MaxLen int
+}
+
+// LPos returns the indexes (from the head) of the elements equal to element.
+func (s *Store) LPos(key string, element []byte, args LPosArgs) ([]int, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	l, err := getList(shard, key)
+	if l == nil {
+		return nil, err
+	}
+
+	fromTail := args.Rank < 0
+	skip := args.Rank - 1
+	if fromTail {
+		skip = -args.Rank - 1
+	}
+
+	var matches []int
+	compared := 0
+	l.Each(fromTail, func(index int, v []byte) bool {
+		if args.MaxLen > 0 && compared == args.MaxLen {
+			return false
+		}
+		compared++
+
+		if !bytes.Equal(v, element) {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		matches = append(matches, index)
+		return args.Count == 0 || len(matches) < args.Count
+	})
+	return matches, nil
+}
+
+// LMove atomically pops an element from src (head when srcLeft, tail otherwise)
+// and pushes it to dst (head when dstLeft). ok is false when src is missing.
+func (s *Store) LMove(src, dst string, srcLeft, dstLeft bool) (val []byte, ok bool, err error) {
+	return s.BMove(nil, src, dst, srcLeft, dstLeft)
+}
+
+// BPop pops an element for BLPOP (left) or BRPOP from the first of keys
+// holding a non-empty list, and returns that key with the element. When w
+// is not nil, it returns what the consumer w was served instead, see
+// BlockPop. An empty key means nothing could be popped.
+func (s *Store) BPop(w *Waiter, keys []string, left bool) (string, []byte, error) {
+	if w != nil {
+		key, val, _ := w.popped()
+		return key, val, nil
+	}
+
+	for _, key := range keys {
+		shard := s.getShard(key)
+		s.lock(shard)
+
+		l, err := listForWrite(shard, key)
+		if err != nil {
+			s.unlock(shard)
+			return "", nil, err
+		}
+		if l == nil {
+			s.unlock(shard)
+			continue
+		}
+
+		val := popList(shard, key, l, left)
+		s.unlock(shard)
+		return key, val, nil
+	}
+
+	return "", nil, nil
+}
+
+// BMove is LMove for BLMOVE: like BPop, it moves the element w was served
+// when w is not nil.
+func (s *Store) BMove(w *Waiter, src, dst string, srcLeft, dstLeft bool) (val []byte, ok bool, err error) {
+	unlock := s.lockKeys(src, dst)
+	defer unlock()
+
+	srcShard, dstShard := s.getShard(src), s.getShard(dst)
+
+	if w != nil {
+		_, val, served := w.popped()
+		if !served {
+			return nil, false, nil
+		}
+		// val was popped from src already, it goes back there when dst
+		// doesn't hold a list
+		if _, err := listForWrite(dstShard, dst); err != nil {
+			pushList(srcShard, src, srcLeft, val)
+			return nil, false, err
+		}
+		pushList(dstShard, dst, dstLeft, val)
+		return val, true, nil
+	}
+
+	l, err := listForWrite(srcShard, src)
+	if err != nil || l == nil {
+		return nil, false, err
+	}
+	// the destination type is checked before anything is popped
+	if _, err := listForWrite(dstShard, dst); err != nil {
+		return nil, false, err
+	}
+
+	val = popList(srcShard, src, l, srcLeft)
+	pushList(dstShard, dst, dstLeft, val)
+	return val, true, nil
+}
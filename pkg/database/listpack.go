@@ -0,0 +1,112 @@
+package database
+
+// listpack is one node of a quicklist: a run of list elements stored back
+// to back in a single buffer. Unlike Redis' listpack, which walks per-entry
+// lengths, it keeps the end offset of every element, so reaching the i-th
+// element of a node is O(1) for 4 bytes of overhead.
+type listpack struct {
+	data []byte
+	// ends[i] is the offset in data just past element i
+	ends []uint32
+}
+
+func newListpack(values ...[]byte) *listpack {
+	lp := &listpack{}
+	for _, v := range values {
+		lp.insert(lp.len(), v)
+	}
+	return lp
+}
+
+func (lp *listpack) len() int {
+	return len(lp.ends)
+}
+
+// size is the number of bytes used by the elements.
+func (lp *listpack) size() int {
+	return len(lp.data)
+}
+
+func (lp *listpack) start(i int) int {
+	if i == 0 {
+		return 0
+	}
+	return int(lp.ends[i-1])
+}
+
+// get returns element i. The slice aliases the node buffer and is only
+// valid until the node is modified.
+func (lp *listpack) get(i int) []byte {
+	return lp.data[lp.start(i):lp.ends[i]:lp.ends[i]]
+}
+
+// insert makes v element i, shifting the following elements.
+func (lp *listpack) insert(i int, v []byte) {
+	at := lp.start(i)
+
+	lp.data = append(lp.data, v...)
+	copy(lp.data[at+len(v):], lp.data[at:len(lp.data)-len(v)])
+	copy(lp.data[at:], v)
+
+	lp.ends = append(lp.ends, 0)
+	copy(lp.ends[i+1:], lp.ends[i:])
+	lp.ends[i] = uint32(at)
+	for j := i; j < len(lp.ends); j++ {
+		lp.ends[j] += uint32(len(v))
+	}
+}
+
+// removeRange deletes the n elements starting at i.
+func (lp *listpack) removeRange(i, n int) {
+	if n <= 0 {
+		return
+	}
+	from, to := lp.start(i), int(lp.ends[i+n-1])
+	width := uint32(to - from)
+
+	lp.data = append(lp.data[:from], lp.data[to:]...)
+	lp.ends = append(lp.ends[:i], lp.ends[i+n:]...)
+	for j := i; j < len(lp.ends); j++ {
+		lp.ends[j] -= width
+	}
+}
+
+func (lp *listpack) remove(i int) {
+	lp.removeRange(i, 1)
+}
+
+// set replaces element i with v.
+func (lp *listpack) set(i int, v []byte) {
+	if old := lp.get(i); len(old) == len(v) {
+		copy(old, v)
+		return
+	}
+	lp.remove(i)
+	lp.insert(i, v)
+}
+
+// split moves the elements from i on to a new node and returns it.
+func (lp *listpack) split(i int) *listpack {
+	from := lp.start(i)
+
+	tail := &listpack{
+		data: append([]byte(nil), lp.data[from:]...),
+		ends: make([]uint32, 0, lp.len()-i),
+	}
+	for _, end := range lp.ends[i:] {
+		tail.ends = append(tail.ends, end-uint32(from))
+	}
+
+	lp.data = lp.data[:from]
+	lp.ends = lp.ends[:i]
+	return tail
+}
+
+// merge appends the elements of other to lp.
+func (lp *listpack) merge(other *listpack) {
+	base := uint32(lp.size())
+	lp.data = append(lp.data, other.data...)
+	for _, end := range other.ends {
+		lp.ends = append(lp.ends, base+end)
+	}
+}
@@ -29,6 +29,7 @@ func (ps *PubSub) UnSubscribe(topic string, clientChan chan string) {
 	defer ps.mu.Unlock()
 
 	if _, exists := ps.subs[topic]; exists {
+		delete(ps.subs[topic], clientChan)
 		if len(ps.subs[topic]) == 0 {
 			delete(ps.subs, topic)
 		}
@@ -37,7 +38,7 @@ func (ps *PubSub) UnSubscribe(topic string, clientChan chan string) {
 
 func (ps *PubSub) Publish(topic, message string) int {
 	ps.mu.RLock()
-	defer ps.mu.RLock()
+	defer ps.mu.RUnlock()
 
 	count := 0
 	if subscribers, exists := ps.subs[topic]; exists {
@@ -0,0 +1,321 @@
+package database
+
+import (
+	"bytes"
+	"slices"
+)
+
+const (
+	// quicklistNodeBytes caps the payload of a node, like Redis' default
+	// list-max-listpack-size of -2 (8kb). A larger element gets a node of its own.
+	quicklistNodeBytes = 8 << 10
+	// quicklistNodeEntries caps the number of elements of a node, which bounds
+	// the cost of inserting in the middle of it.
+	quicklistNodeEntries = 512
+)
+
+// QuickList is the value of a TypeList key: a sequence of listpack nodes,
+// each packing many elements in one buffer. Compared to a linked list of
+// elements it saves the per-element node and allocation, and an index is
+// found by skipping whole nodes.
+// Elements returned by its methods are copies, the nodes are never exposed.
+type QuickList struct {
+	nodes []*listpack
+	count int
+}
+
+// NewQuickList creates a list holding values in order.
+func NewQuickList(values ...[]byte) *QuickList {
+	ql := &QuickList{}
+	for _, v := range values {
+		ql.PushBack(v)
+	}
+	return ql
+}
+
+// clone returns a copy of the list sharing nothing with it.
+func (ql *QuickList) clone() *QuickList {
+	c := &QuickList{nodes: make([]*listpack, len(ql.nodes)), count: ql.count}
+	for i, node := range ql.nodes {
+		c.nodes[i] = &listpack{data: slices.Clone(node.data), ends: slices.Clone(node.ends)}
+	}
+	return c
+}
+
+// Len returns the number of elements.
+func (ql *QuickList) Len() int {
+	return ql.count
+}
+
+// fits reports whether v can be added to node without going over the limits.
+// An empty node takes anything.
+func fits(node *listpack, v []byte) bool {
+	return node.len() == 0 || node.len() < quicklistNodeEntries && node.size()+len(v) <= quicklistNodeBytes
+}
+
+// locate returns the node holding element index and the offset of the element
+// in it, walking from the closest end. index must be in range.
+func (ql *QuickList) locate(index int) (int, int) {
+	if index < ql.count/2 {
+		for n, node := range ql.nodes {
+			if index < node.len() {
+				return n, index
+			}
+			index -= node.len()
+		}
+	}
+
+	index = ql.count - 1 - index
+	for n := len(ql.nodes) - 1; ; n-- {
+		node := ql.nodes[n]
+		if index < node.len() {
+			return n, node.len() - 1 - index
+		}
+		index -= node.len()
+	}
+}
+
+// insertNode makes node the n-th node.
+func (ql *QuickList) insertNode(n int, node *listpack) {
+	ql.nodes = append(ql.nodes, nil)
+	copy(ql.nodes[n+1:], ql.nodes[n:])
+	ql.nodes[n] = node
+}
+
+func (ql *QuickList) removeNode(n int) {
+	copy(ql.nodes[n:], ql.nodes[n+1:])
+	ql.nodes[len(ql.nodes)-1] = nil
+	ql.nodes = ql.nodes[:len(ql.nodes)-1]
+}
+
+// canMerge reports whether the elements of a and b fit in a single node.
+func canMerge(a, b *listpack) bool {
+	return a.len()+b.len() <= quicklistNodeEntries && a.size()+b.size() <= quicklistNodeBytes
+}
+
+// PushFront inserts v at the head.
+func (ql *QuickList) PushFront(v []byte) {
+	if len(ql.nodes) == 0 || !fits(ql.nodes[0], v) {
+		ql.insertNode(0, newListpack())
+	}
+	ql.nodes[0].insert(0, v)
+	ql.count++
+}
+
+// PushBack appends v at the tail.
+func (ql *QuickList) PushBack(v []byte) {
+	if len(ql.nodes) == 0 || !fits(ql.nodes[len(ql.nodes)-1], v) {
+		ql.nodes = append(ql.nodes, newListpack())
+	}
+	last := ql.nodes[len(ql.nodes)-1]
+	last.insert(last.len(), v)
+	ql.count++
+}
+
+// Insert makes v the element at index, 0 <= index <= Len().
+func (ql *QuickList) Insert(index int, v []byte) {
+	switch index {
+	case 0:
+		ql.PushFront(v)
+		return
+	case ql.count:
+		ql.PushBack(v)
+		return
+	}
+
+	n, off := ql.locate(index)
+	node := ql.nodes[n]
+	switch {
+	case fits(node, v):
+		node.insert(off, v)
+	case off == 0 && fits(ql.nodes[n-1], v):
+		prev := ql.nodes[n-1]
+		prev.insert(prev.len(), v)
+	default:
+		// the node is full: split it and put v at the end of the first half
+		// when it fits there, or in a node of its own
+		tail := node.split(off)
+		ql.insertNode(n+1, tail)
+		if fits(node, v) {
+			node.insert(off, v)
+		} else {
+			ql.insertNode(n+1, newListpack(v))
+		}
+	}
+	ql.count++
+}
+
+// Index returns the element at index, negative indexes count from the tail.
+func (ql *QuickList) Index(index int) ([]byte, bool) {
+	if index < 0 {
+		index += ql.count
+	}
+	if index < 0 || index >= ql.count {
+		return nil, false
+	}
+	n, off := ql.locate(index)
+	return bytes.Clone(ql.nodes[n].get(off)), true
+}
+
+// Set replaces the element at index, negative indexes count from the tail.
+// It reports false when index is out of range.
+func (ql *QuickList) Set(index int, v []byte) bool {
+	if index < 0 {
+		index += ql.count
+	}
+	if index < 0 || index >= ql.count {
+		return false
+	}
+	n, off := ql.locate(index)
+	node := ql.nodes[n]
+	if node.len() == 1 || node.size()-len(node.get(off))+len(v) <= quicklistNodeBytes {
+		node.set(off, v)
+		return true
+	}
+	// v doesn't fit where the old element was
+	ql.removeAt(n, off)
+	ql.Insert(index, v)
+	return true
+}
+
+// removeAt deletes element off of node n, dropping the node when it empties.
+func (ql *QuickList) removeAt(n, off int) {
+	node := ql.nodes[n]
+	node.remove(off)
+	ql.count--
+	if node.len() == 0 {
+		ql.removeNode(n)
+	}
+}
+
+// PopFront removes and returns the head, the list must not be empty.
+func (ql *QuickList) PopFront() []byte {
+	v := bytes.Clone(ql.nodes[0].get(0))
+	ql.removeAt(0, 0)
+	return v
+}
+
+// PopBack removes and returns the tail, the list must not be empty.
+func (ql *QuickList) PopBack() []byte {
+	n := len(ql.nodes) - 1
+	last := ql.nodes[n]
+	v := bytes.Clone(last.get(last.len() - 1))
+	ql.removeAt(n, last.len()-1)
+	return v
+}
+
+// Range returns the elements from start to stop, both inclusive and in range.
+func (ql *QuickList) Range(start, stop int) [][]byte {
+	result := make([][]byte, 0, stop-start+1)
+	n, off := ql.locate(start)
+	for ; len(result) < cap(result); n, off = n+1, 0 {
+		node := ql.nodes[n]
+		for ; off < node.len() && len(result) < cap(result); off++ {
+			result = append(result, bytes.Clone(node.get(off)))
+		}
+	}
+	return result
+}
+
+// Trim keeps only the elements from start to stop, both inclusive and in range.
+func (ql *QuickList) Trim(start, stop int) {
+	ql.removeRange(stop+1, ql.count-stop-1)
+	ql.removeRange(0, start)
+}
+
+// removeRange deletes count elements from start, dropping whole nodes when possible.
+func (ql *QuickList) removeRange(start, count int) {
+	if count <= 0 {
+		return
+	}
+	n, off := ql.locate(start)
+	for count > 0 {
+		node := ql.nodes[n]
+		k := min(node.len()-off, count)
+		if k == node.len() {
+			ql.removeNode(n)
+		} else {
+			node.removeRange(off, k)
+			n++
+		}
+		ql.count -= k
+		count -= k
+		off = 0
+	}
+}
+
+// Each calls fn with every element and its index, from the head or from the
+// tail, until fn returns false. v is only valid during the call.
+func (ql *QuickList) Each(fromTail bool, fn func(index int, v []byte) bool) {
+	if !fromTail {
+		index := 0
+		for _, node := range ql.nodes {
+			for off := 0; off < node.len(); off++ {
+				if !fn(index, node.get(off)) {
+					return
+				}
+				index++
+			}
+		}
+		return
+	}
+
+	index := ql.count - 1
+	for n := len(ql.nodes) - 1; n >= 0; n-- {
+		node := ql.nodes[n]
+		for off := node.len() - 1; off >= 0; off-- {
+			if !fn(index, node.get(off)) {
+				return
+			}
+			index--
+		}
+	}
+}
+
+// RemoveEqual deletes up to count elements equal to v (all of them when count
+// is 0), scanning from the head or from the tail, and returns how many it removed.
+func (ql *QuickList) RemoveEqual(v []byte, count int, fromTail bool) int {
+	removed := 0
+	done := func() bool { return count > 0 && removed == count }
+
+	for i := 0; i < len(ql.nodes) && !done(); i++ {
+		node := ql.nodes[i]
+		if fromTail {
+			node = ql.nodes[len(ql.nodes)-1-i]
+		}
+		// j counts the elements of the node left to look at, from the scan direction
+		for j := node.len(); j > 0 && !done(); j-- {
+			off := node.len() - j
+			if fromTail {
+				off = j - 1
+			}
+			if bytes.Equal(node.get(off), v) {
+				node.remove(off)
+				removed++
+			}
+		}
+	}
+
+	if removed > 0 {
+		ql.count -= removed
+		ql.compact()
+	}
+	return removed
+}
+
+// compact drops the empty nodes and merges the neighbours that fit together,
+// after removals in the middle of the list.
+func (ql *QuickList) compact() {
+	kept := ql.nodes[:0]
+	for _, node := range ql.nodes {
+		switch {
+		case node.len() == 0:
+		case len(kept) > 0 && canMerge(kept[len(kept)-1], node):
+			kept[len(kept)-1].merge(node)
+		default:
+			kept = append(kept, node)
+		}
+	}
+	clear(ql.nodes[len(kept):])
+	ql.nodes = kept
+}
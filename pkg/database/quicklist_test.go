@@ -0,0 +1,268 @@
+package database
+
+import (
+	"bytes"
+	"container/list"
+	"math/rand/v2"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// checkQuickList compares ql with model and verifies the node invariants.
+func checkQuickList(t *testing.T, ql *QuickList, model [][]byte) {
+	t.Helper()
+
+	if ql.Len() != len(model) {
+		t.Fatalf("Expected %d elements, got %d", len(model), ql.Len())
+	}
+
+	total := 0
+	for n, node := range ql.nodes {
+		if node.len() == 0 {
+			t.Fatalf("Node %d is empty", n)
+		}
+		if node.len() > 1 && (node.len() > quicklistNodeEntries || node.size() > quicklistNodeBytes) {
+			t.Fatalf("Node %d is over the limits: %d elements, %d bytes", n, node.len(), node.size())
+		}
+		total += node.len()
+	}
+	if total != ql.Len() {
+		t.Fatalf("Nodes hold %d elements, Len says %d", total, ql.Len())
+	}
+
+	if len(model) == 0 {
+		return
+	}
+	for i, v := range ql.Range(0, len(model)-1) {
+		if !bytes.Equal(v, model[i]) {
+			t.Fatalf("Element %d: got %q, want %q", i, v, model[i])
+		}
+	}
+}
+
+func randomValue() []byte {
+	// mostly small values, sometimes one larger than a whole node
+	if rand.IntN(50) == 0 {
+		return bytes.Repeat([]byte{'L'}, quicklistNodeBytes+rand.IntN(100))
+	}
+	return []byte(strconv.Itoa(rand.IntN(20)))
+}
+
+func TestQuickListMatchesModel(t *testing.T) {
+	ql := NewQuickList()
+	var model [][]byte
+
+	for i := 0; i < 20000; i++ {
+		v := randomValue()
+
+		switch op := rand.IntN(10); {
+		case op < 3:
+			ql.PushFront(v)
+			model = append([][]byte{v}, model...)
+		case op < 6:
+			ql.PushBack(v)
+			model = append(model, v)
+		case op == 6:
+			at := rand.IntN(len(model) + 1)
+			ql.Insert(at, v)
+			model = append(model[:at], append([][]byte{v}, model[at:]...)...)
+		case op == 7 && len(model) > 0:
+			if rand.IntN(2) == 0 {
+				if got := ql.PopFront(); !bytes.Equal(got, model[0]) {
+					t.Fatalf("PopFront: got %q, want %q", got, model[0])
+				}
+				model = model[1:]
+			} else {
+				if got := ql.PopBack(); !bytes.Equal(got, model[len(model)-1]) {
+					t.Fatalf("PopBack: got %q, want %q", got, model[len(model)-1])
+				}
+				model = model[:len(model)-1]
+			}
+		case op == 8 && len(model) > 0:
+			at := rand.IntN(len(model))
+			ql.Set(at, v)
+			model[at] = v
+		case op == 9 && len(model) > 0:
+			target := []byte(strconv.Itoa(rand.IntN(20)))
+			count := rand.IntN(3)
+			fromTail := rand.IntN(2) == 0
+			removed := ql.RemoveEqual(target, count, fromTail)
+
+			var kept [][]byte
+			n := 0
+			for j := range model {
+				k := j
+				if fromTail {
+					k = len(model) - 1 - j
+				}
+				if bytes.Equal(model[k], target) && (count == 0 || n < count) {
+					n++
+					model[k] = nil
+				}
+			}
+			for _, m := range model {
+				if m != nil {
+					kept = append(kept, m)
+				}
+			}
+			if removed != n {
+				t.Fatalf("RemoveEqual: removed %d, want %d", removed, n)
+			}
+			model = kept
+		}
+
+		if i%500 == 0 {
+			checkQuickList(t, ql, model)
+		}
+	}
+	checkQuickList(t, ql, model)
+
+	for i := -len(model); i < len(model); i++ {
+		want := model[(i+len(model))%len(model)]
+		if got, ok := ql.Index(i); !ok || !bytes.Equal(got, want) {
+			t.Fatalf("Index(%d): got %q, want %q", i, got, want)
+		}
+	}
+	if _, ok := ql.Index(len(model)); ok {
+		t.Fatalf("Index past the end should not be found")
+	}
+
+	if len(model) > 10 {
+		ql.Trim(3, len(model)-4)
+		checkQuickList(t, ql, model[3:len(model)-3])
+	}
+}
+
+func TestQuickListReturnsCopies(t *testing.T) {
+	ql := NewQuickList([]byte("abc"), []byte("def"))
+
+	got, _ := ql.Index(0)
+	got[0] = 'X'
+	ql.Range(0, 1)[1][0] = 'Y'
+
+	checkQuickList(t, ql, [][]byte{[]byte("abc"), []byte("def")})
+}
+
+// The benchmarks compare the quicklist with the container/list the lists used to be.
+const benchListLen = 10000
+
+func benchValue(i int) []byte {
+	return []byte("element:" + strconv.Itoa(i))
+}
+
+func BenchmarkListPush(b *testing.B) {
+	b.Run("container-list", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l := list.New()
+			for j := 0; j < benchListLen; j++ {
+				l.PushBack(benchValue(j))
+			}
+		}
+	})
+	b.Run("quicklist", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ql := NewQuickList()
+			for j := 0; j < benchListLen; j++ {
+				ql.PushBack(benchValue(j))
+			}
+		}
+	})
+}
+
+func BenchmarkListIndex(b *testing.B) {
+	l := list.New()
+	ql := NewQuickList()
+	for j := 0; j < benchListLen; j++ {
+		l.PushBack(benchValue(j))
+		ql.PushBack(benchValue(j))
+	}
+
+	b.Run("container-list", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			// what LINDEX had to do: walk from the closest end
+			index := i % benchListLen
+			if index < benchListLen/2 {
+				e := l.Front()
+				for ; index > 0; index-- {
+					e = e.Next()
+				}
+			} else {
+				e := l.Back()
+				for index = benchListLen - 1 - index; index > 0; index-- {
+					e = e.Prev()
+				}
+			}
+		}
+	})
+	b.Run("quicklist", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ql.Index(i % benchListLen)
+		}
+	})
+}
+
+func BenchmarkListRange(b *testing.B) {
+	l := list.New()
+	ql := NewQuickList()
+	for j := 0; j < benchListLen; j++ {
+		l.PushBack(benchValue(j))
+		ql.PushBack(benchValue(j))
+	}
+
+	// LRANGE key 5000 5099
+	b.Run("container-list", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			result := make([][]byte, 0, 100)
+			e := l.Front()
+			for j := 0; j < 5000; j++ {
+				e = e.Next()
+			}
+			for ; len(result) < 100; e = e.Next() {
+				result = append(result, e.Value.([]byte))
+			}
+		}
+	})
+	b.Run("quicklist", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ql.Range(5000, 5099)
+		}
+	})
+}
+
+// BenchmarkListMemory reports the heap used per element of a list of benchListLen elements.
+func BenchmarkListMemory(b *testing.B) {
+	measure := func(b *testing.B, build func() any) {
+		var before, after runtime.MemStats
+		for i := 0; i < b.N; i++ {
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			l := build()
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			runtime.KeepAlive(l)
+			b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/benchListLen, "bytes/element")
+		}
+	}
+
+	b.Run("container-list", func(b *testing.B) {
+		measure(b, func() any {
+			l := list.New()
+			for j := 0; j < benchListLen; j++ {
+				l.PushBack(benchValue(j))
+			}
+			return l
+		})
+	})
+	b.Run("quicklist", func(b *testing.B) {
+		measure(b, func() any {
+			ql := NewQuickList()
+			for j := 0; j < benchListLen; j++ {
+				ql.PushBack(benchValue(j))
+			}
+			return ql
+		})
+	})
+}
@@ -0,0 +1,180 @@
+package database
+
+import "redis-lite/pkg/glob"
+
+// The SCAN family walks Go maps, whose iteration order is random and
+// changes as they grow, so it doesn't follow it: the keys of a map are
+// visited in the order of their position, a 56-bit hash of the key.
+// Positions don't depend on the map, so a cursor telling the position to
+// resume at is all the state a scan needs, and a key present for the whole
+// scan is returned whatever was inserted or deleted in the meantime. Each
+// map scanned keeps a scanIndex next to it, so that a step costs about the
+// number of names it visits rather than the size of the map.
+//
+// The cursor of SCAN packs the index of the shard being visited in its top
+// 8 bits and the position within the shard below. The cursors of HSCAN,
+// SSCAN and ZSCAN are positions. In both cases 0 starts and ends a scan.
+const (
+	positionBits = 56
+	maxPosition  = 1<<positionBits - 1
+)
+
+// position returns the place of key in the scan order: its 64-bit FNV-1a
+// hash, truncated to fit the cursor.
+func position(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h >> (64 - positionBits)
+}
+
+// scanIndex groups the names of a map by position so that a scan step only
+// visits the names it returns: buckets[i] holds the names whose position
+// starts with i in its top bits. The number of buckets follows the number of
+// names, so a step walks about as many buckets as it returns names. The zero
+// value is an empty index.
+type scanIndex struct {
+	buckets [][]string
+	bits    uint
+	n       int
+}
+
+func (x *scanIndex) bucket(pos uint64) int {
+	return int(pos >> (positionBits - x.bits))
+}
+
+// add records name, which must not be in the index yet.
+func (x *scanIndex) add(name string) {
+	if x.buckets == nil {
+		x.buckets = make([][]string, 1)
+	}
+	i := x.bucket(position(name))
+	x.buckets[i] = append(x.buckets[i], name)
+	x.n++
+	if x.n > 2*len(x.buckets) {
+		x.resize(x.bits + 1)
+	}
+}
+
+// remove forgets name, which must be in the index.
+func (x *scanIndex) remove(name string) {
+	i := x.bucket(position(name))
+	b := x.buckets[i]
+	for j := range b {
+		if b[j] == name {
+			last := len(b) - 1
+			b[j], b[last] = b[last], ""
+			x.buckets[i] = b[:last]
+			break
+		}
+	}
+	x.n--
+	if x.bits > 0 && x.n < len(x.buckets)/8 {
+		x.resize(x.bits - 1)
+	}
+}
+
+// resize spreads the names over 1<<bits buckets.
+func (x *scanIndex) resize(bits uint) {
+	old := x.buckets
+	x.buckets, x.bits = make([][]string, 1<<bits), bits
+	for _, b := range old {
+		for _, name := range b {
+			i := x.bucket(position(name))
+			x.buckets[i] = append(x.buckets[i], name)
+		}
+	}
+}
+
+// clone returns a copy of the index sharing nothing with it.
+func (x *scanIndex) clone() scanIndex {
+	c := scanIndex{buckets: make([][]string, len(x.buckets)), bits: x.bits, n: x.n}
+	for i, b := range x.buckets {
+		c.buckets[i] = append([]string(nil), b...)
+	}
+	return c
+}
+
+// scan visits the names from position from on, at least count of them
+// unless fewer are left: whole buckets are visited, so the scan can resume
+// at the start of the next one. It returns the names visited and the
+// position to resume at, 0 once the end was reached.
+func (x *scanIndex) scan(from uint64, count int) ([]string, uint64) {
+	if x.n == 0 {
+		return nil, 0
+	}
+	var names []string
+	i := x.bucket(from)
+	for ; i < len(x.buckets) && len(names) < count; i++ {
+		for _, name := range x.buckets[i] {
+			// the names of the first bucket may lie before from after a resize
+			if position(name) >= from {
+				names = append(names, name)
+			}
+		}
+	}
+	if i == len(x.buckets) {
+		return names, 0
+	}
+	return names, uint64(i) << (positionBits - x.bits)
+}
+
+// ScanArgs are the options of the SCAN family.
+type ScanArgs struct {
+	// Count is how many keys or elements to visit, a hint the scan may exceed.
+	Count int
+	// Match only returns the names matching this glob pattern when not empty.
+	Match string
+	// Type only returns the keys holding this type (as TYPE names it) when
+	// not empty. SCAN only.
+	Type string
+}
+
+// keep reports whether the filters of args let name through.
+func (args *ScanArgs) keep(name string) bool {
+	return args.Match == "" || glob.Match(args.Match, name)
+}
+
+// Scan visits about args.Count keys from cursor on and returns those the
+// filters of args let through, with the cursor to continue from. A key
+// present from the first call to the one returning cursor 0 is returned at
+// least once; keys added or deleted meanwhile may or may not be.
+// Each shard is read-locked for one step at most.
+func (s *Store) Scan(cursor uint64, args ScanArgs) (uint64, []string) {
+	idx, from := int(cursor>>positionBits), cursor&maxPosition
+
+	var keys []string
+	visited := 0
+	for idx < ShardCount && visited < args.Count {
+		var n int
+		keys, n, from = s.scanShard(s.Shards[idx], from, args.Count-visited, &args, keys)
+		visited += n
+		if from == 0 {
+			idx++
+		}
+	}
+
+	if idx == ShardCount {
+		return 0, keys
+	}
+	return uint64(idx)<<positionBits | from, keys
+}
+
+// scanShard is one step of Scan through shard: it appends the keys it keeps to
+// keys and returns them with the number of keys visited and the position to resume at.
+func (s *Store) scanShard(shard *Shard, from uint64, count int, args *ScanArgs, keys []string) ([]string, int, uint64) {
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	visited, next := shard.index.scan(from, count)
+	for _, key := range visited {
+		item := shard.readItem(key)
+		if item == nil || args.Type != "" && item.Type.String() != args.Type || !args.keep(key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, len(visited), next
+}
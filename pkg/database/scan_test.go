@@ -0,0 +1,173 @@
+package database
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// scanAll runs a whole scan and returns how many times each key was returned.
+func scanAll(s *Store, args ScanArgs) map[string]int {
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for {
+		next, keys := s.Scan(cursor, args)
+		for _, key := range keys {
+			seen[key]++
+		}
+		if next == 0 {
+			return seen
+		}
+		cursor = next
+	}
+}
+
+// TestScanSurvivesWrites scans while other clients add and delete keys, and
+// checks that every key present for the whole scan is returned.
+func TestScanSurvivesWrites(t *testing.T) {
+	s := NewStore()
+	for i := 0; i < 5000; i++ {
+		s.Set("stable:"+strconv.Itoa(i), []byte("v"), 0)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			key := "churn:" + strconv.Itoa(i)
+			s.Set(key, []byte("v"), 0)
+			if i%2 == 0 {
+				s.Delete(key)
+			}
+		}
+	}()
+
+	seen := scanAll(s, ScanArgs{Count: 7})
+	close(stop)
+	wg.Wait()
+
+	for i := 0; i < 5000; i++ {
+		if key := "stable:" + strconv.Itoa(i); seen[key] == 0 {
+			t.Fatalf("The scan missed %q", key)
+		}
+	}
+}
+
+func TestScanFilters(t *testing.T) {
+	s := NewStore()
+	for i := 0; i < 100; i++ {
+		s.Set("str:"+strconv.Itoa(i), []byte("v"), 0)
+		s.SAdd("set:"+strconv.Itoa(i), []string{"m"})
+	}
+
+	seen := scanAll(s, ScanArgs{Count: 10, Match: "str:1*"})
+	if len(seen) != 11 {
+		t.Errorf("Expected the 11 keys matching str:1*, got %v", seen)
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("Without writes, %q should be returned once, got %d times", key, n)
+		}
+	}
+
+	seen = scanAll(s, ScanArgs{Count: 10, Type: "set"})
+	if len(seen) != 100 {
+		t.Errorf("Expected the 100 sets, got %d keys", len(seen))
+	}
+}
+
+// TestScanCollections checks that scanning a collection in small steps
+// returns every element exactly once.
+func TestScanCollections(t *testing.T) {
+	s := NewStore()
+	var members []string
+	for i := 0; i < 1000; i++ {
+		members = append(members, strconv.Itoa(i))
+	}
+	s.SAdd("set", members)
+
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for {
+		next, page, err := s.SScan("set", cursor, ScanArgs{Count: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range page {
+			seen[m]++
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if len(seen) != len(members) {
+		t.Errorf("Expected %d members, got %d", len(members), len(seen))
+	}
+	for m, n := range seen {
+		if n != 1 {
+			t.Errorf("%q returned %d times", m, n)
+		}
+	}
+}
+
+// TestScanIndex grows and shrinks an index while scanning it, and checks that
+// each step stays close to its count and that the names present for the whole
+// scan are returned.
+func TestScanIndex(t *testing.T) {
+	var x scanIndex
+	names := make(map[string]bool)
+	for i := 0; i < 100000; i++ {
+		name := strconv.Itoa(i)
+		x.add(name)
+		names[name] = true
+	}
+
+	seen := make(map[string]bool)
+	cursor, i := uint64(0), 0
+	for {
+		page, next := x.scan(cursor, 10)
+		if len(page) > 100 {
+			t.Fatalf("A step of count 10 visited %d names", len(page))
+		}
+		for _, name := range page {
+			seen[name] = true
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+
+		// shrink the index on the way, keeping names 0 to 99
+		for n := 0; n < 2000 && i < 99900; n++ {
+			name := strconv.Itoa(100 + i)
+			x.remove(name)
+			delete(names, name)
+			i++
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if name := strconv.Itoa(i); !seen[name] {
+			t.Fatalf("The scan missed %q", name)
+		}
+	}
+	if x.n != len(names) || len(x.buckets) > 8*x.n {
+		t.Errorf("Expected %d names in at most %d buckets, got %d in %d", len(names), 8*len(names), x.n, len(x.buckets))
+	}
+	for _, b := range x.buckets {
+		for _, name := range b {
+			if !names[name] {
+				t.Errorf("%q was removed but is still indexed", name)
+			}
+		}
+	}
+}
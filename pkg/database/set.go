@@ -0,0 +1,441 @@
+package database
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+)
+
+// Set is the value of a TypeSet key. Go strings are binary-safe map keys.
+type Set struct {
+	members map[string]struct{}
+	// index orders the members for SSCAN, see scan.go
+	index scanIndex
+}
+
+func newSet() *Set {
+	return &Set{members: make(map[string]struct{})}
+}
+
+// setOf returns the set of members, taking ownership of the map.
+func setOf(members map[string]struct{}) *Set {
+	set := &Set{members: members}
+	for m := range members {
+		set.index.add(m)
+	}
+	return set
+}
+
+// clone returns a copy of the set sharing nothing with it.
+func (set *Set) clone() *Set {
+	return &Set{members: maps.Clone(set.members), index: set.index.clone()}
+}
+
+// len returns the number of members, 0 for the nil set of a missing key.
+func (set *Set) len() int {
+	if set == nil {
+		return 0
+	}
+	return len(set.members)
+}
+
+// has reports whether member belongs to the set, which may be nil.
+func (set *Set) has(member string) bool {
+	if set == nil {
+		return false
+	}
+	_, ok := set.members[member]
+	return ok
+}
+
+// add adds member and reports whether it was not there yet.
+func (set *Set) add(member string) bool {
+	if _, exists := set.members[member]; exists {
+		return false
+	}
+	set.members[member] = struct{}{}
+	set.index.add(member)
+	return true
+}
+
+// getSet returns the set at key for reading, nil when missing.
+func getSet(shard *Shard, key string) (*Set, error) {
+	item := shard.readItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeSet {
+		return nil, ErrWrongType
+	}
+	return item.Value.(*Set), nil
+}
+
+// setForWrite is getSet for callers holding the shard write lock.
+// Sets are never stored empty, so a nil set means the key is missing.
+func setForWrite(shard *Shard, key string) (*Set, error) {
+	item := shard.writeItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeSet {
+		return nil, ErrWrongType
+	}
+	return item.Value.(*Set), nil
+}
+
+// removeMember deletes member from set, and key with its last member.
+// The caller holds the shard write lock.
+func removeMember(shard *Shard, key string, set *Set, member string) {
+	delete(set.members, member)
+	set.index.remove(member)
+	if len(set.members) == 0 {
+		shard.remove(key)
+	}
+	shard.touch(key)
+}
+
+// SAdd adds members to the set at key and returns how many were not there yet.
+func (s *Store) SAdd(key string, members []string) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	set, err := setForWrite(shard, key)
+	if err != nil {
+		return 0, err
+	}
+	if set == nil {
+		set = newSet()
+		shard.put(key, &Item{Value: set, Type: TypeSet})
+	}
+
+	added := 0
+	for _, m := range members {
+		if set.add(m) {
+			added++
+		}
+	}
+	if added > 0 {
+		shard.touch(key)
+	}
+	return added, nil
+}
+
+// SRem removes members from the set at key and returns how many it removed.
+func (s *Store) SRem(key string, members []string) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	set, err := setForWrite(shard, key)
+	if set == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, m := range members {
+		if set.has(m) {
+			removeMember(shard, key, set, m)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// SMembers returns the members of the set at key, in no particular order.
+func (s *Store) SMembers(key string) ([]string, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	set, err := getSet(shard, key)
+	return setMembers(set), err
+}
+
+func setMembers(set *Set) []string {
+	members := make([]string, 0, set.len())
+	if set != nil {
+		for m := range set.members {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// SScan is Scan for the members of the set at key.
+func (s *Store) SScan(key string, cursor uint64, args ScanArgs) (uint64, []string, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	set, err := getSet(shard, key)
+	if set == nil {
+		return 0, nil, err
+	}
+
+	visited, next := set.index.scan(cursor, args.Count)
+	members := visited[:0]
+	for _, m := range visited {
+		if args.keep(m) {
+			members = append(members, m)
+		}
+	}
+	return next, members, nil
+}
+
+// SIsMember reports whether member belongs to the set at key.
+func (s *Store) SIsMember(key, member string) (bool, error) {
+	found, err := s.SMIsMember(key, []string{member})
+	if err != nil {
+		return false, err
+	}
+	return found[0], nil
+}
+
+// SMIsMember reports for each of members whether it belongs to the set at key.
+func (s *Store) SMIsMember(key string, members []string) ([]bool, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	set, err := getSet(shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]bool, len(members))
+	for i, m := range members {
+		found[i] = set.has(m)
+	}
+	return found, nil
+}
+
+// SCard returns the number of members of the set at key.
+func (s *Store) SCard(key string) (int, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	set, err := getSet(shard, key)
+	return set.len(), err
+}
+
+// randomMembers returns count distinct members of set picked at random,
+// or all of them when count is larger than the set.
+func randomMembers(set *Set, count int) []string {
+	members := setMembers(set)
+	if count >= len(members) {
+		return members
+	}
+	// a partial Fisher-Yates shuffle puts the picks first
+	for i := 0; i < count; i++ {
+		j := i + rand.IntN(len(members)-i)
+		members[i], members[j] = members[j], members[i]
+	}
+	return members[:count]
+}
+
+// SPop removes up to count random members from the set at key and returns them.
+func (s *Store) SPop(key string, count int) ([]string, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	set, err := setForWrite(shard, key)
+	if set == nil {
+		return nil, err
+	}
+
+	popped := randomMembers(set, count)
+	for _, m := range popped {
+		removeMember(shard, key, set, m)
+	}
+	return popped, nil
+}
+
+// SRandMember returns random members of the set at key, like SRANDMEMBER:
+// up to count distinct members when count is positive, exactly -count
+// members that may repeat when it is negative.
+func (s *Store) SRandMember(key string, count int) ([]string, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	set, err := getSet(shard, key)
+	if set == nil {
+		return nil, err
+	}
+	if count >= 0 {
+		return randomMembers(set, count), nil
+	}
+
+	members := setMembers(set)
+	picks := make([]string, 0, min(-count, 1024))
+	for len(picks) < -count {
+		picks = append(picks, members[rand.IntN(len(members))])
+	}
+	return picks, nil
+}
+
+// SMove moves member from the set at src to the set at dst, and reports
+// whether member was in src.
+func (s *Store) SMove(src, dst, member string) (bool, error) {
+	unlock := s.lockKeys(src, dst)
+	defer unlock()
+
+	srcShard, dstShard := s.getShard(src), s.getShard(dst)
+
+	from, err := setForWrite(srcShard, src)
+	if err != nil {
+		return false, err
+	}
+	to, err := setForWrite(dstShard, dst)
+	if err != nil || from == nil {
+		return false, err
+	}
+
+	if !from.has(member) {
+		return false, nil
+	}
+	if src == dst {
+		return true, nil
+	}
+
+	removeMember(srcShard, src, from, member)
+	if to == nil {
+		to = newSet()
+		dstShard.put(dst, &Item{Value: to, Type: TypeSet})
+	}
+	to.add(member)
+	dstShard.touch(dst)
+	return true, nil
+}
+
+// SetOp is an operation of the set algebra commands.
+type SetOp int
+
+const (
+	SetInter SetOp = iota
+	SetUnion
+	SetDiff
+)
+
+// combine applies op to sets, nil standing for missing keys, and returns
+// the members of the result.
+// Intersections stop after limit members when limit is positive.
+func combine(op SetOp, sets []*Set, limit int) map[string]struct{} {
+	result := make(map[string]struct{})
+	switch op {
+	case SetInter:
+		smallest := 0
+		for i, set := range sets {
+			if set.len() == 0 {
+				return result
+			}
+			if set.len() < sets[smallest].len() {
+				smallest = i
+			}
+		}
+	members:
+		for m := range sets[smallest].members {
+			for i, set := range sets {
+				if !set.has(m) && i != smallest {
+					continue members
+				}
+			}
+			result[m] = struct{}{}
+			if limit > 0 && len(result) == limit {
+				break
+			}
+		}
+	case SetUnion:
+		for _, set := range sets {
+			if set == nil {
+				continue
+			}
+			for m := range set.members {
+				result[m] = struct{}{}
+			}
+		}
+	case SetDiff:
+		if sets[0] == nil {
+			break
+		}
+	diff:
+		for m := range sets[0].members {
+			for _, set := range sets[1:] {
+				if set.has(m) {
+					continue diff
+				}
+			}
+			result[m] = struct{}{}
+		}
+	}
+	return result
+}
+
+// setsAt returns the sets at keys, nil for the missing ones.
+// The caller holds the locks of their shards.
+func (s *Store) setsAt(keys []string) ([]*Set, error) {
+	sets := make([]*Set, len(keys))
+	for i, key := range keys {
+		set, err := getSet(s.getShard(key), key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+// SCombine returns the intersection, union or difference (op) of the sets at keys.
+func (s *Store) SCombine(op SetOp, keys []string) ([]string, error) {
+	runlock := s.rlockKeys(keys...)
+	defer runlock()
+
+	sets, err := s.setsAt(keys)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Collect(maps.Keys(combine(op, sets, 0))), nil
+}
+
+// SInterCard returns the size of the intersection of the sets at keys,
+// counting no further than limit when it is positive.
+func (s *Store) SInterCard(keys []string, limit int) (int, error) {
+	runlock := s.rlockKeys(keys...)
+	defer runlock()
+
+	sets, err := s.setsAt(keys)
+	if err != nil {
+		return 0, err
+	}
+	return len(combine(SetInter, sets, limit)), nil
+}
+
+// SCombineStore is SCombine storing the result at dst, whatever dst held
+// before, and returning its size. An empty result deletes dst.
+func (s *Store) SCombineStore(op SetOp, dst string, keys []string) (int, error) {
+	unlock := s.lockKeys(append([]string{dst}, keys...)...)
+	defer unlock()
+
+	sets, err := s.setsAt(keys)
+	if err != nil {
+		return 0, err
+	}
+	result := combine(op, sets, 0)
+
+	dstShard := s.getShard(dst)
+	_, existed := dstShard.Items[dst]
+	if len(result) == 0 {
+		if existed {
+			dstShard.remove(dst)
+			dstShard.touch(dst)
+		}
+		return 0, nil
+	}
+
+	dstShard.put(dst, &Item{Value: setOf(result), Type: TypeSet})
+	dstShard.touch(dst)
+	return len(result), nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSetMultiKeyLocking runs multi-key set commands naming the same keys in
+// opposite orders. Locking the shards in the order of the arguments would
+// deadlock them.
+func TestSetMultiKeyLocking(t *testing.T) {
+	s := NewStore()
+	keys := make([]string, 8)
+	for i := range keys {
+		keys[i] = "set" + strconv.Itoa(i)
+		s.SAdd(keys[i], []string{"a", "b", "c", keys[i]})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				a, b := keys[g], keys[(g+1)%len(keys)]
+				for i := 0; i < 500; i++ {
+					s.SMove(a, b, "a")
+					s.SMove(b, a, "a")
+					s.SCombineStore(SetUnion, b, []string{a, keys[(g+4)%len(keys)]})
+					s.SCombine(SetInter, []string{b, a})
+				}
+			}(g)
+		}
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Multi-key set commands deadlocked")
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	s := NewStore()
+	s.SAdd("k1", []string{"a", "b", "c", "d"})
+	s.SAdd("k2", []string{"c"})
+	s.SAdd("k3", []string{"a", "c", "e"})
+
+	tests := []struct {
+		op   SetOp
+		keys []string
+		want int
+	}{
+		{SetInter, []string{"k1", "k2", "k3"}, 1},
+		{SetInter, []string{"k1", "missing"}, 0},
+		{SetUnion, []string{"k1", "k2", "k3"}, 5},
+		{SetUnion, []string{"missing"}, 0},
+		{SetDiff, []string{"k1", "k2", "k3"}, 2},
+		{SetDiff, []string{"missing", "k1"}, 0},
+	}
+	for _, tt := range tests {
+		got, err := s.SCombine(tt.op, tt.keys)
+		if err != nil || len(got) != tt.want {
+			t.Errorf("SCombine(%d, %v) = %v, %v; want %d members", tt.op, tt.keys, got, err, tt.want)
+		}
+	}
+
+	if n, _ := s.SInterCard([]string{"k1", "k3"}, 1); n != 1 {
+		t.Errorf("SInterCard with LIMIT 1 = %d, want 1", n)
+	}
+
+	s.Set("str", []byte("v"), 0)
+	if n, err := s.SCombineStore(SetUnion, "str", []string{"k2"}); err != nil || n != 1 {
+		t.Errorf("SUNIONSTORE should overwrite any destination, got %d, %v", n, err)
+	}
+	if _, err := s.SCombine(SetUnion, []string{"k1", "str"}); err != nil {
+		t.Errorf("str holds a set now, got %v", err)
+	}
+}
@@ -1,10 +1,11 @@
 package database
 
 import (
-	"container/list"
-	"fmt"
+	"errors"
 	"hash/fnv"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,25 +20,84 @@ const (
 	TypeList
 	TypeSet
 	TypeHash
+	TypeZSet
+	TypeStream
 )
 
+// String returns the name TYPE replies for t.
+func (t DataType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeList:
+		return "list"
+	case TypeSet:
+		return "set"
+	case TypeHash:
+		return "hash"
+	case TypeZSet:
+		return "zset"
+	case TypeStream:
+		return "stream"
+	}
+	return "none"
+}
+
+// ErrWrongType is returned when a command is run against a key holding another type.
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
 // Item represents the value stored in memory.
 // It holds the actual data and metadata like expiration.
+// Values are kept as raw bytes so anything a client sends round-trips untouched:
+// TypeString holds []byte, TypeList *QuickList, TypeHash *Hash,
+// TypeSet *Set, TypeZSet *ZSet and TypeStream *Stream.
 type Item struct {
 	Value     interface{}
 	Type      DataType
 	ExpiresAt int64
+	// owned is set while a string value shares its backing array with
+	// nothing outside the store, so it may be modified in place, see string.go.
+	owned atomic.Bool
 }
 
 type Shard struct {
-	Mu    sync.RWMutex
+	Mu sync.RWMutex
+	// Items is only written through put and remove, which keep index in step.
 	Items map[string]*Item
+	// index orders the keys for SCAN, see scan.go
+	index scanIndex
+	// watched tracks the keys someone has WATCHed, see watch.go
+	watched map[string]*watchedKey
+	// blocked holds the clients waiting for data on a key, see blocking.go
+	blocked map[string][]*Waiter
+}
+
+// put stores item at key. The caller holds the shard write lock.
+func (shard *Shard) put(key string, item *Item) {
+	if _, exists := shard.Items[key]; !exists {
+		shard.index.add(key)
+	}
+	shard.Items[key] = item
+}
+
+// remove deletes key if present. The caller holds the shard write lock.
+func (shard *Shard) remove(key string) {
+	if _, exists := shard.Items[key]; exists {
+		delete(shard.Items, key)
+		shard.index.remove(key)
+	}
 }
 
 // Store is the main database struct.
 type Store struct {
 	Shards []*Shard
 	PubSub *PubSub
+	// held is set on the views handed out by WithLocked: the shards it
+	// contains are already locked by the caller, so their locks are skipped.
+	held map[*Shard]bool
+	// index is the number of the database in its Databases, which orders
+	// the locks of the commands spanning two databases.
+	index int
 }
 
 // NewStore initializes the DB.
@@ -48,7 +108,9 @@ func NewStore() *Store {
 	}
 	for i := 0; i < ShardCount; i++ {
 		s.Shards[i] = &Shard{
-			Items: make(map[string]*Item),
+			Items:   make(map[string]*Item),
+			watched: make(map[string]*watchedKey),
+			blocked: make(map[string][]*Waiter),
 		}
 	}
 	return s
@@ -66,314 +128,157 @@ func (s *Store) getShard(key string) *Shard {
 	return s.Shards[s.getShardIndex(key)]
 }
 
-func (s *Store) Set(key string, value interface{}, ttl time.Duration) {
-	shard := s.getShard(key)
-
-	shard.Mu.Lock()
-	defer shard.Mu.Unlock()
-
-	expiry := int64(0)
+// Set stores value at key, expiring after ttl when it is positive.
+func (s *Store) Set(key string, value []byte, ttl time.Duration) {
+	var args SetArgs
 	if ttl > 0 {
-		expiry = time.Now().Add(ttl).UnixNano()
-	}
-
-	shard.Items[key] = &Item{
-		Value:     value,
-		Type:      TypeString,
-		ExpiresAt: expiry,
+		args.At = time.Now().Add(ttl)
 	}
+	s.SetWith(key, value, args)
 }
 
 func (s *Store) Get(key string) (interface{}, bool) {
 	shard := s.getShard(key)
 
-	shard.Mu.RLock()
+	s.rlock(shard)
 
 	item, exists := shard.Items[key]
 	if !exists {
+		s.runlock(shard)
 		return nil, false
 	}
 
 	// check if expired
-	if item.ExpiresAt > 0 && time.Now().UnixNano() > item.ExpiresAt {
+	if item.isExpired(time.Now().UnixNano()) {
 		// Delete item
-		shard.Mu.RUnlock()
-		s.Delete(key)
+		s.runlock(shard)
+		s.deleteExpired(key)
 		return nil, false
 	}
 
-	shard.Mu.RUnlock()
-	return item.Value, true
-}
-
-func (s *Store) HSet(key, field, value string, ttl time.Duration) (bool, error) {
-	shard := s.getShard(key)
-	shard.Mu.Lock()
-	defer shard.Mu.Unlock()
-
-	item, exists := shard.Items[key]
-
-	expiry := int64(0)
-	if ttl > 0 {
-		expiry = time.Now().Add(ttl).UnixNano()
+	val := item.Value
+	if item.Type == TypeString {
+		val = shareString(item)
 	}
+	s.runlock(shard)
+	return val, true
+}
 
-	if !exists {
-		shard.Items[key] = &Item{
-			Value:     map[string]string{field: value},
-			Type:      TypeHash,
-			ExpiresAt: expiry,
+// Delete removes keys and returns how many of them existed.
+//
+// Only the keyspace entries are removed under the shard locks: the garbage
+// collector reclaims the values concurrently, so even huge ones cost DEL no
+// more than small ones, and UNLINK shares this implementation.
+func (s *Store) Delete(keys ...string) int {
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	deleted := 0
+	for _, key := range keys {
+		shard := s.getShard(key)
+		if shard.writeItem(key) != nil {
+			shard.remove(key)
+			shard.touch(key)
+			deleted++
 		}
-		return true, nil
 	}
-
-	if item.Type != TypeHash {
-		return false, fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
-	}
-
-	hash := item.Value.(map[string]string)
-
-	_, fieldExists := hash[field]
-	hash[field] = value
-
-	return !fieldExists, nil
+	return deleted
 }
 
-func (s *Store) HGet(key, field string) (string, bool) {
+// deleteExpired removes key if it is (still) expired.
+// The check is repeated under the write lock because another client may
+// have stored a fresh value since the caller released its read lock.
+func (s *Store) deleteExpired(key string) {
 	shard := s.getShard(key)
 
-	shard.Mu.RLock()
-
-	item, exists := shard.Items[key]
-	if !exists {
-		return "", false
-	}
-
-	// check if expired
-	if item.ExpiresAt > 0 && time.Now().UnixNano() > item.ExpiresAt {
-		// Delete item
-		shard.Mu.RUnlock()
-		s.Delete(key)
-		return "", false
-	}
+	s.lock(shard)
+	defer s.unlock(shard)
 
-	// Check type (If it's a String, you can't HGET it)
-	if item.Type != TypeHash {
-		return "", false
+	if item, exists := shard.Items[key]; exists && item.isExpired(time.Now().UnixNano()) {
+		shard.remove(key)
+		shard.touch(key)
 	}
-
-	hash := item.Value.(map[string]string)
-	val, ok := hash[field]
-
-	shard.Mu.RUnlock()
-	return val, ok
 }
 
-// LPush adds a value to the head of the list
-// Returns the new length of the list
-func (s *Store) LPush(key, value string, ttl time.Duration) (int, error) {
-	shard := s.getShard(key)
-
-	shard.Mu.Lock()
-	defer shard.Mu.Unlock()
-
-	expiry := int64(0)
-	if ttl > 0 {
-		expiry = time.Now().Add(ttl).UnixNano()
-	}
-
+// readItem returns the live item at key, or nil when it is missing or expired.
+// The caller holds at least the shard read lock; expired items are left to the janitor.
+func (shard *Shard) readItem(key string) *Item {
 	item, exists := shard.Items[key]
-	if !exists {
-		l := list.New()
-		l.PushFront(value)
-		shard.Items[key] = &Item{
-			Value:     l,
-			Type:      TypeList,
-			ExpiresAt: expiry,
-		}
-		return 1, nil
-	}
-
-	if item.Type != TypeList {
-		return 0, fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+	if !exists || item.isExpired(time.Now().UnixNano()) {
+		return nil
 	}
-
-	l := item.Value.(*list.List)
-	l.PushFront(value)
-
-	return l.Len(), nil
+	return item
 }
 
-// LPop removes and returns the first element of the list
-func (s *Store) LPop(key string) (string, bool) {
-	shard := s.getShard(key)
-	shard.Mu.RLock()
-	defer shard.Mu.RUnlock()
-
+// writeItem is readItem for callers holding the shard write lock:
+// an expired item is removed on the way, so the caller can reuse the key.
+func (shard *Shard) writeItem(key string) *Item {
 	item, exists := shard.Items[key]
 	if !exists {
-		return "", false
-	}
-
-	if item.Type != TypeList {
-		return "", false
-	}
-
-	l := item.Value.(*list.List)
-	if l.Len() == 0 {
-		return "", false
+		return nil
 	}
-
-	element := l.Front()
-	val := element.Value.(string)
-
-	l.Remove(element)
-
-	if l.Len() == 0 {
-		delete(shard.Items, key)
+	if item.isExpired(time.Now().UnixNano()) {
+		shard.remove(key)
+		shard.touch(key)
+		return nil
 	}
-
-	return val, true
+	return item
 }
 
-func (s *Store) LRange(key string, start, stop int) ([]string, bool) {
-	shard := s.getShard(key)
-	shard.Mu.RLock()
-	defer shard.Mu.RUnlock()
-
-	item, exists := shard.Items[key]
-	if !exists {
-		return nil, false
-	}
-
-	if item.Type != TypeList {
-		return nil, false
+// lockKeys write-locks the shards owning keys in index order, so that
+// commands touching several keys can't deadlock each other, and returns
+// the function releasing them.
+func (s *Store) lockKeys(keys ...string) (unlock func()) {
+	shards := s.shardsOf(keys)
+	for _, shard := range shards {
+		s.lock(shard)
 	}
 
-	l := item.Value.(*list.List)
-	length := l.Len()
-
-	// handle negative
-	if start < 0 {
-		start = length + start
-		if start < 0 {
-			start = 0
+	return func() {
+		for i := len(shards) - 1; i >= 0; i-- {
+			s.unlock(shards[i])
 		}
 	}
-	if stop < 0 {
-		stop = length + stop
-	}
-
-	result := make([]string, 0, stop-start+1)
-
-	current := l.Front()
-	i := 0
-
-	// skip until 'start'
-	for i < start && current != nil {
-		current = current.Next()
-		i++
-	}
-
-	// collect until 'stop'
-	for i < stop && current != nil {
-		result = append(result, current.Value.(string))
-		current = current.Next()
-		i++
-	}
-
-	return result, true
 }
 
-// SAdd adds one or more members to a set
-func (s *Store) SAdd(key string, members []string) (int, error) {
-	shard := s.getShard(key)
-	shard.Mu.Lock()
-	defer shard.Mu.Unlock()
-
-	item, exists := shard.Items[key]
-
-	if !exists {
-		set := make(map[string]struct{})
-		for _, m := range members {
-			set[m] = struct{}{}
-		}
-
-		shard.Items[key] = &Item{
-			Value:     set,
-			Type:      TypeSet,
-			ExpiresAt: 0,
-		}
-		return len(members), nil
+// rlockKeys is lockKeys for commands only reading keys.
+func (s *Store) rlockKeys(keys ...string) (runlock func()) {
+	shards := s.shardsOf(keys)
+	for _, shard := range shards {
+		s.rlock(shard)
 	}
 
-	if item.Type != TypeSet {
-		return 0, fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
-	}
-
-	set := item.Value.(map[string]struct{})
-	addedCount := 0
-
-	for _, m := range members {
-		// check if already exists
-		if _, exists := set[m]; !exists {
-			set[m] = struct{}{}
-			addedCount++
+	return func() {
+		for i := len(shards) - 1; i >= 0; i-- {
+			s.runlock(shards[i])
 		}
 	}
-
-	return addedCount, nil
 }
 
-// SMembers checks if a member exists in the set
-func (s *Store) SMembers(key string) ([]string, bool) {
-	shard := s.getShard(key)
-	shard.Mu.RLock()
-	defer shard.Mu.RUnlock()
-
-	item, exists := shard.Items[key]
-	if !exists {
-		return []string{}, false
+// shardsOf returns the shards owning keys, each once and in index order.
+func (s *Store) shardsOf(keys []string) []*Shard {
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, s.getShardIndex(key))
 	}
+	sort.Ints(indexes)
 
-	set := item.Value.(map[string]struct{})
-	members := make([]string, 0, len(set))
-
-	for m := range set {
-		members = append(members, m)
+	shards := make([]*Shard, 0, len(indexes))
+	for i, idx := range indexes {
+		if i > 0 && idx == indexes[i-1] {
+			continue
+		}
+		shards = append(shards, s.Shards[idx])
 	}
-
-	return members, true
+	return shards
 }
 
-// SIsMember checks if a member exists in the set
-func (s *Store) SIsMember(key, member string) (int, bool) {
-	shard := s.getShard(key)
-	shard.Mu.RLock()
-	defer shard.Mu.RUnlock()
-
-	item, exists := shard.Items[key]
-	if !exists {
-		return 0, false
-	}
-
-	if item.Type != TypeSet {
-		return 0, false
-	}
-
-	set := item.Value.(map[string]struct{})
-	if _, ok := set[member]; ok {
-		return 1, true
+// isExpired reports whether the item has a TTL that is already past at now
+// (unix nanoseconds), or is a hash whose fields all expired.
+func (item *Item) isExpired(now int64) bool {
+	if item.ExpiresAt > 0 && now > item.ExpiresAt {
+		return true
 	}
-
-	return 0, true
-}
-
-func (s *Store) Delete(key string) {
-	shard := s.getShard(key)
-
-	shard.Mu.Lock()
-	defer shard.Mu.Unlock()
-
-	delete(shard.Items, key)
+	hash, ok := item.Value.(*Hash)
+	return ok && hash.allExpired(now)
 }
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"bytes"
 	"sync"
 	"testing"
 	"time"
@@ -9,7 +10,7 @@ import (
 func TestSetGet(t *testing.T) {
 	s := NewStore()
 	key := "foo"
-	val := "bar"
+	val := []byte("bar")
 
 	// 1. Test Set
 	s.Set(key, val, 0)
@@ -20,7 +21,7 @@ func TestSetGet(t *testing.T) {
 		t.Fatalf("Expected key %s to exist", key)
 	}
 
-	if got != val {
+	if !bytes.Equal(got.([]byte), val) {
 		t.Errorf("Expected %v, got %v", val, got)
 	}
 }
@@ -29,19 +30,20 @@ func TestHSetHGet(t *testing.T) {
 	s := NewStore()
 	key := "foo"
 	field := "foofield"
-	val := "bar"
-	ttl := time.Minute
+	val := []byte("bar")
 
 	// 1. Test HSet
-	s.HSet(key, field, val, ttl)
+	if added, err := s.HSet(key, HashField{Name: field, Value: val}); err != nil || added != 1 {
+		t.Fatalf("Expected 1 new field, got %d (%v)", added, err)
+	}
 
 	// 2. Test Get
-	got, found := s.HGet(key, field)
-	if !found {
+	got, found, err := s.HGet(key, field)
+	if err != nil || !found {
 		t.Fatalf("Expected key %s or field %s to exist", key, field)
 	}
 
-	if got != val {
+	if !bytes.Equal(got, val) {
 		t.Errorf("Expected %v, got %v", val, got)
 	}
 }
@@ -49,34 +51,37 @@ func TestHSetHGet(t *testing.T) {
 func TestLPushLPopLRange(t *testing.T) {
 	s := NewStore()
 	key := "foo"
-	val := "bar"
-	ttl := time.Minute
+	val := []byte("bar")
 
 	// 1. Test LPush
-	s.LPush(key, val, ttl)
+	s.LPush(key, val)
 
 	// 2. Test LPop
-	got, found := s.LPop(key)
-	if !found {
+	got, err := s.LPop(key, 1)
+	if err != nil || len(got) != 1 {
 		t.Fatalf("Expected key %s to exist", key)
 	}
 
-	if got != val {
-		t.Errorf("Expected %v, got %v", val, got)
+	if !bytes.Equal(got[0], val) {
+		t.Errorf("Expected %v, got %v", val, got[0])
 	}
 
 	// 3. Test LRange
-	s.LPush(key, val, ttl)
-	s.LPush(key, val, ttl)
+	s.LPush(key, val, val)
 
-	list, ok := s.LRange(key, 0, 2)
-	if !ok {
-		t.Errorf("Expected %v, got %v", val, got)
+	list, err := s.LRange(key, 0, 2)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
 	}
 
 	if len(list) != 2 {
 		t.Errorf("Expected 2 values but got %d", len(list))
 	}
+
+	// the stop index is inclusive
+	if list, _ := s.LRange(key, 0, 0); len(list) != 1 {
+		t.Errorf("Expected LRange 0 0 to return 1 value, got %d", len(list))
+	}
 }
 
 func TestSAddSMembersSIsMember(t *testing.T) {
@@ -89,8 +94,8 @@ func TestSAddSMembersSIsMember(t *testing.T) {
 		t.Errorf("Error while SAdd: %s", err.Error())
 	}
 
-	addedMembers, ok := s.SMembers(key)
-	if !ok {
+	addedMembers, err := s.SMembers(key)
+	if err != nil {
 		t.Errorf("Error while getting key %s addedMembers", key)
 	}
 
@@ -98,16 +103,46 @@ func TestSAddSMembersSIsMember(t *testing.T) {
 		t.Errorf("addedMembers for %s key, must be 2", key)
 	}
 
-	member, ok := s.SIsMember(key, "bar1")
-	if !ok || member == 0 {
+	member, err := s.SIsMember(key, "bar1")
+	if err != nil || !member {
 		t.Errorf("key %s SIsMember couldn't run.", key)
 	}
 }
 
+func TestBinarySafeValues(t *testing.T) {
+	s := NewStore()
+	key := "bin\x00key\r\n"
+	val := []byte("\x00\xff\r\nvalue with spaces\r\n\x00")
+
+	s.Set(key, val, 0)
+	got, found := s.Get(key)
+	if !found || !bytes.Equal(got.([]byte), val) {
+		t.Errorf("Set/Get: expected %q, got %q", val, got)
+	}
+
+	s.HSet("hash", HashField{Name: "field\r\n\x00", Value: val})
+	hval, found, _ := s.HGet("hash", "field\r\n\x00")
+	if !found || !bytes.Equal(hval, val) {
+		t.Errorf("HSet/HGet: expected %q, got %q", val, hval)
+	}
+
+	s.LPush("list", val)
+	list, _ := s.LRange("list", 0, 1)
+	if len(list) != 1 || !bytes.Equal(list[0], val) {
+		t.Errorf("LPush/LRange: expected [%q], got %q", val, list)
+	}
+
+	s.SAdd("set", []string{string(val)})
+	member, _ := s.SIsMember("set", string(val))
+	if !member {
+		t.Errorf("SAdd/SIsMember: expected binary member to exist")
+	}
+}
+
 func TestExpiration(t *testing.T) {
 	s := NewStore()
 	key := "shortlived"
-	val := "data"
+	val := []byte("data")
 
 	// Set with 10ms TTL
 	s.Set(key, val, 10*time.Millisecond)
@@ -128,6 +163,65 @@ func TestExpiration(t *testing.T) {
 	}
 }
 
+func TestHashFieldExpiration(t *testing.T) {
+	s := NewStore()
+	s.HSet("h", HashField{Name: "short", Value: []byte("1")}, HashField{Name: "long", Value: []byte("2")})
+	s.HSet("gone", HashField{Name: "only", Value: []byte("3")})
+
+	soon := time.Now().Add(10 * time.Millisecond)
+	if got, _ := s.HExpire("h", soon, ExpireFlags{}, []string{"short", "missing"}); got[0] != FieldUpdated || got[1] != FieldMissing {
+		t.Fatalf("HExpire: got %v", got)
+	}
+	s.HExpire("gone", soon, ExpireFlags{}, []string{"only"})
+	if got, _ := s.HExpire("h", soon.Add(time.Hour), ExpireFlags{LT: true}, []string{"short"}); got[0] != FieldNotSet {
+		t.Errorf("HExpire LT with a later deadline: got %v", got)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, found, _ := s.HGet("h", "short"); found {
+		t.Error("Expired field should be hidden")
+	}
+	if n, _ := s.HLen("h"); n != 1 {
+		t.Errorf("Expected 1 live field, got %d", n)
+	}
+
+	(&Janitor{}).vacuum(s)
+
+	shard := s.getShard("h")
+	if _, exists := shard.Items["h"].Value.(*Hash).fields["short"]; exists {
+		t.Error("The janitor should reclaim expired fields")
+	}
+	if _, exists := s.getShard("gone").Items["gone"]; exists {
+		t.Error("The janitor should delete a hash once all its fields expired")
+	}
+}
+
+func TestExpireFlags(t *testing.T) {
+	tests := []struct {
+		flags   ExpireFlags
+		cur, at int64
+		want    bool
+	}{
+		{ExpireFlags{}, 0, 5, true},
+		{ExpireFlags{NX: true}, 0, 5, true},
+		{ExpireFlags{NX: true}, 3, 5, false},
+		{ExpireFlags{XX: true}, 0, 5, false},
+		{ExpireFlags{XX: true}, 3, 5, true},
+		{ExpireFlags{GT: true}, 0, 5, false},
+		{ExpireFlags{GT: true}, 3, 5, true},
+		{ExpireFlags{GT: true}, 7, 5, false},
+		{ExpireFlags{LT: true}, 0, 5, true},
+		{ExpireFlags{LT: true}, 3, 5, false},
+		{ExpireFlags{LT: true}, 7, 5, true},
+	}
+	for _, tt := range tests {
+		if got := tt.flags.allows(tt.cur, tt.at); got != tt.want {
+			t.Errorf("%+v.allows(%d, %d) = %v, want %v", tt.flags, tt.cur, tt.at, got, tt.want)
+		}
+	}
+}
+
 // It fires 100 goroutines to read/write simultaneously.
 // Run with: go test -race
 func TestConcurrency(t *testing.T) {
@@ -139,7 +233,7 @@ func TestConcurrency(t *testing.T) {
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			s.Set("key", "value", 0)
+			s.Set("key", []byte("value"), 0)
 		}(i)
 	}
 
@@ -154,3 +248,117 @@ func TestConcurrency(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestWatchTracksModifications(t *testing.T) {
+	s := NewStore()
+
+	version := s.Watch("key")
+	if s.Modified("key", version) {
+		t.Fatal("Key should not be modified right after Watch")
+	}
+
+	s.Set("other", []byte("v"), 0)
+	if s.Modified("key", version) {
+		t.Error("Writing another key should not modify the watched one")
+	}
+
+	s.Set("key", []byte("v"), 0)
+	if !s.Modified("key", version) {
+		t.Error("Set should mark the watched key as modified")
+	}
+
+	version = s.Watch("key")
+	s.Delete("key")
+	if !s.Modified("key", version) {
+		t.Error("Delete should mark the watched key as modified")
+	}
+
+	s.Set("ttl", []byte("v"), 10*time.Millisecond)
+	version = s.Watch("ttl")
+	time.Sleep(20 * time.Millisecond)
+	if !s.Modified("ttl", version) {
+		t.Error("An expired key should count as modified")
+	}
+
+	s.Unwatch("key")
+	s.Unwatch("key")
+	if len(s.getShard("key").watched) != 0 {
+		t.Error("Unwatch should stop tracking the key once every watcher is gone")
+	}
+}
+
+func TestWithLocked(t *testing.T) {
+	s := NewStore()
+
+	s.WithLocked([]string{"a", "b"}, false, func(tx *Store) {
+		// the view skips the locks it already holds, so this must not deadlock
+		tx.Set("a", []byte("1"), 0)
+		tx.Set("b", []byte("2"), 0)
+		if _, found := tx.Get("a"); !found {
+			t.Error("Expected the view to see its own writes")
+		}
+	})
+
+	if _, found := s.Get("b"); !found {
+		t.Error("Expected writes made through the view to be visible after unlocking")
+	}
+}
+
+// TestRenameIsAtomic moves a value back and forth between keys of different
+// shards while readers check that it is always exactly at one of them.
+func TestRenameIsAtomic(t *testing.T) {
+	s := NewStore()
+	a, b := "a", "b"
+	if s.getShardIndex(a) == s.getShardIndex(b) {
+		t.Fatal("The keys should live in different shards")
+	}
+	s.Set(a, []byte("v"), 0)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if n := s.Exists(a, b); n != 1 {
+					t.Errorf("Expected the value at exactly one key, found %d", n)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 1000; i++ {
+		if i%2 == 0 {
+			s.Rename(a, b, false)
+		} else {
+			s.Rename(b, a, false)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestFlushTouchesWatchedKeys(t *testing.T) {
+	s := NewStore()
+	s.Set("key", []byte("v"), 0)
+	version := s.Watch("key")
+	missing := s.Watch("missing")
+
+	s.Flush()
+	if !s.Modified("key", version) {
+		t.Error("Flushing should mark the watched key as modified")
+	}
+	if s.Modified("missing", missing) {
+		t.Error("Flushing should leave watched keys that didn't exist alone")
+	}
+	if n := s.DBSize(); n != 0 {
+		t.Errorf("Expected an empty store after a flush, got %d keys", n)
+	}
+}
@@ -0,0 +1,940 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStreamIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrStreamIDZero     = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	ErrStreamExhausted  = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+	ErrNoStream         = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	ErrGroupExists      = errors.New("BUSYGROUP Consumer Group name already exists")
+	// ErrNoGroup is returned when the key or the consumer group doesn't exist.
+	// Redis names both in the message, so commands build their own reply from it.
+	ErrNoGroup = errors.New("NOGROUP No such key or consumer group")
+)
+
+// StreamID identifies a stream entry: a millisecond timestamp and a sequence
+// number telling apart the entries added within the same millisecond.
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// MaxStreamID is the greatest possible ID, "+" in ranges.
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// ParseStreamID parses "ms-seq", or "ms" alone in which case the sequence is missingSeq.
+func ParseStreamID(s string, missingSeq uint64) (StreamID, bool) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	if !hasSeq {
+		return StreamID{Ms: ms, Seq: missingSeq}, true
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	return StreamID{Ms: ms, Seq: seq}, true
+}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Less reports whether id sorts before other.
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq)
+}
+
+// Next returns the smallest ID greater than id, false when id is the maximum.
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	}
+	return id, false
+}
+
+// Prev returns the greatest ID smaller than id, false when id is 0-0.
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// StreamEntry is one entry of a stream. Fields alternates field names and values.
+// Entries read back from a pending list after they were trimmed have nil Fields.
+type StreamEntry struct {
+	ID     StreamID
+	Fields [][]byte
+}
+
+// Stream is an append-only log of entries ordered by ID, with its consumer groups.
+// Entries only ever get removed from the front (trimming), so a sorted slice
+// with binary search is all the indexing we need.
+type Stream struct {
+	entries []StreamEntry
+	lastID  StreamID
+	groups  map[string]*ConsumerGroup
+}
+
+// ConsumerGroup tracks what was delivered to a group and not acknowledged yet.
+type ConsumerGroup struct {
+	lastID  StreamID
+	pending map[StreamID]*PendingEntry
+	// sorted holds the entries of pending in ID order, so that XPENDING and
+	// XAUTOCLAIM find where to start with a binary search. Only addPending
+	// and removePending change them.
+	sorted    []*PendingEntry
+	consumers map[string]*consumer
+}
+
+type consumer struct {
+	seenAt int64
+}
+
+// PendingEntry is an entry delivered to a consumer and not acknowledged yet.
+type PendingEntry struct {
+	ID       StreamID
+	Consumer string
+	// DeliveredAt is the last delivery time in unix milliseconds.
+	DeliveredAt int64
+	Deliveries  int
+}
+
+// NewStream creates an empty stream.
+func NewStream() *Stream {
+	return &Stream{groups: make(map[string]*ConsumerGroup)}
+}
+
+// clone returns a copy of the stream and its consumer groups. Entries are
+// never modified once added, so the copy shares their fields.
+func (st *Stream) clone() *Stream {
+	c := &Stream{
+		entries: slices.Clone(st.entries),
+		lastID:  st.lastID,
+		groups:  make(map[string]*ConsumerGroup, len(st.groups)),
+	}
+	for name, g := range st.groups {
+		cg := &ConsumerGroup{
+			lastID:    g.lastID,
+			pending:   make(map[StreamID]*PendingEntry, len(g.pending)),
+			consumers: make(map[string]*consumer, len(g.consumers)),
+		}
+		cg.sorted = make([]*PendingEntry, len(g.sorted))
+		for i, p := range g.sorted {
+			pe := *p
+			cg.pending[pe.ID], cg.sorted[i] = &pe, &pe
+		}
+		for n, cons := range g.consumers {
+			cc := *cons
+			cg.consumers[n] = &cc
+		}
+		c.groups[name] = cg
+	}
+	return c
+}
+
+// Len returns the number of entries.
+func (st *Stream) Len() int {
+	return len(st.entries)
+}
+
+// search returns the index of the first entry whose ID is not below id.
+func (st *Stream) search(id StreamID) int {
+	return sort.Search(len(st.entries), func(i int) bool { return !st.entries[i].ID.Less(id) })
+}
+
+// lookup returns the entry with the given ID.
+func (st *Stream) lookup(id StreamID) (StreamEntry, bool) {
+	i := st.search(id)
+	if i < len(st.entries) && st.entries[i].ID == id {
+		return st.entries[i], true
+	}
+	return StreamEntry{}, false
+}
+
+// Range returns the entries with start <= ID <= end, at most count of them
+// when count > 0, from the end when rev is set.
+func (st *Stream) Range(start, end StreamID, rev bool, count int) []StreamEntry {
+	if end.Less(start) {
+		return nil
+	}
+
+	lo := st.search(start)
+	hi := lo + sort.Search(len(st.entries)-lo, func(i int) bool { return end.Less(st.entries[lo+i].ID) })
+	selected := st.entries[lo:hi]
+
+	if count > 0 && len(selected) > count {
+		if rev {
+			selected = selected[len(selected)-count:]
+		} else {
+			selected = selected[:count]
+		}
+	}
+
+	result := make([]StreamEntry, len(selected))
+	copy(result, selected)
+	if rev {
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	return result
+}
+
+// after returns up to count entries (all when count <= 0) with an ID greater than id.
+func (st *Stream) after(id StreamID, count int) []StreamEntry {
+	start, ok := id.Next()
+	if !ok {
+		return nil
+	}
+	return st.Range(start, MaxStreamID, false, count)
+}
+
+// TrimStrategy selects how XADD trims a stream.
+type TrimStrategy int
+
+const (
+	TrimNone TrimStrategy = iota
+	TrimMaxLen
+	TrimMinID
+)
+
+// StreamTrim is the MAXLEN / MINID clause of XADD.
+type StreamTrim struct {
+	Strategy TrimStrategy
+	MaxLen   int
+	MinID    StreamID
+	// Limit caps the number of entries evicted at once, 0 means no cap.
+	Limit int
+}
+
+// trim evicts the oldest entries according to t and returns how many went.
+func (st *Stream) trim(t StreamTrim) int {
+	n := 0
+	switch t.Strategy {
+	case TrimMaxLen:
+		n = max(len(st.entries)-t.MaxLen, 0)
+	case TrimMinID:
+		n = st.search(t.MinID)
+	}
+	if t.Limit > 0 && n > t.Limit {
+		n = t.Limit
+	}
+
+	// let the evicted fields be collected even though the array is reused
+	clear(st.entries[:n])
+	st.entries = st.entries[n:]
+	return n
+}
+
+// XAddArgs are the arguments of XADD.
+type XAddArgs struct {
+	// ID is the explicit entry ID. AutoID generates it entirely ("*"),
+	// AutoSeq only generates the sequence part of ID ("1526919030474-*").
+	ID      StreamID
+	AutoID  bool
+	AutoSeq bool
+	// NoMkStream makes XAdd fail instead of creating a missing stream.
+	NoMkStream bool
+	Trim       StreamTrim
+	Fields     [][]byte
+}
+
+// nextID computes the ID of the entry XADD is about to append.
+func (st *Stream) nextID(args *XAddArgs, nowMs uint64) (StreamID, error) {
+	last := st.lastID
+
+	switch {
+	case args.AutoID:
+		if nowMs > last.Ms {
+			return StreamID{Ms: nowMs}, nil
+		}
+		id, ok := last.Next()
+		if !ok {
+			return id, ErrStreamExhausted
+		}
+		return id, nil
+
+	case args.AutoSeq:
+		ms := args.ID.Ms
+		if ms < last.Ms || ms == last.Ms && last.Seq == math.MaxUint64 {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		if ms == last.Ms {
+			// also turns "0-*" into 0-1 on an empty stream, 0-0 is never valid
+			return StreamID{Ms: ms, Seq: last.Seq + 1}, nil
+		}
+		return StreamID{Ms: ms}, nil
+	}
+
+	if args.ID == (StreamID{}) {
+		return StreamID{}, ErrStreamIDZero
+	}
+	if !last.Less(args.ID) {
+		return StreamID{}, ErrStreamIDTooSmall
+	}
+	return args.ID, nil
+}
+
+// getStream returns the stream at key for reading, nil when missing.
+func getStream(shard *Shard, key string) (*Stream, error) {
+	item := shard.readItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeStream {
+		return nil, ErrWrongType
+	}
+	return item.Value.(*Stream), nil
+}
+
+// streamForWrite returns the stream at key, nil when missing.
+// The shard write lock must be held.
+func streamForWrite(shard *Shard, key string) (*Stream, error) {
+	item := shard.writeItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeStream {
+		return nil, ErrWrongType
+	}
+	return item.Value.(*Stream), nil
+}
+
+// XAdd appends an entry to the stream at key, creating the stream unless
+// NoMkStream is set, and trims it. It returns the ID of the new entry,
+// or false when the stream doesn't exist and NoMkStream was given.
+func (s *Store) XAdd(key string, args XAddArgs) (StreamID, bool, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	st, err := streamForWrite(shard, key)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	created := false
+	if st == nil {
+		if args.NoMkStream {
+			return StreamID{}, false, nil
+		}
+		st = NewStream()
+		created = true
+	}
+
+	id, err := st.nextID(&args, uint64(time.Now().UnixMilli()))
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if created {
+		shard.put(key, &Item{Value: st, Type: TypeStream})
+	}
+
+	st.entries = append(st.entries, StreamEntry{ID: id, Fields: args.Fields})
+	st.lastID = id
+	st.trim(args.Trim)
+
+	shard.touch(key)
+	shard.signalWaiters(key)
+
+	return id, true, nil
+}
+
+// XLen returns the number of entries in the stream at key.
+func (s *Store) XLen(key string) (int, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	st, err := getStream(shard, key)
+	if st == nil {
+		return 0, err
+	}
+	return st.Len(), nil
+}
+
+// XRange returns the entries of the stream at key between start and end
+// (both inclusive), see Stream.Range.
+func (s *Store) XRange(key string, start, end StreamID, rev bool, count int) ([]StreamEntry, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	st, err := getStream(shard, key)
+	if st == nil {
+		return nil, err
+	}
+	return st.Range(start, end, rev, count), nil
+}
+
+// XRead returns up to count entries (all when count <= 0) of the stream at
+// key with an ID greater than id.
+func (s *Store) XRead(key string, id StreamID, count int) ([]StreamEntry, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	st, err := getStream(shard, key)
+	if st == nil {
+		return nil, err
+	}
+	return st.after(id, count), nil
+}
+
+// XLastID returns the ID of the last entry added to the stream at key
+// ("$" in XREAD and XGROUP), 0-0 when the key doesn't exist.
+func (s *Store) XLastID(key string) (StreamID, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	st, err := getStream(shard, key)
+	if st == nil {
+		return StreamID{}, err
+	}
+	return st.lastID, nil
+}
+
+// groupForWrite returns the stream at key and its consumer group, or ErrNoGroup.
+// The shard write lock must be held.
+func groupForWrite(shard *Shard, key, group string) (*Stream, *ConsumerGroup, error) {
+	st, err := streamForWrite(shard, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if st == nil {
+		return nil, nil, ErrNoGroup
+	}
+	g, ok := st.groups[group]
+	if !ok {
+		return nil, nil, ErrNoGroup
+	}
+	return st, g, nil
+}
+
+// XGroupCreate creates a consumer group starting after id, or after the
+// last entry when useLast is set ("$"). A missing stream is created only
+// with mkStream. It returns the ID the group starts from.
+func (s *Store) XGroupCreate(key, group string, id StreamID, useLast, mkStream bool) (StreamID, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	st, err := streamForWrite(shard, key)
+	if err != nil {
+		return StreamID{}, err
+	}
+	if st == nil {
+		if !mkStream {
+			return StreamID{}, ErrNoStream
+		}
+		st = NewStream()
+		shard.put(key, &Item{Value: st, Type: TypeStream})
+	}
+
+	if _, exists := st.groups[group]; exists {
+		return StreamID{}, ErrGroupExists
+	}
+	if useLast {
+		id = st.lastID
+	}
+
+	st.groups[group] = &ConsumerGroup{
+		lastID:    id,
+		pending:   make(map[StreamID]*PendingEntry),
+		consumers: make(map[string]*consumer),
+	}
+	shard.touch(key)
+
+	return id, nil
+}
+
+// XGroupExists reports whether the stream at key has a consumer group called group.
+func (s *Store) XGroupExists(key, group string) (bool, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	_, err := readGroup(shard, key, group)
+	if err == ErrNoGroup {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// XGroupSetID moves the last delivered ID of a group, see XGroupCreate.
+func (s *Store) XGroupSetID(key, group string, id StreamID, useLast bool) (StreamID, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	st, err := streamForWrite(shard, key)
+	if err != nil {
+		return StreamID{}, err
+	}
+	if st == nil {
+		return StreamID{}, ErrNoStream
+	}
+	g, ok := st.groups[group]
+	if !ok {
+		return StreamID{}, ErrNoGroup
+	}
+
+	if useLast {
+		id = st.lastID
+	}
+	g.lastID = id
+	shard.touch(key)
+
+	return id, nil
+}
+
+// XGroupDestroy deletes a consumer group and its pending entries.
+func (s *Store) XGroupDestroy(key, group string) (bool, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	st, err := streamForWrite(shard, key)
+	if err != nil {
+		return false, err
+	}
+	if st == nil {
+		return false, ErrNoStream
+	}
+	if _, ok := st.groups[group]; !ok {
+		return false, nil
+	}
+
+	delete(st.groups, group)
+	shard.touch(key)
+
+	return true, nil
+}
+
+// XGroupCreateConsumer adds a consumer to a group, false if it already exists.
+func (s *Store) XGroupCreateConsumer(key, group, name string) (bool, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	_, g, err := groupForWrite(shard, key, group)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := g.consumers[name]; exists {
+		return false, nil
+	}
+
+	g.consumers[name] = &consumer{seenAt: time.Now().UnixMilli()}
+	shard.touch(key)
+
+	return true, nil
+}
+
+// XGroupDelConsumer removes a consumer and its pending entries from a group.
+// It returns how many pending entries the consumer had.
+func (s *Store) XGroupDelConsumer(key, group, name string) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	_, g, err := groupForWrite(shard, key, group)
+	if err != nil {
+		return 0, err
+	}
+	if _, exists := g.consumers[name]; !exists {
+		return 0, nil
+	}
+
+	deleted := 0
+	g.sorted = slices.DeleteFunc(g.sorted, func(p *PendingEntry) bool {
+		if p.Consumer != name {
+			return false
+		}
+		delete(g.pending, p.ID)
+		deleted++
+		return true
+	})
+	delete(g.consumers, name)
+	shard.touch(key)
+
+	return deleted, nil
+}
+
+// touchConsumer returns the named consumer, creating it when needed.
+func (g *ConsumerGroup) touchConsumer(name string, now int64) *consumer {
+	c, ok := g.consumers[name]
+	if !ok {
+		c = &consumer{}
+		g.consumers[name] = c
+	}
+	c.seenAt = now
+	return c
+}
+
+// search returns the index in g.sorted of the first entry whose ID is not
+// less than id.
+func (g *ConsumerGroup) search(id StreamID) int {
+	return sort.Search(len(g.sorted), func(i int) bool { return !g.sorted[i].ID.Less(id) })
+}
+
+// pendingFrom returns the pending entries from ID start on, in ID order.
+// The slice must not be kept across changes to the pending list.
+func (g *ConsumerGroup) pendingFrom(start StreamID) []*PendingEntry {
+	return g.sorted[g.search(start):]
+}
+
+// addPending adds p to the pending list, replacing the entry with its ID.
+func (g *ConsumerGroup) addPending(p *PendingEntry) {
+	i := g.search(p.ID)
+	if _, exists := g.pending[p.ID]; exists {
+		g.sorted[i] = p
+	} else {
+		g.sorted = slices.Insert(g.sorted, i, p)
+	}
+	g.pending[p.ID] = p
+}
+
+// removePending removes id from the pending list and reports whether it was there.
+func (g *ConsumerGroup) removePending(id StreamID) bool {
+	if _, exists := g.pending[id]; !exists {
+		return false
+	}
+	delete(g.pending, id)
+	i := g.search(id)
+	g.sorted = slices.Delete(g.sorted, i, i+1)
+	return true
+}
+
+// XReadGroupArgs are the arguments of XREADGROUP for one stream.
+type XReadGroupArgs struct {
+	Group, Consumer string
+	// New reads entries never delivered to the group (">"). Otherwise the
+	// consumer's own pending entries with an ID greater than ID are returned.
+	New   bool
+	ID    StreamID
+	Count int
+	// NoAck delivers new entries without adding them to the pending list.
+	NoAck bool
+	// Now is the delivery time recorded in the pending list, in unix milliseconds.
+	Now int64
+}
+
+// XReadGroup reads the stream at key on behalf of a consumer of a group.
+func (s *Store) XReadGroup(key string, args XReadGroupArgs) ([]StreamEntry, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	st, g, err := groupForWrite(shard, key, args.Group)
+	if err != nil {
+		return nil, err
+	}
+	g.touchConsumer(args.Consumer, args.Now)
+
+	if !args.New {
+		var entries []StreamEntry
+		for _, p := range g.pendingFrom(args.ID) {
+			if p.Consumer != args.Consumer || p.ID == args.ID {
+				continue
+			}
+			if args.Count > 0 && len(entries) == args.Count {
+				break
+			}
+			entry, _ := st.lookup(p.ID)
+			entries = append(entries, StreamEntry{ID: p.ID, Fields: entry.Fields})
+			p.DeliveredAt = args.Now
+			p.Deliveries++
+		}
+		if len(entries) > 0 {
+			shard.touch(key)
+		}
+		return entries, nil
+	}
+
+	entries := st.after(g.lastID, args.Count)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	g.lastID = entries[len(entries)-1].ID
+	if !args.NoAck {
+		for _, e := range entries {
+			// the entry may already be pending if the group was moved back with SETID
+			g.addPending(&PendingEntry{
+				ID:          e.ID,
+				Consumer:    args.Consumer,
+				DeliveredAt: args.Now,
+				Deliveries:  1,
+			})
+		}
+	}
+	shard.touch(key)
+
+	return entries, nil
+}
+
+// XAck removes ids from the pending list of a group and returns how many were there.
+func (s *Store) XAck(key, group string, ids []StreamID) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	_, g, err := groupForWrite(shard, key, group)
+	if err == ErrNoGroup {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	acked := 0
+	for _, id := range ids {
+		if g.removePending(id) {
+			acked++
+		}
+	}
+	if acked > 0 {
+		shard.touch(key)
+	}
+	return acked, nil
+}
+
+// ConsumerPending is the number of pending entries of one consumer.
+type ConsumerPending struct {
+	Name  string
+	Count int
+}
+
+// PendingSummary is the short form of XPENDING.
+type PendingSummary struct {
+	Count    int
+	Min, Max StreamID
+	// Consumers lists the consumers having pending entries, by name.
+	Consumers []ConsumerPending
+}
+
+// XPendingSummary summarises the pending list of a group.
+func (s *Store) XPendingSummary(key, group string) (PendingSummary, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	var summary PendingSummary
+	g, err := readGroup(shard, key, group)
+	if err != nil {
+		return summary, err
+	}
+
+	counts := make(map[string]int)
+	for _, p := range g.sorted {
+		if summary.Count == 0 {
+			summary.Min = p.ID
+		}
+		summary.Max = p.ID
+		summary.Count++
+		counts[p.Consumer]++
+	}
+	for name, n := range counts {
+		summary.Consumers = append(summary.Consumers, ConsumerPending{Name: name, Count: n})
+	}
+	sort.Slice(summary.Consumers, func(i, j int) bool { return summary.Consumers[i].Name < summary.Consumers[j].Name })
+
+	return summary, nil
+}
+
+// XPendingArgs select the pending entries returned by the extended form of XPENDING.
+type XPendingArgs struct {
+	Start, End StreamID
+	Count      int
+	// Consumer restricts the result to one consumer when not empty.
+	Consumer string
+	// MinIdle (milliseconds) skips entries delivered less than MinIdle before Now.
+	MinIdle, Now int64
+}
+
+// XPending lists pending entries of a group in ID order.
+func (s *Store) XPending(key, group string, args XPendingArgs) ([]PendingEntry, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	g, err := readGroup(shard, key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []PendingEntry
+	for _, p := range g.pendingFrom(args.Start) {
+		if len(result) == args.Count || args.End.Less(p.ID) {
+			break
+		}
+		if args.Consumer != "" && p.Consumer != args.Consumer || args.Now-p.DeliveredAt < args.MinIdle {
+			continue
+		}
+		result = append(result, *p)
+	}
+	return result, nil
+}
+
+// readGroup returns a consumer group for reading, or ErrNoGroup.
+func readGroup(shard *Shard, key, group string) (*ConsumerGroup, error) {
+	st, err := getStream(shard, key)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, ErrNoGroup
+	}
+	g, ok := st.groups[group]
+	if !ok {
+		return nil, ErrNoGroup
+	}
+	return g, nil
+}
+
+// XClaimArgs are the arguments of XCLAIM.
+type XClaimArgs struct {
+	Group, Consumer string
+	// MinIdle (milliseconds) only claims entries delivered at least MinIdle before Now.
+	MinIdle, Now int64
+	IDs          []StreamID
+	// DeliveredAt is the delivery time recorded for claimed entries (IDLE, TIME or Now).
+	DeliveredAt int64
+	// RetryCount sets the delivery counter, when >= 0 (RETRYCOUNT).
+	RetryCount int
+	// Force creates the pending entry of IDs no consumer has been delivered yet.
+	Force bool
+	// JustID doesn't count the claim as a delivery.
+	JustID bool
+	// LastID moves the last delivered ID of the group forward.
+	LastID StreamID
+}
+
+// claim gives the pending entry id to args.Consumer. It reports whether
+// the entry was claimed, and whether it was dropped from the pending list
+// because it no longer exists in the stream.
+func (g *ConsumerGroup) claim(st *Stream, id StreamID, args *XClaimArgs) (entry StreamEntry, claimed, deleted bool) {
+	p, ok := g.pending[id]
+	entry, exists := st.lookup(id)
+
+	if !ok {
+		if !args.Force || !exists {
+			return entry, false, false
+		}
+		// like Redis, a new pending entry counts as delivered once
+		p = &PendingEntry{ID: id, Deliveries: 1}
+		g.addPending(p)
+	}
+	if !exists {
+		g.removePending(id)
+		return entry, false, true
+	}
+	if args.MinIdle > 0 && args.Now-p.DeliveredAt < args.MinIdle {
+		return entry, false, false
+	}
+
+	p.Consumer = args.Consumer
+	p.DeliveredAt = args.DeliveredAt
+	if args.RetryCount >= 0 {
+		p.Deliveries = args.RetryCount
+	} else if !args.JustID {
+		p.Deliveries++
+	}
+	return entry, true, false
+}
+
+// XClaim changes the owner of pending entries. It returns the claimed
+// entries and the IDs dropped from the pending list because they were
+// trimmed from the stream.
+func (s *Store) XClaim(key string, args XClaimArgs) ([]StreamEntry, []StreamID, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	st, g, err := groupForWrite(shard, key, args.Group)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if g.lastID.Less(args.LastID) {
+		g.lastID = args.LastID
+	}
+	g.touchConsumer(args.Consumer, args.Now)
+
+	var claimed []StreamEntry
+	var deleted []StreamID
+	for _, id := range args.IDs {
+		entry, ok, gone := g.claim(st, id, &args)
+		if ok {
+			claimed = append(claimed, entry)
+		}
+		if gone {
+			deleted = append(deleted, id)
+		}
+	}
+	shard.touch(key)
+
+	return claimed, deleted, nil
+}
+
+// XAutoClaim claims up to count pending entries idle for at least
+// args.MinIdle, scanning the pending list from start. Besides the claimed
+// entries and the deleted IDs (see XClaim), it returns the ID to resume
+// the scan from, 0-0 once the whole list was scanned.
+func (s *Store) XAutoClaim(key string, start StreamID, count int, args XClaimArgs) (StreamID, []StreamEntry, []StreamID, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	st, g, err := groupForWrite(shard, key, args.Group)
+	if err != nil {
+		return StreamID{}, nil, nil, err
+	}
+	g.touchConsumer(args.Consumer, args.Now)
+
+	// like Redis, bound the work done by a single call
+	attempts := count * 10
+	var claimed []StreamEntry
+	var deleted []StreamID
+	i := g.search(start)
+	for ; i < len(g.sorted) && attempts > 0 && len(claimed) < count; attempts-- {
+		id := g.sorted[i].ID
+		entry, ok, gone := g.claim(st, id, &args)
+		if ok {
+			claimed = append(claimed, entry)
+		}
+		if gone {
+			// the entry left the pending list: the next one took its place
+			deleted = append(deleted, id)
+		} else {
+			i++
+		}
+	}
+	shard.touch(key)
+
+	next := StreamID{}
+	if i < len(g.sorted) {
+		next = g.sorted[i].ID
+	}
+	return next, claimed, deleted, nil
+}
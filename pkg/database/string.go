@@ -0,0 +1,395 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotInteger   = errors.New("ERR value is not an integer or out of range")
+	ErrNotFloat     = errors.New("ERR value is not a valid float")
+	ErrStringTooBig = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+)
+
+// MaxStringLen is the largest string SETRANGE and APPEND may build, like
+// Redis' default proto-max-bulk-len.
+const MaxStringLen = 512 << 20
+
+// String values may share their backing array with the arguments of the
+// command that stored them, or with the replies of the commands that read
+// them, so they are copied before being changed. Only the strings an item
+// owns are modified in place, which the bitmap writes rely on not to copy a
+// whole string to change a few bits: storeString takes ownership of the
+// value it is given, and shareString gives it up when handing the value out.
+
+// getString returns the item holding the string at key for reading, nil when missing.
+func getString(shard *Shard, key string) (*Item, error) {
+	item := shard.readItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeString {
+		return nil, ErrWrongType
+	}
+	return item, nil
+}
+
+// stringForWrite is getString for callers holding the shard write lock.
+func stringForWrite(shard *Shard, key string) (*Item, error) {
+	item := shard.writeItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeString {
+		return nil, ErrWrongType
+	}
+	return item, nil
+}
+
+// storeString sets the string at key, keeping the TTL of the string it
+// replaces, if any. value must share its backing array with nothing else:
+// the item owns it. The caller holds the shard write lock.
+func storeString(shard *Shard, key string, item *Item, value []byte) {
+	if item == nil {
+		item = &Item{Type: TypeString}
+		shard.put(key, item)
+	}
+	item.Value = value
+	item.owned.Store(true)
+	shard.touch(key)
+}
+
+// shareString returns the string held by item for use after the shard lock
+// is released, so item no longer owns it. The caller holds the shard lock.
+func shareString(item *Item) []byte {
+	item.owned.Store(false)
+	return item.Value.([]byte)
+}
+
+// SetArgs are the options of SET.
+type SetArgs struct {
+	// NX only sets missing keys, XX only existing ones.
+	NX, XX bool
+	// At is the deadline of the key, none when zero.
+	At time.Time
+	// KeepTTL keeps the deadline of the key being replaced.
+	KeepTTL bool
+	// Get asks for the string the key held, which makes a key of another type an error.
+	Get bool
+}
+
+// SetWith stores value at key whatever it held before, under the conditions
+// of args. It reports whether it stored the value, and returns the string
+// the key held before (nil when missing or of another type).
+func (s *Store) SetWith(key string, value []byte, args SetArgs) (old []byte, stored bool, err error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item := shard.writeItem(key)
+	if item != nil {
+		if item.Type == TypeString {
+			// NX keeps the item, which mustn't change old once returned
+			old = shareString(item)
+		} else if args.Get {
+			return nil, false, ErrWrongType
+		}
+	}
+	if args.NX && item != nil || args.XX && item == nil {
+		return old, false, nil
+	}
+
+	var expiresAt int64
+	switch {
+	case !args.At.IsZero():
+		expiresAt = args.At.UnixNano()
+	case args.KeepTTL && item != nil:
+		expiresAt = item.ExpiresAt
+	}
+	shard.put(key, &Item{Value: value, Type: TypeString, ExpiresAt: expiresAt})
+	shard.touch(key)
+	return old, true, nil
+}
+
+// IncrBy adds delta to the integer stored at key, a missing key counting as 0,
+// and returns the new value. The TTL of the key is kept.
+func (s *Store) IncrBy(key string, delta int64) (int64, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := stringForWrite(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var cur int64
+	if item != nil {
+		if cur, err = strconv.ParseInt(string(item.Value.([]byte)), 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	if delta > 0 && cur > math.MaxInt64-delta || delta < 0 && cur < math.MinInt64-delta {
+		return 0, ErrOverflow
+	}
+
+	cur += delta
+	storeString(shard, key, item, strconv.AppendInt(nil, cur, 10))
+	return cur, nil
+}
+
+// IncrByFloat adds delta to the number stored at key, a missing key counting
+// as 0, and returns the new value as stored. The TTL of the key is kept.
+func (s *Store) IncrByFloat(key string, delta float64) ([]byte, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := stringForWrite(shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var cur float64
+	if item != nil {
+		cur, err = strconv.ParseFloat(string(item.Value.([]byte)), 64)
+		if err != nil || math.IsNaN(cur) {
+			return nil, ErrNotFloat
+		}
+	}
+
+	cur += delta
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		return nil, ErrNaNOrInfinity
+	}
+
+	val := strconv.AppendFloat(nil, cur, 'f', -1, 64)
+	storeString(shard, key, item, val)
+	return val, nil
+}
+
+// Append appends value to the string at key, creating it when missing,
+// and returns the new length.
+func (s *Store) Append(key string, value []byte) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := stringForWrite(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var cur []byte
+	if item != nil {
+		cur = item.Value.([]byte)
+	}
+	if len(cur)+len(value) > MaxStringLen {
+		return 0, ErrStringTooBig
+	}
+
+	val := make([]byte, 0, len(cur)+len(value))
+	val = append(append(val, cur...), value...)
+	storeString(shard, key, item, val)
+	return len(val), nil
+}
+
+// StrLen returns the length of the string at key, 0 when missing.
+func (s *Store) StrLen(key string) (int, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	item, err := getString(shard, key)
+	if item == nil {
+		return 0, err
+	}
+	return len(item.Value.([]byte)), nil
+}
+
+// GetRange returns the substring of the string at key between start and end,
+// both inclusive. Negative offsets count from the end of the string.
+func (s *Store) GetRange(key string, start, end int) ([]byte, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	item, err := getString(shard, key)
+	if item == nil {
+		return nil, err
+	}
+
+	val := shareString(item)
+	n := len(val)
+	if start < 0 {
+		start = max(start+n, 0)
+	}
+	if end < 0 {
+		end = max(end+n, 0)
+	}
+	end = min(end, n-1)
+	if start > end || n == 0 {
+		return nil, nil
+	}
+	return val[start : end+1], nil
+}
+
+// SetRange overwrites the string at key from offset with value, padding it
+// with zero bytes when it is shorter than offset, and returns the new length.
+// A missing key is only created when value is not empty.
+func (s *Store) SetRange(key string, offset int, value []byte) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := stringForWrite(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var cur []byte
+	if item != nil {
+		cur = item.Value.([]byte)
+	}
+	if len(value) == 0 {
+		return len(cur), nil
+	}
+	if offset+len(value) > MaxStringLen {
+		return 0, ErrStringTooBig
+	}
+
+	val := make([]byte, max(len(cur), offset+len(value)))
+	copy(val, cur)
+	copy(val[offset:], value)
+	storeString(shard, key, item, val)
+	return len(val), nil
+}
+
+// MGet returns the strings at keys, nil for the keys that are missing or
+// hold another type. The keys are read at the same instant.
+func (s *Store) MGet(keys []string) [][]byte {
+	runlock := s.rlockKeys(keys...)
+	defer runlock()
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		if item, _ := getString(s.getShard(key), key); item != nil {
+			values[i] = shareString(item)
+		}
+	}
+	return values
+}
+
+// KeyValue is a key with the string to store there.
+type KeyValue struct {
+	Key   string
+	Value []byte
+}
+
+// MSet stores all of pairs at once, later pairs winning over earlier ones
+// with the same key. Like SET it overwrites any type and drops the TTLs.
+func (s *Store) MSet(pairs []KeyValue) {
+	s.mset(pairs, false)
+}
+
+// MSetNX is MSet storing nothing unless all the keys are missing.
+// It reports whether it stored the pairs.
+func (s *Store) MSetNX(pairs []KeyValue) bool {
+	return s.mset(pairs, true)
+}
+
+func (s *Store) mset(pairs []KeyValue, onlyNew bool) bool {
+	keys := make([]string, len(pairs))
+	for i, p := range pairs {
+		keys[i] = p.Key
+	}
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	if onlyNew {
+		for _, key := range keys {
+			if s.getShard(key).writeItem(key) != nil {
+				return false
+			}
+		}
+	}
+
+	for _, p := range pairs {
+		shard := s.getShard(p.Key)
+		shard.put(p.Key, &Item{Value: p.Value, Type: TypeString})
+		shard.touch(p.Key)
+	}
+	return true
+}
+
+// GetSet stores value at key, dropping its TTL, and returns the string it
+// held before, nil when missing.
+func (s *Store) GetSet(key string, value []byte) ([]byte, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := stringForWrite(shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	shard.put(key, &Item{Value: value, Type: TypeString})
+	shard.touch(key)
+	if item == nil {
+		return nil, nil
+	}
+	return item.Value.([]byte), nil
+}
+
+// GetDel deletes the string at key and returns it, nil when missing.
+func (s *Store) GetDel(key string) ([]byte, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := stringForWrite(shard, key)
+	if item == nil {
+		return nil, err
+	}
+
+	shard.remove(key)
+	shard.touch(key)
+	return item.Value.([]byte), nil
+}
+
+// GetExArgs are the options of GETEX.
+type GetExArgs struct {
+	// At becomes the deadline of the key when it is not zero.
+	// A deadline already past deletes the key.
+	At time.Time
+	// Persist drops the TTL of the key.
+	Persist bool
+}
+
+// GetEx returns the string at key, nil when missing, and updates its TTL as args say.
+func (s *Store) GetEx(key string, args GetExArgs) ([]byte, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	item, err := stringForWrite(shard, key)
+	if item == nil {
+		return nil, err
+	}
+
+	switch {
+	case !args.At.IsZero() && !args.At.After(time.Now()):
+		shard.remove(key)
+		shard.touch(key)
+	case !args.At.IsZero():
+		item.ExpiresAt = args.At.UnixNano()
+		shard.touch(key)
+	case args.Persist && item.ExpiresAt != 0:
+		item.ExpiresAt = 0
+		shard.touch(key)
+	}
+	return shareString(item), nil
+}
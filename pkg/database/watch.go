@@ -0,0 +1,161 @@
+package database
+
+import (
+	"sort"
+	"time"
+)
+
+// watchedKey is the modification counter of a key at least one client WATCHes.
+// Keys nobody watches are not tracked, so writes only pay for a map lookup.
+type watchedKey struct {
+	refs    int
+	version uint64
+}
+
+// touch records that key was modified. The caller holds the shard write lock,
+// which makes the version bump atomic with the modification itself.
+func (shard *Shard) touch(key string) {
+	if w, ok := shard.watched[key]; ok {
+		w.version++
+	}
+}
+
+// Watch starts tracking modifications of key and returns its current version.
+// Every call must be paired with Unwatch.
+func (s *Store) Watch(key string) uint64 {
+	shard := s.getShard(key)
+
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	// reap an already expired value now, so that the expiration is not
+	// mistaken for a modification that happened after the WATCH
+	if item, exists := shard.Items[key]; exists && item.isExpired(time.Now().UnixNano()) {
+		shard.remove(key)
+		shard.touch(key)
+	}
+
+	w, ok := shard.watched[key]
+	if !ok {
+		w = &watchedKey{}
+		shard.watched[key] = w
+	}
+	w.refs++
+
+	return w.version
+}
+
+// Unwatch stops tracking key for one watcher.
+func (s *Store) Unwatch(key string) {
+	shard := s.getShard(key)
+
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	if w, ok := shard.watched[key]; ok {
+		w.refs--
+		if w.refs <= 0 {
+			delete(shard.watched, key)
+		}
+	}
+}
+
+// Modified reports whether key changed since Watch returned version.
+// A key that expired in the meantime counts as modified.
+func (s *Store) Modified(key string, version uint64) bool {
+	shard := s.getShard(key)
+
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	w, ok := shard.watched[key]
+	if !ok || w.version != version {
+		return true
+	}
+
+	item, exists := shard.Items[key]
+	return exists && item.isExpired(time.Now().UnixNano())
+}
+
+// WithLocked runs fn while holding the write locks of the shards owning keys
+// (or of every shard when allShards is true), taken in index order so that
+// concurrent callers can't deadlock. fn receives a view of the store whose
+// methods skip those locks: everything fn does looks like a single step to
+// other clients. The view must only be used inside fn, for the given keys.
+func (s *Store) WithLocked(keys []string, allShards bool, fn func(tx *Store)) {
+	var indexes []int
+	if allShards {
+		indexes = make([]int, len(s.Shards))
+		for i := range indexes {
+			indexes[i] = i
+		}
+	} else {
+		seen := make(map[int]bool, len(keys))
+		for _, key := range keys {
+			idx := s.getShardIndex(key)
+			if !seen[idx] {
+				seen[idx] = true
+				indexes = append(indexes, idx)
+			}
+		}
+		sort.Ints(indexes)
+	}
+
+	held := make(map[*Shard]bool, len(indexes))
+	for _, idx := range indexes {
+		shard := s.Shards[idx]
+		s.lock(shard)
+		held[shard] = true
+	}
+
+	defer func() {
+		for i := len(indexes) - 1; i >= 0; i-- {
+			s.unlock(s.Shards[indexes[i]])
+		}
+	}()
+
+	view := *s
+	view.held = mergeHeld(s.held, held)
+	fn(&view)
+}
+
+// mergeHeld combines the shards held by an outer view with newly locked ones.
+func mergeHeld(outer, inner map[*Shard]bool) map[*Shard]bool {
+	if len(outer) == 0 {
+		return inner
+	}
+	merged := make(map[*Shard]bool, len(outer)+len(inner))
+	for shard := range outer {
+		merged[shard] = true
+	}
+	for shard := range inner {
+		merged[shard] = true
+	}
+	return merged
+}
+
+// lock, unlock, rlock and runlock guard a shard, unless this store is a
+// WithLocked view that already holds it.
+func (s *Store) lock(shard *Shard) {
+	if !s.held[shard] {
+		shard.Mu.Lock()
+	}
+}
+
+func (s *Store) unlock(shard *Shard) {
+	if !s.held[shard] {
+		shard.Mu.Unlock()
+	}
+}
+
+func (s *Store) rlock(shard *Shard) {
+	if !s.held[shard] {
+		shard.Mu.RLock()
+	}
+}
+
+func (s *Store) runlock(shard *Shard) {
+	if !s.held[shard] {
+		shard.Mu.RUnlock()
+	}
+}
@@ -0,0 +1,485 @@
+package database
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrScoreNaN is returned when an increment would turn a score into NaN (e.g. +inf + -inf).
+var ErrScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
+
+// ZSet is a sorted set: a map gives O(1) score lookups by member and a
+// skiplist keeps the members ordered by score for ranks and ranges.
+type ZSet struct {
+	dict map[string]float64
+	zsl  *skiplist
+	// index orders the members for ZSCAN, see scan.go
+	index scanIndex
+}
+
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// NewZSet creates an empty sorted set.
+func NewZSet() *ZSet {
+	return &ZSet{
+		dict: make(map[string]float64),
+		zsl:  newSkiplist(),
+	}
+}
+
+// Len returns the number of members.
+func (z *ZSet) Len() int {
+	return len(z.dict)
+}
+
+// clone returns a copy of the sorted set sharing nothing with it.
+func (z *ZSet) clone() *ZSet {
+	c := NewZSet()
+	for x := z.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		c.Set(x.member, x.score)
+	}
+	return c
+}
+
+// Score returns the score of member.
+func (z *ZSet) Score(member string) (float64, bool) {
+	score, ok := z.dict[member]
+	return score, ok
+}
+
+// Set adds member or updates its score. It returns true when member is new.
+func (z *ZSet) Set(member string, score float64) bool {
+	cur, exists := z.dict[member]
+	if exists {
+		if cur == score {
+			return false
+		}
+		z.zsl.delete(cur, member)
+	} else {
+		z.index.add(member)
+	}
+	z.zsl.insert(score, member)
+	z.dict[member] = score
+	return !exists
+}
+
+// Remove deletes member and reports whether it was there.
+func (z *ZSet) Remove(member string) bool {
+	score, exists := z.dict[member]
+	if !exists {
+		return false
+	}
+	z.zsl.delete(score, member)
+	delete(z.dict, member)
+	z.index.remove(member)
+	return true
+}
+
+// Rank returns the 0-based position of member, counted from the highest
+// score when reverse is set.
+func (z *ZSet) Rank(member string, reverse bool) (int, bool) {
+	score, exists := z.dict[member]
+	if !exists {
+		return 0, false
+	}
+	rank := z.zsl.rank(score, member)
+	if reverse {
+		return z.Len() - rank, true
+	}
+	return rank - 1, true
+}
+
+// ZRangeBy selects how ZRangeSpec interprets its bounds.
+type ZRangeBy int
+
+const (
+	ZByRank ZRangeBy = iota
+	ZByScore
+	ZByLex
+)
+
+// ScoreBound is one end of a score range, "(1.5" is an exclusive bound.
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// LexBound is one end of a lexicographical range: "[a", "(a", or the
+// infinite "-" (Inf -1) and "+" (Inf 1).
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+// ZRangeSpec describes the ZRANGE family of queries.
+type ZRangeSpec struct {
+	By ZRangeBy
+	// Start and Stop are inclusive ranks for ZByRank, negative counts from the end.
+	Start, Stop int
+	// Min and Max bound ZByScore queries.
+	Min, Max ScoreBound
+	// LexMin and LexMax bound ZByLex queries.
+	LexMin, LexMax LexBound
+	// Rev walks from the highest to the lowest element.
+	Rev bool
+	// Offset and Count implement LIMIT, a negative Count means no limit.
+	Offset, Count int
+}
+
+func (b ScoreBound) belowMin(score float64) bool {
+	return score < b.Value || (b.Exclusive && score == b.Value)
+}
+
+func (b ScoreBound) aboveMax(score float64) bool {
+	return score > b.Value || (b.Exclusive && score == b.Value)
+}
+
+func (b LexBound) belowMin(member string) bool {
+	switch b.Inf {
+	case -1:
+		return false
+	case 1:
+		return true
+	}
+	return member < b.Value || (b.Exclusive && member == b.Value)
+}
+
+func (b LexBound) aboveMax(member string) bool {
+	switch b.Inf {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	return member > b.Value || (b.Exclusive && member == b.Value)
+}
+
+// Range returns the members selected by spec, in the requested order.
+func (z *ZSet) Range(spec ZRangeSpec) []ZMember {
+	if spec.By == ZByRank {
+		return z.rangeByRank(spec.Start, spec.Stop, spec.Rev)
+	}
+
+	var belowMin, aboveMax func(n *skiplistNode) bool
+	if spec.By == ZByScore {
+		belowMin = func(n *skiplistNode) bool { return spec.Min.belowMin(n.score) }
+		aboveMax = func(n *skiplistNode) bool { return spec.Max.aboveMax(n.score) }
+	} else {
+		belowMin = func(n *skiplistNode) bool { return spec.LexMin.belowMin(n.member) }
+		aboveMax = func(n *skiplistNode) bool { return spec.LexMax.aboveMax(n.member) }
+	}
+
+	var node *skiplistNode
+	if spec.Rev {
+		node = z.zsl.lastWith(func(n *skiplistNode) bool { return !aboveMax(n) })
+	} else {
+		node = z.zsl.firstWith(belowMin)
+	}
+
+	var result []ZMember
+	offset := spec.Offset
+	for node != nil && spec.Count != 0 {
+		if spec.Rev && belowMin(node) || !spec.Rev && aboveMax(node) {
+			break
+		}
+		if offset > 0 {
+			offset--
+		} else {
+			result = append(result, ZMember{Member: node.member, Score: node.score})
+			if spec.Count > 0 && len(result) == spec.Count {
+				break
+			}
+		}
+		if spec.Rev {
+			node = node.backward
+		} else {
+			node = node.level[0].forward
+		}
+	}
+
+	return result
+}
+
+func (z *ZSet) rangeByRank(start, stop int, rev bool) []ZMember {
+	length := z.Len()
+	start, stop, ok := normalizeRange(start, stop, length)
+	if !ok {
+		return nil
+	}
+
+	n := stop - start + 1
+	result := make([]ZMember, 0, n)
+
+	var node *skiplistNode
+	if rev {
+		node = z.zsl.byRank(length - start)
+	} else {
+		node = z.zsl.byRank(start + 1)
+	}
+	for ; n > 0 && node != nil; n-- {
+		result = append(result, ZMember{Member: node.member, Score: node.score})
+		if rev {
+			node = node.backward
+		} else {
+			node = node.level[0].forward
+		}
+	}
+
+	return result
+}
+
+// ZAddFlags are the conditions of ZADD.
+type ZAddFlags struct {
+	// NX only adds new members, XX only updates existing ones.
+	NX, XX bool
+	// GT and LT only update when the new score is greater / less than the current one.
+	GT, LT bool
+	// CH makes ZAdd count changed members too, not just added ones.
+	CH bool
+}
+
+// allows reports whether the flags let member get score, given its current state.
+func (f ZAddFlags) allows(exists bool, cur, score float64) bool {
+	if f.NX && exists || f.XX && !exists {
+		return false
+	}
+	if exists && (f.GT && score <= cur || f.LT && score >= cur) {
+		return false
+	}
+	return true
+}
+
+// getZSet returns the sorted set at key for reading, nil when missing.
+func getZSet(shard *Shard, key string) (*ZSet, error) {
+	item := shard.readItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.Type != TypeZSet {
+		return nil, ErrWrongType
+	}
+	return item.Value.(*ZSet), nil
+}
+
+// zsetForWrite returns the sorted set at key, creating an empty one (not
+// yet stored) when the key is missing. The shard write lock must be held.
+func zsetForWrite(shard *Shard, key string) (*ZSet, bool, error) {
+	item := shard.writeItem(key)
+	if item == nil {
+		return NewZSet(), false, nil
+	}
+	if item.Type != TypeZSet {
+		return nil, false, ErrWrongType
+	}
+	return item.Value.(*ZSet), true, nil
+}
+
+// ZAdd adds members to the sorted set at key, honouring flags.
+// It returns the number of added members (or added + updated with CH).
+func (s *Store) ZAdd(key string, flags ZAddFlags, members []ZMember) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	zset, stored, err := zsetForWrite(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	added, changed := 0, 0
+	for _, m := range members {
+		cur, exists := zset.Score(m.Member)
+		if !flags.allows(exists, cur, m.Score) {
+			continue
+		}
+		if zset.Set(m.Member, m.Score) {
+			added++
+		} else if cur != m.Score {
+			changed++
+		}
+	}
+
+	if added+changed > 0 {
+		if !stored {
+			shard.put(key, &Item{Value: zset, Type: TypeZSet})
+		}
+		shard.touch(key)
+	}
+
+	if flags.CH {
+		return added + changed, nil
+	}
+	return added, nil
+}
+
+// ZIncrBy adds incr to the score of member (ZINCRBY, ZADD ... INCR).
+// The bool is false when flags prevented the update.
+func (s *Store) ZIncrBy(key string, flags ZAddFlags, member string, incr float64) (float64, bool, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	zset, stored, err := zsetForWrite(shard, key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	cur, exists := zset.Score(member)
+	score := cur + incr
+	if math.IsNaN(score) {
+		return 0, false, ErrScoreNaN
+	}
+	if !flags.allows(exists, cur, score) {
+		return 0, false, nil
+	}
+
+	zset.Set(member, score)
+	if !stored {
+		shard.put(key, &Item{Value: zset, Type: TypeZSet})
+	}
+	shard.touch(key)
+
+	return score, true, nil
+}
+
+// ZRem removes members and returns how many were there.
+// The key is deleted when its last member goes.
+func (s *Store) ZRem(key string, members []string) (int, error) {
+	shard := s.getShard(key)
+	s.lock(shard)
+	defer s.unlock(shard)
+
+	zset, stored, err := zsetForWrite(shard, key)
+	if err != nil || !stored {
+		return 0, err
+	}
+
+	removed := 0
+	for _, m := range members {
+		if zset.Remove(m) {
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		if zset.Len() == 0 {
+			shard.remove(key)
+		}
+		shard.touch(key)
+	}
+	return removed, nil
+}
+
+// ZCard returns the number of members of the sorted set at key.
+func (s *Store) ZCard(key string) (int, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	zset, err := getZSet(shard, key)
+	if zset == nil {
+		return 0, err
+	}
+	return zset.Len(), nil
+}
+
+// ZScore returns the score of member.
+func (s *Store) ZScore(key, member string) (float64, bool, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	zset, err := getZSet(shard, key)
+	if zset == nil {
+		return 0, false, err
+	}
+	score, ok := zset.Score(member)
+	return score, ok, nil
+}
+
+// ZRank returns the 0-based rank and the score of member.
+func (s *Store) ZRank(key, member string, reverse bool) (int, float64, bool, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	zset, err := getZSet(shard, key)
+	if zset == nil {
+		return 0, 0, false, err
+	}
+	rank, ok := zset.Rank(member, reverse)
+	score, _ := zset.Score(member)
+	return rank, score, ok, nil
+}
+
+// ZRange returns the members of the sorted set at key selected by spec.
+func (s *Store) ZRange(key string, spec ZRangeSpec) ([]ZMember, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	zset, err := getZSet(shard, key)
+	if zset == nil {
+		return nil, err
+	}
+	return zset.Range(spec), nil
+}
+
+// ZRangeStore stores the members of src selected by spec into dst,
+// replacing whatever dst held, and returns how many were stored.
+func (s *Store) ZRangeStore(dst, src string, spec ZRangeSpec) (int, error) {
+	unlock := s.lockKeys(dst, src)
+	defer unlock()
+
+	srcShard := s.getShard(src)
+	zset, _, err := zsetForWrite(srcShard, src)
+	if err != nil {
+		return 0, err
+	}
+	members := zset.Range(spec)
+
+	dstShard := s.getShard(dst)
+	_, existed := dstShard.Items[dst]
+	if len(members) == 0 {
+		if existed {
+			dstShard.remove(dst)
+			dstShard.touch(dst)
+		}
+		return 0, nil
+	}
+
+	result := NewZSet()
+	for _, m := range members {
+		result.Set(m.Member, m.Score)
+	}
+	dstShard.put(dst, &Item{Value: result, Type: TypeZSet})
+	dstShard.touch(dst)
+
+	return len(members), nil
+}
+
+// ZScan is Scan for the members of the sorted set at key.
+func (s *Store) ZScan(key string, cursor uint64, args ScanArgs) (uint64, []ZMember, error) {
+	shard := s.getShard(key)
+	s.rlock(shard)
+	defer s.runlock(shard)
+
+	zset, err := getZSet(shard, key)
+	if zset == nil {
+		return 0, nil, err
+	}
+
+	visited, next := zset.index.scan(cursor, args.Count)
+	members := make([]ZMember, 0, len(visited))
+	for _, m := range visited {
+		if args.keep(m) {
+			members = append(members, ZMember{Member: m, Score: zset.dict[m]})
+		}
+	}
+	return next, members, nil
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// sortedModel returns the members of z ordered the way the skiplist must keep them.
+func sortedModel(z map[string]float64) []ZMember {
+	model := make([]ZMember, 0, len(z))
+	for m, s := range z {
+		model = append(model, ZMember{Member: m, Score: s})
+	}
+	sort.Slice(model, func(i, j int) bool {
+		if model[i].Score != model[j].Score {
+			return model[i].Score < model[j].Score
+		}
+		return model[i].Member < model[j].Member
+	})
+	return model
+}
+
+func TestZSetMatchesModel(t *testing.T) {
+	z := NewZSet()
+	model := make(map[string]float64)
+
+	for i := 0; i < 5000; i++ {
+		member := "m" + strconv.Itoa(rand.IntN(500))
+		score := float64(rand.IntN(100))
+		if rand.IntN(4) == 0 {
+			z.Remove(member)
+			delete(model, member)
+		} else {
+			z.Set(member, score)
+			model[member] = score
+		}
+	}
+
+	sorted := sortedModel(model)
+	if z.Len() != len(sorted) || z.zsl.length != len(sorted) {
+		t.Fatalf("Expected %d members, got %d (skiplist %d)", len(sorted), z.Len(), z.zsl.length)
+	}
+
+	for i, want := range sorted {
+		if rank, ok := z.Rank(want.Member, false); !ok || rank != i {
+			t.Fatalf("Rank(%s): got %d, want %d", want.Member, rank, i)
+		}
+		if rank, _ := z.Rank(want.Member, true); rank != len(sorted)-1-i {
+			t.Fatalf("reverse Rank(%s): got %d, want %d", want.Member, rank, len(sorted)-1-i)
+		}
+		if node := z.zsl.byRank(i + 1); node == nil || node.member != want.Member {
+			t.Fatalf("byRank(%d): got %v, want %s", i+1, node, want.Member)
+		}
+	}
+
+	all := z.Range(ZRangeSpec{By: ZByRank, Start: 0, Stop: -1})
+	for i := range all {
+		if all[i] != sorted[i] {
+			t.Fatalf("Range by rank differs at %d: got %v, want %v", i, all[i], sorted[i])
+		}
+	}
+
+	// (20, 60] walked both ways
+	spec := ZRangeSpec{By: ZByScore, Min: ScoreBound{Value: 20, Exclusive: true}, Max: ScoreBound{Value: 60}, Count: -1}
+	var want []ZMember
+	for _, m := range sorted {
+		if m.Score > 20 && m.Score <= 60 {
+			want = append(want, m)
+		}
+	}
+	got := z.Range(spec)
+	if len(got) != len(want) {
+		t.Fatalf("Range by score: got %d members, want %d", len(got), len(want))
+	}
+	spec.Rev = true
+	rev := z.Range(spec)
+	for i := range want {
+		if got[i] != want[i] || rev[len(rev)-1-i] != want[i] {
+			t.Fatalf("Range by score differs at %d", i)
+		}
+	}
+}
+
+func TestZAddFlags(t *testing.T) {
+	s := NewStore()
+
+	if n, _ := s.ZAdd("z", ZAddFlags{XX: true}, []ZMember{{"a", 1}}); n != 0 {
+		t.Errorf("XX must not create members, added %d", n)
+	}
+	if _, ok := s.Get("z"); ok {
+		t.Error("A ZADD that adds nothing must not create the key")
+	}
+
+	s.ZAdd("z", ZAddFlags{}, []ZMember{{"a", 5}})
+	if n, _ := s.ZAdd("z", ZAddFlags{GT: true, CH: true}, []ZMember{{"a", 3}}); n != 0 {
+		t.Errorf("GT must not lower a score, changed %d", n)
+	}
+	if n, _ := s.ZAdd("z", ZAddFlags{LT: true, CH: true}, []ZMember{{"a", 3}, {"b", 9}}); n != 2 {
+		t.Errorf("LT should lower a and still add b, changed %d", n)
+	}
+	if score, _, _ := s.ZScore("z", "a"); score != 3 {
+		t.Errorf("Expected a to be 3, got %v", score)
+	}
+
+	if n, _ := s.ZRem("z", []string{"a", "b"}); n != 2 {
+		t.Errorf("Expected 2 removed, got %d", n)
+	}
+	if _, ok := s.Get("z"); ok {
+		t.Error("Removing the last member should delete the key")
+	}
+}
+
+func TestZSetWrites(t *testing.T) {
+	s := NewStore()
+	s.Set("str", []byte("v"), 0)
+
+	if _, err := s.ZAdd("str", ZAddFlags{}, []ZMember{{"a", 1}}); err != ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+
+	version := s.Watch("z")
+	s.ZAdd("z", ZAddFlags{}, []ZMember{{"a", 1}})
+	if !s.Modified("z", version) {
+		t.Error("ZADD should mark a watched key as modified")
+	}
+	s.Unwatch("z")
+}
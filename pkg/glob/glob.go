@@ -0,0 +1,96 @@
+// Package glob matches strings against the glob-style patterns of Redis,
+// as used by KEYS and the MATCH option of the SCAN family:
+//
+//	?       any single byte
+//	*       any sequence of bytes, including none
+//	[abc]   one of the bytes listed; [^abc] any byte but those; [a-z] a range
+//	\x      x itself, to match a special character literally
+//
+// Patterns and strings are compared byte by byte, so both are binary-safe.
+package glob
+
+// Match reports whether s matches pattern. Like in Redis, malformed patterns
+// are not errors: an unterminated [ class ends with the pattern, and a
+// trailing \ matches a backslash.
+func Match(pattern, s string) bool {
+	p, i := 0, 0
+	// the position after the last * seen, and where in s it started matching
+	star, starAt := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				star, starAt = p+1, i
+				p++
+				continue
+			}
+			if n, ok := matchByte(pattern[p:], s[i]); ok {
+				p += n
+				i++
+				continue
+			}
+		}
+		// every token but * matches a single byte, so backtracking to the
+		// last * and letting it absorb one more byte is enough
+		if star < 0 {
+			return false
+		}
+		starAt++
+		p, i = star, starAt
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchByte matches c against the token starting pattern, which isn't a *.
+// It returns the length of the token and whether c matched it.
+func matchByte(pattern string, c byte) (int, bool) {
+	switch pattern[0] {
+	case '?':
+		return 1, true
+	case '\\':
+		if len(pattern) >= 2 {
+			return 2, pattern[1] == c
+		}
+		return 1, c == '\\'
+	case '[':
+		return matchClass(pattern, c)
+	}
+	return 1, pattern[0] == c
+}
+
+// matchClass matches c against the [...] class starting pattern.
+func matchClass(pattern string, c byte) (int, bool) {
+	i := 1
+	negate := i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+
+	matched := false
+	for i < len(pattern) && pattern[i] != ']' {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			matched = matched || pattern[i+1] == c
+			i += 2
+		case i+2 < len(pattern) && pattern[i+1] == '-':
+			lo, hi := pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || lo <= c && c <= hi
+			i += 3
+		default:
+			matched = matched || pattern[i] == c
+			i++
+		}
+	}
+
+	if i < len(pattern) {
+		// past the closing ]
+		i++
+	}
+	return i, matched != negate
+}
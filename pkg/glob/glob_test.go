@@ -0,0 +1,82 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		// the examples of the KEYS documentation
+		{"h?llo", "hello", true},
+		{"h?llo", "hallo", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "hllo", true},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hbllo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hallo", true},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+
+		// stars
+		{"*", "", true},
+		{"*", "anything", true},
+		{"**", "x", true},
+		{"", "", true},
+		{"", "x", false},
+		{"a*", "abc", true},
+		{"*c", "abc", true},
+		{"*c", "abd", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"*ab", "aab", true},
+		{"*a*b", "xaxxb", true},
+		{"user:*:name", "user:42:name", true},
+		{"user:*:name", "user:42:email", false},
+
+		// classes
+		{"[z-a]", "m", true},
+		// like in Redis, the ] closing [a-] ends the range instead
+		{"[a-]", "^", true},
+		{"[a-]", "b", false},
+		{"[abc", "b", true},
+		{"[abc", "d", false},
+		{"[]", "x", false},
+		{"[^]", "x", true},
+		{"[\\]]", "]", true},
+		{"[\\-]", "-", true},
+		{"[\\-]", "a", false},
+
+		// escapes
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"a\\?", "a?", true},
+		{"a\\?", "ab", false},
+		{"\\[a]", "[a]", true},
+		{"a\\", "a\\", true},
+
+		// binary-safe
+		{"a?b", "a\x00b", true},
+		{"[\x00-\x01]", "\x01", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestMatchBacktracking checks that a pattern made of many stars, which takes
+// exponential time with naive recursion, is matched quickly.
+func TestMatchBacktracking(t *testing.T) {
+	pattern := "a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*b"
+	s := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	if Match(pattern, s) {
+		t.Errorf("Match(%q, %q) = true, want false", pattern, s)
+	}
+}
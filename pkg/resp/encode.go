@@ -0,0 +1,22 @@
+package resp
+
+import "strconv"
+
+// AppendCommand appends args to b as a multibulk array, the same framing
+// clients use to send commands. Every argument is length-prefixed so the
+// result is binary-safe and can be decoded again with Reader.
+func AppendCommand(b []byte, args [][]byte) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, '\r', '\n')
+
+	for _, arg := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(arg)), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, arg...)
+		b = append(b, '\r', '\n')
+	}
+
+	return b
+}
@@ -0,0 +1,306 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+)
+
+const (
+	// maxMultiBulkLen is the largest number of arguments accepted in one command.
+	maxMultiBulkLen = 1024 * 1024
+	// maxBulkLen is the largest single argument accepted (512MB, same as Redis).
+	maxBulkLen = 512 * 1024 * 1024
+	// maxInlineLen protects us from clients sending an endless line.
+	maxInlineLen = 64 * 1024
+	// The lengths announced by the client are only trusted up to these for
+	// the first allocation: larger arrays and strings grow as the data
+	// arrives, so a few bytes can't make us allocate gigabytes.
+	maxPreallocArgs  = 1024
+	maxPreallocBytes = 64 * 1024
+)
+
+// ProtocolError is returned when the client sends something that is not valid RESP.
+// The connection can't be resynchronized after that, so it should be closed.
+type ProtocolError struct {
+	msg string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.msg
+}
+
+func protocolError(msg string) error {
+	return &ProtocolError{msg: msg}
+}
+
+// IsProtocolError reports whether err was caused by a malformed request.
+func IsProtocolError(err error) bool {
+	var pe *ProtocolError
+	return errors.As(err, &pe)
+}
+
+// Reader decodes client requests from a stream.
+// It understands both multibulk arrays (*N\r\n$len\r\n...) which every
+// real client sends, and inline commands (SET key value\n) typed by hand.
+type Reader struct {
+	rd *bufio.Reader
+}
+
+// NewReader wraps rd in a buffered RESP request decoder.
+func NewReader(rd io.Reader) *Reader {
+	return &Reader{rd: bufio.NewReader(rd)}
+}
+
+// ReadCommand reads the next command and returns its arguments exactly as sent.
+// An empty command (blank inline line, *0 or *-1) is returned as a nil slice.
+func (r *Reader) ReadCommand() ([][]byte, error) {
+	b, err := r.rd.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if b[0] == '*' {
+		return r.readMultiBulk()
+	}
+	return r.readInline()
+}
+
+// Buffered returns the number of bytes already received but not yet decoded.
+// A non-zero value means the client pipelined more commands behind the current one.
+func (r *Reader) Buffered() int {
+	return r.rd.Buffered()
+}
+
+// readLine reads up to \n and strips the trailing \r\n (or bare \n).
+func (r *Reader) readLine() ([]byte, error) {
+	line, err := r.rd.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// line is longer than the buffer, keep collecting
+		buf := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			if len(buf) > maxInlineLen {
+				return nil, protocolError("too big inline request")
+			}
+			line, err = r.rd.ReadSlice('\n')
+			buf = append(buf, line...)
+		}
+		line = buf
+	}
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+func (r *Reader) readMultiBulk() ([][]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count > maxMultiBulkLen {
+		return nil, protocolError("invalid multibulk length")
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+
+	args := make([][]byte, 0, min(count, maxPreallocArgs))
+	for i := 0; i < count; i++ {
+		arg, err := r.readBulk()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+func (r *Reader) readBulk() ([]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+
+	if len(line) == 0 || line[0] != '$' {
+		got := "?"
+		if len(line) > 0 {
+			got = string(line[0])
+		}
+		return nil, protocolError("expected '$', got '" + got + "'")
+	}
+
+	size, err := strconv.Atoi(string(line[1:]))
+	if err != nil || size < 0 || size > maxBulkLen {
+		return nil, protocolError("invalid bulk length")
+	}
+
+	// payload plus the trailing \r\n, doubling the buffer each time it fills up
+	buf := make([]byte, min(size+2, maxPreallocBytes))
+	for read := 0; ; {
+		if _, err := io.ReadFull(r.rd, buf[read:]); err != nil {
+			return nil, unexpected(err)
+		}
+		read = len(buf)
+		if read == size+2 {
+			break
+		}
+		buf = append(buf, make([]byte, min(size+2-read, read))...)
+	}
+	if buf[size] != '\r' || buf[size+1] != '\n' {
+		return nil, protocolError("bulk string not terminated by CRLF")
+	}
+
+	return buf[:size:size], nil
+}
+
+func (r *Reader) readInline() ([][]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	args, ok := splitArgs(line)
+	if !ok {
+		return nil, protocolError("unbalanced quotes in request")
+	}
+	return args, nil
+}
+
+// unexpected turns a clean EOF in the middle of a command into ErrUnexpectedEOF.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// splitArgs splits an inline command the way redis-cli does: on whitespace,
+// honouring "double quoted" strings with escapes and 'single quoted' strings.
+func splitArgs(line []byte) ([][]byte, bool) {
+	var args [][]byte
+	i := 0
+
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, true
+		}
+
+		var cur []byte
+		inDouble, inSingle, done := false, false, false
+
+		for !done {
+			if inDouble {
+				if i >= len(line) {
+					return nil, false
+				}
+				switch {
+				case line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' &&
+					isHex(line[i+2]) && isHex(line[i+3]):
+					cur = append(cur, hexVal(line[i+2])<<4|hexVal(line[i+3]))
+					i += 3
+				case line[i] == '\\' && i+1 < len(line):
+					i++
+					switch line[i] {
+					case 'n':
+						cur = append(cur, '\n')
+					case 'r':
+						cur = append(cur, '\r')
+					case 't':
+						cur = append(cur, '\t')
+					case 'b':
+						cur = append(cur, '\b')
+					case 'a':
+						cur = append(cur, '\a')
+					default:
+						cur = append(cur, line[i])
+					}
+				case line[i] == '"':
+					// closing quote must be followed by a space or nothing
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, false
+					}
+					done = true
+				default:
+					cur = append(cur, line[i])
+				}
+			} else if inSingle {
+				if i >= len(line) {
+					return nil, false
+				}
+				switch {
+				case line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					cur = append(cur, '\'')
+				case line[i] == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, false
+					}
+					done = true
+				default:
+					cur = append(cur, line[i])
+				}
+			} else {
+				if i >= len(line) {
+					break
+				}
+				switch {
+				case isSpace(line[i]):
+					done = true
+				case line[i] == '"':
+					inDouble = true
+				case line[i] == '\'':
+					inSingle = true
+				default:
+					cur = append(cur, line[i])
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+
+		if cur == nil {
+			cur = []byte{}
+		}
+		args = append(args, cur)
+	}
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\v', '\f':
+		return true
+	}
+	return false
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexVal(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
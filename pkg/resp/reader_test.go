@@ -0,0 +1,192 @@
+package resp
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, input string) [][]string {
+	t.Helper()
+
+	r := NewReader(strings.NewReader(input))
+	var cmds [][]string
+	for {
+		args, err := r.ReadCommand()
+		if err == io.EOF {
+			return cmds
+		}
+		if err != nil {
+			t.Fatalf("ReadCommand(%q) failed: %v", input, err)
+		}
+		if args == nil {
+			continue
+		}
+		cmd := make([]string, len(args))
+		for i, a := range args {
+			cmd[i] = string(a)
+		}
+		cmds = append(cmds, cmd)
+	}
+}
+
+func equalCmds(a, b [][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][]string
+	}{
+		{
+			name:  "multibulk",
+			input: "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n",
+			want:  [][]string{{"SET", "key", "value"}},
+		},
+		{
+			name:  "multibulk with spaces and newlines in value",
+			input: "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$12\r\nhello\r\nworld\r\n",
+			want:  [][]string{{"SET", "k", "hello\r\nworld"}},
+		},
+		{
+			name:  "empty bulk string",
+			input: "*2\r\n$3\r\nGET\r\n$0\r\n\r\n",
+			want:  [][]string{{"GET", ""}},
+		},
+		{
+			name:  "inline",
+			input: "SET key value\r\n",
+			want:  [][]string{{"SET", "key", "value"}},
+		},
+		{
+			name:  "inline with bare newline",
+			input: "PING\n",
+			want:  [][]string{{"PING"}},
+		},
+		{
+			name:  "inline with quotes",
+			input: "SET \"my key\" 'it\\'s'\r\n",
+			want:  [][]string{{"SET", "my key", "it's"}},
+		},
+		{
+			name:  "inline with escapes",
+			input: "SET k \"a\\tb\\x41\"\r\n",
+			want:  [][]string{{"SET", "k", "a\tbA"}},
+		},
+		{
+			name:  "blank lines and empty arrays are skipped",
+			input: "\r\n*0\r\n*-1\r\nPING\r\n",
+			want:  [][]string{{"PING"}},
+		},
+		{
+			name:  "mixed stream",
+			input: "PING\r\n*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\nGET k\r\n",
+			want:  [][]string{{"PING"}, {"ECHO", "hi"}, {"GET", "k"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readAll(t, tt.input)
+			if !equalCmds(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCommandProtocolErrors(t *testing.T) {
+	inputs := []string{
+		"*x\r\n",
+		"*1\r\n+OK\r\n",
+		"*1\r\n$-5\r\n",
+		"*1\r\n$3\r\nabcde\r\n",
+		"SET \"unterminated\r\n",
+		"SET 'a'b\r\n",
+	}
+
+	for _, input := range inputs {
+		_, err := NewReader(strings.NewReader(input)).ReadCommand()
+		if !IsProtocolError(err) {
+			t.Errorf("ReadCommand(%q) = %v, want protocol error", input, err)
+		}
+	}
+}
+
+func TestReadCommandTruncated(t *testing.T) {
+	_, err := NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n")).ReadCommand()
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestAppendCommandRoundTrip(t *testing.T) {
+	want := [][]byte{[]byte("SET"), []byte("k\x00\r\n"), []byte("\x00\xff\r\n\r\nv"), {}}
+
+	r := NewReader(strings.NewReader(string(AppendCommand(nil, want))))
+	got, err := r.ReadCommand()
+	if err != nil {
+		t.Fatalf("ReadCommand failed: %v", err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d args, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if string(got[i]) != string(want[i]) {
+			t.Errorf("arg %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+// TestReadCommandLengthsNotTrusted checks that announcing huge lengths
+// without sending the data doesn't allocate them.
+func TestReadCommandLengthsNotTrusted(t *testing.T) {
+	inputs := []string{
+		"*1\r\n$536870912\r\nabc",
+		"*1048576\r\n$3\r\nabc\r\n",
+	}
+
+	for _, input := range inputs {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := NewReader(strings.NewReader(input)).ReadCommand()
+		runtime.ReadMemStats(&after)
+
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("ReadCommand(%q) = %v, want io.ErrUnexpectedEOF", input, err)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Errorf("ReadCommand(%q) allocated %d bytes", input, n)
+		}
+	}
+}
+
+// TestReadCommandLargeBulk reads a bulk string larger than the first
+// allocation.
+func TestReadCommandLargeBulk(t *testing.T) {
+	value := strings.Repeat("0123456789", 100000)
+	args, err := NewReader(strings.NewReader("*1\r\n$1000000\r\n" + value + "\r\n")).ReadCommand()
+	if err != nil || len(args) != 1 || string(args[0]) != value {
+		t.Errorf("ReadCommand of a 1MB bulk string = %d args, %v", len(args), err)
+	}
+	if cap(args[0]) != len(value) {
+		t.Errorf("Expected the argument capacity to be capped at its length, got %d", cap(args[0]))
+	}
+}
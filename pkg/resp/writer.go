@@ -0,0 +1,213 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Protocol versions a client can negotiate with HELLO.
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// Writer encodes replies for one connection.
+// Commands describe *what* they reply (a map, a set, a null...) and the writer
+// picks the wire representation for the protocol the client negotiated:
+// RESP3 gets its native types, RESP2 gets the classic flattened equivalents.
+type Writer struct {
+	bw      *bufio.Writer
+	proto   int
+	scratch [64]byte
+}
+
+// NewWriter returns a RESP2 writer buffering replies on top of w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		bw:    bufio.NewWriterSize(w, 16*1024),
+		proto: RESP2,
+	}
+}
+
+// Protocol returns the protocol version replies are encoded with.
+func (w *Writer) Protocol() int {
+	return w.proto
+}
+
+// SetProtocol switches the encoding for every following reply.
+func (w *Writer) SetProtocol(proto int) {
+	w.proto = proto
+}
+
+// Flush sends every buffered reply to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.bw.Flush()
+}
+
+// Buffered returns the number of reply bytes not flushed yet.
+func (w *Writer) Buffered() int {
+	return w.bw.Buffered()
+}
+
+// writeHeader writes a type prefix followed by a number and CRLF, e.g. "*3\r\n".
+func (w *Writer) writeHeader(prefix byte, n int64) {
+	b := append(w.scratch[:0], prefix)
+	b = strconv.AppendInt(b, n, 10)
+	b = append(b, '\r', '\n')
+	w.bw.Write(b)
+}
+
+// WriteSimpleString writes a status reply such as +OK.
+// Line breaks in s are replaced by spaces so the reply stays on one line.
+func (w *Writer) WriteSimpleString(s string) {
+	w.bw.WriteByte('+')
+	w.bw.WriteString(singleLine(s))
+	w.bw.WriteString("\r\n")
+}
+
+// WriteOK writes the +OK status reply.
+func (w *Writer) WriteOK() {
+	w.bw.WriteString("+OK\r\n")
+}
+
+// WriteError writes an error reply. msg starts with the error code,
+// e.g. "ERR syntax error" or "WRONGTYPE Operation against ...".
+// Like simple strings, line breaks in msg are replaced by spaces.
+func (w *Writer) WriteError(msg string) {
+	w.bw.WriteByte('-')
+	w.bw.WriteString(singleLine(msg))
+	w.bw.WriteString("\r\n")
+}
+
+// WriteInteger writes an integer reply.
+func (w *Writer) WriteInteger(n int64) {
+	w.writeHeader(':', n)
+}
+
+// WriteBulk writes a binary-safe bulk string.
+func (w *Writer) WriteBulk(b []byte) {
+	w.writeHeader('$', int64(len(b)))
+	w.bw.Write(b)
+	w.bw.WriteString("\r\n")
+}
+
+// WriteBulkString writes s as a bulk string.
+func (w *Writer) WriteBulkString(s string) {
+	w.writeHeader('$', int64(len(s)))
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+// WriteNull writes a missing value: $-1 in RESP2, _ in RESP3.
+func (w *Writer) WriteNull() {
+	if w.proto == RESP3 {
+		w.bw.WriteString("_\r\n")
+		return
+	}
+	w.bw.WriteString("$-1\r\n")
+}
+
+// WriteNullArray writes a missing aggregate: *-1 in RESP2, _ in RESP3.
+func (w *Writer) WriteNullArray() {
+	if w.proto == RESP3 {
+		w.bw.WriteString("_\r\n")
+		return
+	}
+	w.bw.WriteString("*-1\r\n")
+}
+
+// WriteArray starts an array of n elements; the caller writes the elements next.
+func (w *Writer) WriteArray(n int) {
+	w.writeHeader('*', int64(n))
+}
+
+// WriteMap starts a map of n key/value pairs; the caller writes 2*n elements next.
+// RESP2 has no maps so it becomes a flat array of alternating keys and values.
+func (w *Writer) WriteMap(n int) {
+	if w.proto == RESP3 {
+		w.writeHeader('%', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(2*n))
+}
+
+// WriteSet starts an unordered collection of n elements (an array in RESP2).
+func (w *Writer) WriteSet(n int) {
+	if w.proto == RESP3 {
+		w.writeHeader('~', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(n))
+}
+
+// WritePush starts an out-of-band push message of n elements, used for pub/sub.
+// RESP2 clients receive it as a plain array.
+func (w *Writer) WritePush(n int) {
+	if w.proto == RESP3 {
+		w.writeHeader('>', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(n))
+}
+
+// WriteDouble writes a floating point number: a native double in RESP3,
+// a bulk string in RESP2.
+func (w *Writer) WriteDouble(f float64) {
+	// the second half of scratch, writeHeader owns the first one
+	b := w.scratch[32:32]
+	switch {
+	case math.IsInf(f, 1):
+		b = append(b, "inf"...)
+	case math.IsInf(f, -1):
+		b = append(b, "-inf"...)
+	case math.IsNaN(f):
+		b = append(b, "nan"...)
+	case f == 0 || math.Abs(f) >= 1e-4 && math.Abs(f) < 1e17:
+		// like Redis, print scores such as 1234567 without an exponent
+		b = strconv.AppendFloat(b, f, 'f', -1, 64)
+	default:
+		b = strconv.AppendFloat(b, f, 'g', -1, 64)
+	}
+
+	if w.proto == RESP3 {
+		w.bw.WriteByte(',')
+		w.bw.Write(b)
+		w.bw.WriteString("\r\n")
+		return
+	}
+	w.WriteBulk(b)
+}
+
+// WriteBool writes a boolean: #t/#f in RESP3, :1/:0 in RESP2.
+func (w *Writer) WriteBool(v bool) {
+	if w.proto == RESP3 {
+		if v {
+			w.bw.WriteString("#t\r\n")
+		} else {
+			w.bw.WriteString("#f\r\n")
+		}
+		return
+	}
+	if v {
+		w.bw.WriteString(":1\r\n")
+	} else {
+		w.bw.WriteString(":0\r\n")
+	}
+}
+
+// singleLine makes s safe to send as a simple string or error, which can't span lines.
+// Error messages may echo user input (e.g. an unknown command name) so this matters.
+func singleLine(s string) string {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, s)
+}
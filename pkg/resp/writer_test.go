@@ -0,0 +1,103 @@
+package resp
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestWriterProtocols(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w *Writer)
+		resp2 string
+		resp3 string
+	}{
+		{"simple string", func(w *Writer) { w.WriteSimpleString("PONG") }, "+PONG\r\n", "+PONG\r\n"},
+		{"error", func(w *Writer) { w.WriteError("ERR boom") }, "-ERR boom\r\n", "-ERR boom\r\n"},
+		{"integer", func(w *Writer) { w.WriteInteger(-42) }, ":-42\r\n", ":-42\r\n"},
+		{"bulk", func(w *Writer) { w.WriteBulk([]byte("a\r\nb")) }, "$4\r\na\r\nb\r\n", "$4\r\na\r\nb\r\n"},
+		{"null", func(w *Writer) { w.WriteNull() }, "$-1\r\n", "_\r\n"},
+		{"null array", func(w *Writer) { w.WriteNullArray() }, "*-1\r\n", "_\r\n"},
+		{"bool", func(w *Writer) { w.WriteBool(true); w.WriteBool(false) }, ":1\r\n:0\r\n", "#t\r\n#f\r\n"},
+		{"double", func(w *Writer) { w.WriteDouble(1.5) }, "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{"large double", func(w *Writer) { w.WriteDouble(1234567) }, "$7\r\n1234567\r\n", ",1234567\r\n"},
+		{"infinite double", func(w *Writer) { w.WriteDouble(math.Inf(-1)) }, "$4\r\n-inf\r\n", ",-inf\r\n"},
+		{
+			"map",
+			func(w *Writer) { w.WriteMap(1); w.WriteBulkString("k"); w.WriteInteger(1) },
+			"*2\r\n$1\r\nk\r\n:1\r\n",
+			"%1\r\n$1\r\nk\r\n:1\r\n",
+		},
+		{
+			"set",
+			func(w *Writer) { w.WriteSet(1); w.WriteBulkString("m") },
+			"*1\r\n$1\r\nm\r\n",
+			"~1\r\n$1\r\nm\r\n",
+		},
+		{
+			"push",
+			func(w *Writer) { w.WritePush(1); w.WriteBulkString("message") },
+			"*1\r\n$7\r\nmessage\r\n",
+			">1\r\n$7\r\nmessage\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, proto := range []int{RESP2, RESP3} {
+				var buf bytes.Buffer
+				w := NewWriter(&buf)
+				w.SetProtocol(proto)
+				tt.write(w)
+				w.Flush()
+
+				want := tt.resp2
+				if proto == RESP3 {
+					want = tt.resp3
+				}
+				if buf.String() != want {
+					t.Errorf("RESP%d: got %q, want %q", proto, buf.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriterDoesNotAllocate(t *testing.T) {
+	w := NewWriter(io.Discard)
+	value := []byte("value")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		w.WriteArray(2)
+		w.WriteArray(3)
+		w.WriteInteger(12345)
+		w.WriteBulk(value)
+		w.WriteDouble(3.25)
+		w.WriteBulkString("nested")
+		w.WriteError("ERR boom")
+		w.WriteNull()
+		w.Flush()
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected replies to be encoded without allocations, got %v per run", allocs)
+	}
+}
+
+func BenchmarkWriterBulkArray(b *testing.B) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	value := []byte("some value of a reasonable size")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.WriteArray(10)
+		for j := 0; j < 10; j++ {
+			w.WriteBulk(value)
+		}
+		w.Flush()
+		buf.Reset()
+	}
+}